@@ -0,0 +1,65 @@
+package certparser
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"hash"
+)
+
+var (
+	oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidPoison  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+)
+
+// tbsFingerprint hashes a DER TBSCertificate with the CT poison and SCT
+// list extensions left out, so a precert and its final certificate hash
+// the same. It returns "" if the structure can't be walked.
+func tbsFingerprint(tbs []byte) string {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(tbs, &seq); err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	rest := seq.Bytes
+	for len(rest) > 0 {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return ""
+		}
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			if err := hashExtensions(h, field.Bytes); err != nil {
+				return ""
+			}
+			continue
+		}
+		h.Write(field.FullBytes)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashExtensions(h hash.Hash, data []byte) error {
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(data, &seq); err != nil {
+		return err
+	}
+	rest := seq.Bytes
+	for len(rest) > 0 {
+		var ext asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &ext); err != nil {
+			return err
+		}
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(ext.Bytes, &oid); err != nil {
+			return err
+		}
+		if oid.Equal(oidSCTList) || oid.Equal(oidPoison) {
+			continue
+		}
+		h.Write(ext.FullBytes)
+	}
+	return nil
+}
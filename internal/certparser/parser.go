@@ -4,30 +4,176 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"math"
+	"net/netip"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
 )
 
+// minPrefilterLen is the shortest domain filter worth scanning raw leaf
+// bytes for. Shorter patterns turn up by chance in most DER blobs, so the
+// scan costs time without saving any parses.
+const minPrefilterLen = 4
+
+// Leaf timestamps outside [ctEpoch, now+maxClockSkew] can't come from a
+// real log: the first CT logs went live in 2013.
+var ctEpoch = time.Date(2013, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const maxClockSkew = 24 * time.Hour
+
 type Parser struct {
 	domainFilter      []string
 	domainFilterBytes [][]byte
+	prefilter         bool
+	ipFilters         []ipFilter
+	serials           map[string]bool
+	brands            [][]byte
+	opts              Options
+	tooOld            atomic.Int64
+	badTimestamp      atomic.Int64
+}
+
+// Options holds post-parse filters applied to each result.
+type Options struct {
+	CAOnly bool
+	EKU    []string
+	// ValidationLevels keeps only certificates asserting one of these
+	// CA/Browser Forum levels (DV, OV, IV, EV; case-insensitive).
+	ValidationLevels []string
+	// Serials keeps only certificates with one of these serial numbers,
+	// in hex; see NormalizeSerial.
+	Serials []string
+	// ExactMatch makes domain filters match only the name itself (or its
+	// wildcard), not subdomains.
+	ExactMatch bool
+	// SubdomainsOnly makes domain filters match subdomains (and wildcards
+	// covering them) but not the name itself.
+	SubdomainsOnly bool
+	// BrandMatch also keeps certificates with a name that contains the
+	// first label of a domain filter anywhere, such as
+	// example-com-login.evil.net for example.com, and sets
+	// CertResult.BrandEmbedded on them.
+	BrandMatch bool
+	// Issuers and DenyIssuers are case-insensitive substrings matched
+	// against the issuer name.
+	Issuers     []string
+	DenyIssuers []string
+	// ExcludeExpiredAtLog drops certificates that had already expired when
+	// the log accepted them.
+	ExcludeExpiredAtLog bool
+	// MinNotBefore drops certificates issued before this time (zero = no
+	// limit).
+	MinNotBefore time.Time
+	// IncludeRaw fills CertResult.DER, or CertResult.PrecertTBS for
+	// precert entries.
+	IncludeRaw bool
+	// ShowMatch records which domain filter matched, and on which name, in
+	// CertResult.MatchedFilter and MatchedDomain.
+	ShowMatch bool
+	// StrictTimestamps drops entries whose leaf timestamp is before 2013
+	// or more than a day in the future.
+	StrictTimestamps bool
+	// ReportCertErrors makes ParseEntry return a *CertError for
+	// certificates crypto/x509 cannot parse instead of skipping them.
+	ReportCertErrors bool
+	// SkipX509 and SkipPrecerts drop x509_entry or precert_entry leaves
+	// by their type field, before the certificate is parsed.
+	SkipX509     bool
+	SkipPrecerts bool
+	// LinkPrecerts fills CertResult.TBSHash so precerts can be paired with
+	// their final certificates.
+	LinkPrecerts bool
+}
+
+// ipFilter is a -d value that parsed as an IP address or CIDR block; a
+// single address is stored as a full-length prefix.
+type ipFilter struct {
+	name   string
+	prefix netip.Prefix
 }
 
 func New(domains []string) *Parser {
+	return NewWithOptions(domains, Options{})
+}
+
+func NewWithOptions(domains []string, opts Options) *Parser {
 	lower := make([]string, len(domains))
 	lowerBytes := make([][]byte, len(domains))
+	prefilter := len(domains) > 0
+	var ipFilters []ipFilter
+	var brands [][]byte
+	if opts.BrandMatch {
+		brands = make([][]byte, len(domains))
+	}
 	for i, d := range domains {
 		lower[i] = strings.ToLower(strings.TrimPrefix(d, "."))
 		lowerBytes[i] = []byte(lower[i])
+		if prefix, ok := parseIPFilter(lower[i]); ok {
+			ipFilters = append(ipFilters, ipFilter{name: lower[i], prefix: prefix})
+			// IP SANs are binary in DER, so the text never shows up.
+			prefilter = false
+		} else if brands != nil {
+			brand, _, _ := strings.Cut(lower[i], ".")
+			brands[i] = []byte(brand)
+			// The brand is what has to show up in the raw entry.
+			lowerBytes[i] = brands[i]
+		}
+		// Filters are ORed, so one short filter makes the scan useless.
+		if len(lowerBytes[i]) < minPrefilterLen {
+			prefilter = false
+		}
+	}
+	opts.EKU = extKeyUsageFilters(opts.EKU)
+	opts.Issuers = lowerAll(opts.Issuers)
+	opts.DenyIssuers = lowerAll(opts.DenyIssuers)
+	var serials map[string]bool
+	if len(opts.Serials) > 0 {
+		serials = make(map[string]bool, len(opts.Serials))
+		for _, s := range opts.Serials {
+			serials[NormalizeSerial(s)] = true
+		}
 	}
 	return &Parser{
 		domainFilter:      lower,
 		domainFilterBytes: lowerBytes,
+		prefilter:         prefilter,
+		ipFilters:         ipFilters,
+		serials:           serials,
+		brands:            brands,
+		opts:              opts,
+	}
+}
+
+// NormalizeSerial puts a hex serial number in the form CertResult.Serial
+// uses: lowercase, without a 0x prefix, colons, spaces or leading zeros,
+// so "00:A1:B2" and "a1b2" compare equal.
+func NormalizeSerial(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.NewReplacer(":", "", " ", "").Replace(s)
+	if trimmed := strings.TrimLeft(s, "0"); trimmed != "" || s == "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// parseIPFilter accepts an address (2001:db8::1) or CIDR block
+// (10.0.0.0/8) in any valid notation.
+func parseIPFilter(s string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(s); err == nil && addr.Zone() == "" {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), true
 	}
+	return netip.Prefix{}, false
 }
 
 func (p *Parser) ParseEntry(entry ctlog.RawEntry, index int64, logURL string) (*ctlog.CertResult, error) {
@@ -36,11 +182,18 @@ func (p *Parser) ParseEntry(entry ctlog.RawEntry, index int64, logURL string) (*
 		return nil, fmt.Errorf("decoding leaf_input: %w", err)
 	}
 
-	if len(p.domainFilter) > 0 && !p.rawBytesMatchDomain(leafBytes) {
+	if p.skipEntryType(leafBytes) {
+		return nil, nil
+	}
+	if p.opts.StrictTimestamps && !plausibleTimestamp(leafBytes) {
+		p.badTimestamp.Add(1)
+		return nil, nil
+	}
+	if p.prefilter && !p.rawBytesMatchDomain(leafBytes) {
 		return nil, nil
 	}
 
-	certInfo, err := p.parseMerkleTreeLeaf(leafBytes)
+	certInfo, err := p.parseMerkleTreeLeaf(leafBytes, entry.ExtraData)
 	if err != nil {
 		return nil, err
 	}
@@ -48,17 +201,131 @@ func (p *Parser) ParseEntry(entry ctlog.RawEntry, index int64, logURL string) (*
 	if certInfo == nil || certInfo.Cert == nil {
 		return nil, nil
 	}
+	if !p.opts.MinNotBefore.IsZero() && certInfo.Cert.NotBefore.Before(p.opts.MinNotBefore) {
+		p.tooOld.Add(1)
+		return nil, nil
+	}
 	certInfo.Index = index
 
 	result := p.buildResult(certInfo, logURL)
+	if p.opts.LinkPrecerts {
+		result.TBSHash = tbsFingerprint(certInfo.LoggedTBS())
+	}
+	if p.opts.IncludeRaw {
+		if certInfo.IsPrecert {
+			result.PrecertTBS = certInfo.LoggedTBS()
+		} else {
+			result.DER = certInfo.Cert.Raw
+		}
+	}
+
+	if len(p.domainFilter) > 0 {
+		filter, domain, ok := p.resultMatchesDomain(result)
+		if !ok && p.brands != nil {
+			filter, domain, ok = p.brandEmbedded(result)
+			result.BrandEmbedded = ok
+		}
+		if !ok {
+			return nil, nil
+		}
+		result.MatchedName = domain
+		if p.opts.ShowMatch {
+			result.MatchedFilter, result.MatchedDomain = filter, domain
+		}
+		if result.CNOnly && domain != strings.ToLower(result.CommonName) {
+			result.CNOnly = false
+		}
+	}
 
-	if len(p.domainFilter) > 0 && !p.resultMatchesDomain(result) {
+	if !p.matchesFilters(result) {
 		return nil, nil
 	}
 
 	return result, nil
 }
 
+// SkippedByNotBefore reports how many certificates Options.MinNotBefore
+// has dropped so far.
+func (p *Parser) SkippedByNotBefore() int64 {
+	return p.tooOld.Load()
+}
+
+// SkippedByTimestamp reports how many entries Options.StrictTimestamps
+// has dropped so far.
+func (p *Parser) SkippedByTimestamp() int64 {
+	return p.badTimestamp.Load()
+}
+
+// plausibleTimestamp checks the leaf timestamp against the CT epoch and
+// the current time. Short leaves are left for parseMerkleTreeLeaf to
+// reject.
+func plausibleTimestamp(data []byte) bool {
+	if len(data) < 12 {
+		return true
+	}
+	ms := binary.BigEndian.Uint64(data[2:10])
+	return ms >= uint64(ctEpoch.UnixMilli()) && ms <= uint64(time.Now().Add(maxClockSkew).UnixMilli())
+}
+
+func (p *Parser) matchesFilters(result *ctlog.CertResult) bool {
+	if p.opts.CAOnly && !result.IsCA {
+		return false
+	}
+	if p.opts.ExcludeExpiredAtLog && result.ExpiredWhenLogged {
+		return false
+	}
+	if len(p.opts.EKU) > 0 && !containsAnyFold(result.ExtKeyUsage, p.opts.EKU) {
+		return false
+	}
+	if len(p.opts.ValidationLevels) > 0 && !containsAnyFold([]string{result.ValidationLevel}, p.opts.ValidationLevels) {
+		return false
+	}
+	if p.serials != nil && !p.serials[NormalizeSerial(result.Serial)] {
+		return false
+	}
+	if len(p.opts.Issuers) > 0 || len(p.opts.DenyIssuers) > 0 {
+		issuer := strings.ToLower(result.Issuer)
+		if len(p.opts.Issuers) > 0 && !containsAnySubstring(issuer, p.opts.Issuers) {
+			return false
+		}
+		if containsAnySubstring(issuer, p.opts.DenyIssuers) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAnySubstring(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(ss []string) []string {
+	if len(ss) == 0 {
+		return nil
+	}
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
+
+func containsAnyFold(values, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if strings.EqualFold(v, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (p *Parser) rawBytesMatchDomain(data []byte) bool {
 	for _, domainBytes := range p.domainFilterBytes {
 		if containsFoldASCII(data, domainBytes) {
@@ -137,8 +404,24 @@ func containsFoldASCII(data, pattern []byte) bool {
 	return false
 }
 
+// skipEntryType reports whether the leaf's LogEntryType is excluded by
+// Options.SkipX509 or SkipPrecerts. Short leaves are left for
+// parseMerkleTreeLeaf to reject.
+func (p *Parser) skipEntryType(data []byte) bool {
+	if len(data) < 12 || (!p.opts.SkipX509 && !p.opts.SkipPrecerts) {
+		return false
+	}
+	switch binary.BigEndian.Uint16(data[10:12]) {
+	case 0:
+		return p.opts.SkipX509
+	case 1:
+		return p.opts.SkipPrecerts
+	}
+	return false
+}
+
 // Version (1) | MerkleLeafType (1) | Timestamp (8) | LogEntryType (2) | Entry data...
-func (p *Parser) parseMerkleTreeLeaf(data []byte) (*ctlog.CertInfo, error) {
+func (p *Parser) parseMerkleTreeLeaf(data []byte, extraData string) (*ctlog.CertInfo, error) {
 	if len(data) < 12 {
 		return nil, fmt.Errorf("leaf data too short: %d bytes", len(data))
 	}
@@ -154,12 +437,30 @@ func (p *Parser) parseMerkleTreeLeaf(data []byte) (*ctlog.CertInfo, error) {
 	case 0: // x509_entry
 		return p.parseX509Entry(data[12:], ts)
 	case 1: // precert_entry
-		return p.parsePrecertEntry(data[12:], ts)
+		return p.parsePrecertEntry(data[12:], extraData, ts)
 	default:
 		return nil, fmt.Errorf("unknown entry type: %d", entryType)
 	}
 }
 
+// CertError is returned by ParseEntry, when Options.ReportCertErrors is
+// set, for a well-formed leaf whose certificate crypto/x509 rejects.
+type CertError struct {
+	Err error
+}
+
+func (e *CertError) Error() string { return "parsing certificate: " + e.Err.Error() }
+func (e *CertError) Unwrap() error { return e.Err }
+
+// certError drops unparseable certificates silently unless the caller
+// asked to see them.
+func (p *Parser) certError(err error) error {
+	if !p.opts.ReportCertErrors {
+		return nil
+	}
+	return &CertError{Err: err}
+}
+
 func (p *Parser) parseX509Entry(data []byte, timestamp time.Time) (*ctlog.CertInfo, error) {
 	if len(data) < 3 {
 		return nil, fmt.Errorf("x509 entry data too short")
@@ -174,7 +475,7 @@ func (p *Parser) parseX509Entry(data []byte, timestamp time.Time) (*ctlog.CertIn
 
 	cert, err := x509.ParseCertificate(data[:certLen])
 	if err != nil {
-		return nil, nil
+		return nil, p.certError(err)
 	}
 
 	return &ctlog.CertInfo{
@@ -184,12 +485,12 @@ func (p *Parser) parseX509Entry(data []byte, timestamp time.Time) (*ctlog.CertIn
 	}, nil
 }
 
-func (p *Parser) parsePrecertEntry(data []byte, timestamp time.Time) (*ctlog.CertInfo, error) {
+func (p *Parser) parsePrecertEntry(data []byte, extraData string, timestamp time.Time) (*ctlog.CertInfo, error) {
 	if len(data) < 32+3 {
 		return nil, fmt.Errorf("precert entry data too short")
 	}
 
-	// skip issuer_key_hash (32 bytes)
+	issuerKeyHash := hex.EncodeToString(data[:32])
 	data = data[32:]
 
 	tbsLen := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
@@ -198,42 +499,89 @@ func (p *Parser) parsePrecertEntry(data []byte, timestamp time.Time) (*ctlog.Cer
 	if len(data) < tbsLen {
 		return nil, fmt.Errorf("TBS certificate data truncated")
 	}
+	tbs := data[:tbsLen]
 
-	cert, err := x509.ParseCertificate(data[:tbsLen])
+	info := &ctlog.CertInfo{
+		IsPrecert:     true,
+		Timestamp:     timestamp,
+		IssuerKeyHash: issuerKeyHash,
+	}
+
+	// The leaf only holds the TBSCertificate, which crypto/x509 can't
+	// parse on its own; the complete precertificate is in extra_data.
+	precertDER, extraErr := precertFromExtraData(extraData)
+	if extraErr == nil {
+		cert, err := x509.ParseCertificate(precertDER)
+		if err == nil {
+			info.Cert = cert
+			info.TBS = tbs
+			return info, nil
+		}
+		extraErr = err
+	}
+
+	cert, err := x509.ParseCertificate(tbs)
 	if err != nil {
-		return nil, nil
+		if extraData != "" {
+			err = extraErr
+		}
+		return nil, p.certError(err)
 	}
+	info.Cert = cert
+	return info, nil
+}
 
-	return &ctlog.CertInfo{
-		Cert:      cert,
-		IsPrecert: true,
-		Timestamp: timestamp,
-	}, nil
+// precertFromExtraData returns the precertificate DER from a precert
+// entry's extra_data, a PrecertChainEntry (RFC 6962 section 3.1): the
+// precertificate followed by its issuing chain, each length-prefixed.
+func precertFromExtraData(extraData string) ([]byte, error) {
+	if extraData == "" {
+		return nil, fmt.Errorf("no extra_data")
+	}
+	data, err := base64.StdEncoding.DecodeString(extraData)
+	if err != nil {
+		return nil, fmt.Errorf("decoding extra_data: %w", err)
+	}
+	if len(data) < 3 {
+		return nil, fmt.Errorf("extra_data too short")
+	}
+	certLen := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+	if len(data)-3 < certLen {
+		return nil, fmt.Errorf("precertificate in extra_data truncated: need %d, have %d", certLen, len(data)-3)
+	}
+	return data[3 : 3+certLen], nil
 }
 
 func (p *Parser) buildResult(info *ctlog.CertInfo, logURL string) *ctlog.CertResult {
 	cert := info.Cert
 
 	domainSet := make(map[string]struct{})
-	if cert.Subject.CommonName != "" {
-		domainSet[strings.ToLower(cert.Subject.CommonName)] = struct{}{}
-	}
 	for _, name := range cert.DNSNames {
 		domainSet[strings.ToLower(name)] = struct{}{}
 	}
+	cn := strings.ToLower(cert.Subject.CommonName)
+	_, cnInSANs := domainSet[cn]
+	if cn != "" {
+		domainSet[cn] = struct{}{}
+	}
 
+	// Names are sorted so the same certificate always gives the same
+	// output; the domain set would otherwise come out in map order.
 	domains := make([]string, 0, len(domainSet))
 	for d := range domainSet {
 		domains = append(domains, d)
 	}
+	slices.Sort(domains)
 
 	ips := make([]string, 0, len(cert.IPAddresses))
 	for _, ip := range cert.IPAddresses {
 		ips = append(ips, ip.String())
 	}
+	slices.Sort(ips)
 
 	emails := make([]string, 0, len(cert.EmailAddresses))
 	emails = append(emails, cert.EmailAddresses...)
+	slices.Sort(emails)
 
 	issuer := cert.Issuer.CommonName
 	if issuer == "" && len(cert.Issuer.Organization) > 0 {
@@ -244,39 +592,94 @@ func (p *Parser) buildResult(info *ctlog.CertInfo, logURL string) *ctlog.CertRes
 	if cert.SerialNumber != nil {
 		serial = fmt.Sprintf("%x", cert.SerialNumber)
 	}
+	policies := policyOIDs(cert)
 
 	return &ctlog.CertResult{
-		Index:      info.Index,
-		Timestamp:  info.Timestamp,
-		Domains:    domains,
-		IPs:        ips,
-		Emails:     emails,
-		CommonName: cert.Subject.CommonName,
-		Issuer:     issuer,
-		NotBefore:  cert.NotBefore,
-		NotAfter:   cert.NotAfter,
-		IsPrecert:  info.IsPrecert,
-		LogURL:     logURL,
-		Serial:     serial,
+		Index:         info.Index,
+		Timestamp:     info.Timestamp,
+		Domains:       domains,
+		IPs:           ips,
+		Emails:        emails,
+		CommonName:    cert.Subject.CommonName,
+		Issuer:        issuer,
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		IsPrecert:     info.IsPrecert,
+		IsCA:          cert.BasicConstraintsValid && cert.IsCA,
+		IssuerKeyHash: info.IssuerKeyHash,
+		KeyUsage:      keyUsageStrings(cert.KeyUsage),
+		ExtKeyUsage:   extKeyUsageStrings(cert),
+		OCSP:          cert.OCSPServer,
+		CRL:           cert.CRLDistributionPoints,
+		LogURL:        logURL,
+		Serial:        serial,
+
+		ExpiredWhenLogged: cert.NotAfter.Before(info.Timestamp),
+		SCTLogIDs:         sctLogIDs(cert),
+		CNOnly:            cn != "" && !cnInSANs,
+		Policies:          policies,
+		ValidationLevel:   validationLevel(policies),
 	}
 }
 
-func (p *Parser) resultMatchesDomain(result *ctlog.CertResult) bool {
+// MatchesDomain reports whether one of the result's names or IPs matches
+// a domain filter of p, honouring ExactMatch and SubdomainsOnly. Other
+// options are not applied.
+func (p *Parser) MatchesDomain(result *ctlog.CertResult) bool {
+	_, _, ok := p.resultMatchesDomain(result)
+	return ok
+}
+
+// resultMatchesDomain reports the first filter that matches one of the
+// result's domains or IPs, and the name it matched.
+func (p *Parser) resultMatchesDomain(result *ctlog.CertResult) (filter, domain string, ok bool) {
 	for _, domain := range result.Domains {
 		for _, filter := range p.domainFilter {
-			if matchesDomain(domain, filter) {
-				return true
+			var match bool
+			switch {
+			case p.opts.ExactMatch:
+				match = matchesDomainExact(domain, filter)
+			case p.opts.SubdomainsOnly:
+				match = domain != filter && matchesDomain(domain, filter)
+			default:
+				match = matchesDomain(domain, filter)
+			}
+			if match {
+				return filter, domain, true
 			}
 		}
 	}
 	for _, ip := range result.IPs {
-		for _, filter := range p.domainFilter {
-			if ip == filter {
-				return true
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			for _, filter := range p.domainFilter {
+				if ip == filter {
+					return filter, ip, true
+				}
+			}
+			continue
+		}
+		addr = addr.Unmap()
+		for _, filter := range p.ipFilters {
+			if filter.prefix.Contains(addr) {
+				return filter.name, ip, true
 			}
 		}
 	}
-	return false
+	return "", "", false
+}
+
+// brandEmbedded reports the first filter whose brand label appears inside
+// one of the result's domains, and that domain.
+func (p *Parser) brandEmbedded(result *ctlog.CertResult) (filter, domain string, ok bool) {
+	for _, domain := range result.Domains {
+		for i, brand := range p.brands {
+			if len(brand) > 0 && containsFoldASCII([]byte(domain), brand) {
+				return p.domainFilter[i], domain, true
+			}
+		}
+	}
+	return "", "", false
 }
 
 func matchesDomain(domain, filter string) bool {
@@ -297,3 +700,7 @@ func matchesDomain(domain, filter string) bool {
 
 	return false
 }
+
+func matchesDomainExact(domain, filter string) bool {
+	return strings.TrimPrefix(domain, "*.") == filter
+}
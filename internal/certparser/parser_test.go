@@ -7,10 +7,13 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"math/big"
 	"net"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -49,6 +52,50 @@ func TestMatchesDomain(t *testing.T) {
 	}
 }
 
+func TestResultMatchesDomain_ExactMatch(t *testing.T) {
+	p := NewWithOptions([]string{"example.com"}, Options{ExactMatch: true})
+
+	tests := []struct {
+		domains []string
+		want    bool
+	}{
+		{[]string{"example.com"}, true},
+		{[]string{"*.example.com"}, true},
+		{[]string{"sub.example.com"}, false},
+		{[]string{"*.sub.example.com"}, false},
+		{[]string{"sub.example.com", "example.com"}, true},
+	}
+
+	for _, tt := range tests {
+		_, _, got := p.resultMatchesDomain(&ctlog.CertResult{Domains: tt.domains})
+		if got != tt.want {
+			t.Errorf("resultMatchesDomain(%v) = %v, want %v", tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestResultMatchesDomain_SubdomainsOnly(t *testing.T) {
+	p := NewWithOptions([]string{"example.com"}, Options{SubdomainsOnly: true})
+
+	tests := []struct {
+		domains []string
+		want    bool
+	}{
+		{[]string{"example.com"}, false},
+		{[]string{"*.example.com"}, true},
+		{[]string{"sub.example.com"}, true},
+		{[]string{"notexample.com"}, false},
+		{[]string{"example.com", "www.example.com"}, true},
+	}
+
+	for _, tt := range tests {
+		_, _, got := p.resultMatchesDomain(&ctlog.CertResult{Domains: tt.domains})
+		if got != tt.want {
+			t.Errorf("resultMatchesDomain(%v) = %v, want %v", tt.domains, got, tt.want)
+		}
+	}
+}
+
 func TestNewParser_DomainNormalization(t *testing.T) {
 	p := New([]string{"Example.COM", ".sub.Example.COM"})
 
@@ -122,6 +169,49 @@ func TestContainsFoldASCII(t *testing.T) {
 	}
 }
 
+func TestNewParser_ShortFilterSkipsPrefilter(t *testing.T) {
+	if !New([]string{"example.com"}).prefilter {
+		t.Error("expected prefilter for a regular domain filter")
+	}
+	if New([]string{"example.com", "io"}).prefilter {
+		t.Error("expected prefilter to be skipped when any filter is short")
+	}
+	if New(nil).prefilter {
+		t.Error("expected no prefilter without domain filters")
+	}
+
+	certDER := makeTestCert(t, "app.example.io", []string{"app.example.io"}, nil, nil)
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, certDER)}
+	result, err := New([]string{"io"}).ParseEntry(entry, 0, "https://log.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		t.Error("expected short filter to still match after a full parse")
+	}
+}
+
+// BenchmarkParseEntry_ShortFilter compares scanning leaf bytes for a short
+// filter against parsing every entry; the scan almost always matches.
+func BenchmarkParseEntry_ShortFilter(b *testing.B) {
+	certDER := makeTestCert(b, "app.example.io", []string{"app.example.io", "www.example.io"}, nil, nil)
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(b, 0, certDER)}
+
+	for _, prefilter := range []bool{true, false} {
+		name := "always-parse"
+		if prefilter {
+			name = "prefilter"
+		}
+		b.Run(name, func(b *testing.B) {
+			p := New([]string{"io"})
+			p.prefilter = prefilter
+			for b.Loop() {
+				p.ParseEntry(entry, 0, "https://log.example.com")
+			}
+		})
+	}
+}
+
 func BenchmarkRawBytesMatchDomain(b *testing.B) {
 	p := New([]string{"example.com", "test.org", "mysite.io"})
 	data := []byte("some random DER data with CN=app.example.com embedded in it, plus extra padding bytes to simulate realistic leaf size")
@@ -131,7 +221,7 @@ func BenchmarkRawBytesMatchDomain(b *testing.B) {
 	}
 }
 
-func makeMerkleLeaf(t *testing.T, entryType uint16, certDER []byte) string {
+func makeMerkleLeaf(t testing.TB, entryType uint16, certDER []byte) string {
 	t.Helper()
 	var buf []byte
 	buf = append(buf, 0)
@@ -148,7 +238,7 @@ func makeMerkleLeaf(t *testing.T, entryType uint16, certDER []byte) string {
 		buf = append(buf, byte(certLen>>16), byte(certLen>>8), byte(certLen))
 		buf = append(buf, certDER...)
 	} else {
-		buf = append(buf, make([]byte, 32)...)
+		buf = append(buf, bytes.Repeat([]byte{0xab}, 32)...)
 		certLen := len(certDER)
 		buf = append(buf, byte(certLen>>16), byte(certLen>>8), byte(certLen))
 		buf = append(buf, certDER...)
@@ -156,13 +246,13 @@ func makeMerkleLeaf(t *testing.T, entryType uint16, certDER []byte) string {
 	return base64.StdEncoding.EncodeToString(buf)
 }
 
-func makeTestCert(t *testing.T, cn string, dnsNames []string, ips []net.IP, emails []string) []byte {
+func makeTestCert(t testing.TB, cn string, dnsNames []string, ips []net.IP, emails []string) []byte {
 	t.Helper()
-	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		t.Fatal(err)
-	}
-	tmpl := &x509.Certificate{
+	return signTestCert(t, testCertTemplate(cn, dnsNames, ips, emails))
+}
+
+func testCertTemplate(cn string, dnsNames []string, ips []net.IP, emails []string) *x509.Certificate {
+	return &x509.Certificate{
 		SerialNumber:   big.NewInt(12345),
 		Subject:        pkix.Name{CommonName: cn},
 		NotBefore:      time.Now().Add(-time.Hour),
@@ -175,6 +265,14 @@ func makeTestCert(t *testing.T, cn string, dnsNames []string, ips []net.IP, emai
 			Organization: []string{"Test Org"},
 		},
 	}
+}
+
+func signTestCert(t testing.TB, tmpl *x509.Certificate) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
 	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
 	if err != nil {
 		t.Fatal(err)
@@ -184,7 +282,7 @@ func makeTestCert(t *testing.T, cn string, dnsNames []string, ips []net.IP, emai
 
 func TestParseMerkleTreeLeaf_TooShort(t *testing.T) {
 	p := New(nil)
-	_, err := p.parseMerkleTreeLeaf([]byte{0, 0, 0})
+	_, err := p.parseMerkleTreeLeaf([]byte{0, 0, 0}, "")
 	if err == nil {
 		t.Fatal("expected error for short data")
 	}
@@ -194,7 +292,7 @@ func TestParseMerkleTreeLeaf_UnknownEntryType(t *testing.T) {
 	p := New(nil)
 	data := make([]byte, 12)
 	binary.BigEndian.PutUint16(data[10:], 99)
-	_, err := p.parseMerkleTreeLeaf(data)
+	_, err := p.parseMerkleTreeLeaf(data, "")
 	if err == nil || !strings.Contains(err.Error(), "unknown entry type") {
 		t.Fatalf("expected unknown entry type error, got: %v", err)
 	}
@@ -227,9 +325,23 @@ func TestParseX509Entry_InvalidDER(t *testing.T) {
 	}
 }
 
+func TestParseEntry_ReportCertErrors(t *testing.T) {
+	leaf := makeMerkleLeaf(t, 0, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF})
+
+	p := NewWithOptions(nil, Options{ReportCertErrors: true})
+	result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 3, "")
+	var certErr *CertError
+	if !errors.As(err, &certErr) {
+		t.Fatalf("ParseEntry() error = %v, want *CertError", err)
+	}
+	if result != nil {
+		t.Error("expected nil result for unparseable certificate")
+	}
+}
+
 func TestParsePrecertEntry_TooShort(t *testing.T) {
 	p := New(nil)
-	_, err := p.parsePrecertEntry(make([]byte, 10), time.Now())
+	_, err := p.parsePrecertEntry(make([]byte, 10), "", time.Now())
 	if err == nil {
 		t.Fatal("expected error for short precert entry")
 	}
@@ -268,6 +380,9 @@ func TestParseEntry_X509(t *testing.T) {
 	if len(result.Emails) != 1 || result.Emails[0] != "admin@example.com" {
 		t.Errorf("Emails = %v", result.Emails)
 	}
+	if result.IssuerKeyHash != "" {
+		t.Errorf("IssuerKeyHash = %q, want empty for x509 entry", result.IssuerKeyHash)
+	}
 }
 
 func TestParseEntry_Precert(t *testing.T) {
@@ -285,6 +400,44 @@ func TestParseEntry_Precert(t *testing.T) {
 	if !result.IsPrecert {
 		t.Error("expected IsPrecert = true")
 	}
+	if want := strings.Repeat("ab", 32); result.IssuerKeyHash != want {
+		t.Errorf("IssuerKeyHash = %q, want %q", result.IssuerKeyHash, want)
+	}
+}
+
+func TestParseEntry_EntryType(t *testing.T) {
+	der := makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil)
+	x509Leaf := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, der)}
+	precertLeaf := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 1, der)}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantX509    bool
+		wantPrecert bool
+	}{
+		{"both", Options{}, true, true},
+		{"x509 only", Options{SkipPrecerts: true}, true, false},
+		{"precert only", Options{SkipX509: true}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewWithOptions(nil, tt.opts)
+			for _, c := range []struct {
+				entry ctlog.RawEntry
+				want  bool
+			}{{x509Leaf, tt.wantX509}, {precertLeaf, tt.wantPrecert}} {
+				result, err := p.ParseEntry(c.entry, 0, "https://log.example.com/")
+				if err != nil {
+					t.Fatalf("ParseEntry error: %v", err)
+				}
+				if got := result != nil; got != c.want {
+					t.Errorf("got result = %v, want %v", got, c.want)
+				}
+			}
+		})
+	}
 }
 
 func TestParseEntry_InvalidBase64(t *testing.T) {
@@ -339,7 +492,7 @@ func TestResultMatchesDomain(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := p.resultMatchesDomain(tt.result)
+			_, _, got := p.resultMatchesDomain(tt.result)
 			if got != tt.want {
 				t.Errorf("resultMatchesDomain() = %v, want %v", got, tt.want)
 			}
@@ -347,6 +500,43 @@ func TestResultMatchesDomain(t *testing.T) {
 	}
 }
 
+func TestResultMatchesDomain_IPFilters(t *testing.T) {
+	p := New([]string{"2001:0db8:0000::1", "10.0.0.0/8", "192.0.2.7"})
+
+	tests := []struct {
+		ips        []string
+		wantFilter string
+		want       bool
+	}{
+		{[]string{"2001:db8::1"}, "2001:0db8:0000::1", true},
+		{[]string{"2001:db8::2"}, "", false},
+		{[]string{"10.20.30.40"}, "10.0.0.0/8", true},
+		{[]string{"11.0.0.1"}, "", false},
+		{[]string{"::ffff:192.0.2.7"}, "192.0.2.7", true},
+		{[]string{"203.0.113.1", "10.0.0.1"}, "10.0.0.0/8", true},
+	}
+
+	for _, tt := range tests {
+		filter, _, got := p.resultMatchesDomain(&ctlog.CertResult{IPs: tt.ips})
+		if got != tt.want || filter != tt.wantFilter {
+			t.Errorf("resultMatchesDomain(%v) = %q, %v, want %q, %v", tt.ips, filter, got, tt.wantFilter, tt.want)
+		}
+	}
+}
+
+func TestParseEntry_IPFilterSkipsPrefilter(t *testing.T) {
+	der := makeTestCert(t, "host", nil, []net.IP{net.ParseIP("10.1.2.3")}, nil)
+	leaf := makeMerkleLeaf(t, 0, der)
+
+	result, err := New([]string{"10.0.0.0/8"}).ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected the CIDR filter to match the IP SAN")
+	}
+}
+
 func TestBuildResult_IssuerFallback(t *testing.T) {
 	der := makeTestCert(t, "test.com", nil, nil, nil)
 	cert, _ := x509.ParseCertificate(der)
@@ -358,6 +548,28 @@ func TestBuildResult_IssuerFallback(t *testing.T) {
 	}
 }
 
+func TestBuildResult_SortedNames(t *testing.T) {
+	der := makeTestCert(t, "www.example.com",
+		[]string{"z.example.com", "A.example.com", "m.example.com"},
+		[]net.IP{net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.1")},
+		[]string{"b@example.com", "a@example.com"})
+	cert, _ := x509.ParseCertificate(der)
+	info := &ctlog.CertInfo{Cert: cert, Index: 1, Timestamp: time.Now()}
+
+	for range 5 {
+		result := New(nil).buildResult(info, "https://log/")
+		if want := []string{"a.example.com", "m.example.com", "www.example.com", "z.example.com"}; !slices.Equal(result.Domains, want) {
+			t.Fatalf("Domains = %v, want %v", result.Domains, want)
+		}
+		if want := []string{"192.0.2.1", "192.0.2.2"}; !slices.Equal(result.IPs, want) {
+			t.Fatalf("IPs = %v, want %v", result.IPs, want)
+		}
+		if want := []string{"a@example.com", "b@example.com"}; !slices.Equal(result.Emails, want) {
+			t.Fatalf("Emails = %v, want %v", result.Emails, want)
+		}
+	}
+}
+
 func TestContainsFoldASCII_WorstCase(t *testing.T) {
 	data := bytes.Repeat([]byte("A"), 1_000_000)
 
@@ -369,3 +581,571 @@ func TestContainsFoldASCII_WorstCase(t *testing.T) {
 		t.Errorf("expected false, got %v", got)
 	}
 }
+
+func TestParseEntry_CAOnly(t *testing.T) {
+	tmpl := testCertTemplate("Sub CA", []string{"ca.example.com"}, nil, nil)
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	caLeaf := makeMerkleLeaf(t, 0, signTestCert(t, tmpl))
+	leafLeaf := makeMerkleLeaf(t, 0, makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil))
+
+	p := NewWithOptions(nil, Options{CAOnly: true})
+
+	result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: caLeaf}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result == nil || !result.IsCA {
+		t.Fatalf("expected CA result with IsCA = true, got %+v", result)
+	}
+
+	result, err = p.ParseEntry(ctlog.RawEntry{LeafInput: leafLeaf}, 1, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != nil {
+		t.Error("expected leaf certificate to be filtered by -ca-only")
+	}
+}
+
+func TestParseEntry_ExpiredAtLog(t *testing.T) {
+	tmpl := testCertTemplate("old.example.com", []string{"old.example.com"}, nil, nil)
+	tmpl.NotBefore = time.Now().Add(-48 * time.Hour)
+	tmpl.NotAfter = time.Now().Add(-24 * time.Hour)
+	expiredLeaf := makeMerkleLeaf(t, 0, signTestCert(t, tmpl))
+	validLeaf := makeMerkleLeaf(t, 0, makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil))
+
+	result, err := New(nil).ParseEntry(ctlog.RawEntry{LeafInput: expiredLeaf}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result == nil || !result.ExpiredWhenLogged {
+		t.Fatalf("expected ExpiredWhenLogged = true, got %+v", result)
+	}
+
+	p := NewWithOptions(nil, Options{ExcludeExpiredAtLog: true})
+	result, err = p.ParseEntry(ctlog.RawEntry{LeafInput: expiredLeaf}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != nil {
+		t.Error("expected certificate expired at log time to be filtered")
+	}
+
+	result, err = p.ParseEntry(ctlog.RawEntry{LeafInput: validLeaf}, 1, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result == nil || result.ExpiredWhenLogged {
+		t.Fatalf("expected valid certificate to pass with ExpiredWhenLogged = false, got %+v", result)
+	}
+}
+
+func TestParseEntry_MinNotBefore(t *testing.T) {
+	tmpl := testCertTemplate("old.example.com", []string{"old.example.com"}, nil, nil)
+	tmpl.NotBefore = time.Now().Add(-30 * 24 * time.Hour)
+	oldLeaf := makeMerkleLeaf(t, 0, signTestCert(t, tmpl))
+	newLeaf := makeMerkleLeaf(t, 0, makeTestCert(t, "new.example.com", []string{"new.example.com"}, nil, nil))
+
+	p := NewWithOptions(nil, Options{MinNotBefore: time.Now().Add(-24 * time.Hour)})
+
+	result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: oldLeaf}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != nil {
+		t.Error("expected certificate issued before the cutoff to be filtered")
+	}
+
+	result, err = p.ParseEntry(ctlog.RawEntry{LeafInput: newLeaf}, 1, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result == nil {
+		t.Error("expected recently issued certificate to pass")
+	}
+
+	if got := p.SkippedByNotBefore(); got != 1 {
+		t.Errorf("SkippedByNotBefore() = %d, want 1", got)
+	}
+}
+
+func TestParseEntry_IncludeRaw(t *testing.T) {
+	der := makeTestCert(t, "example.com", []string{"example.com"}, nil, nil)
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, der)}
+
+	result, err := New(nil).ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if result.DER != nil {
+		t.Error("DER set without IncludeRaw")
+	}
+
+	result, err = NewWithOptions(nil, Options{IncludeRaw: true}).ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if !bytes.Equal(result.DER, der) {
+		t.Error("DER does not match the logged certificate")
+	}
+	if result.PrecertTBS != nil {
+		t.Error("PrecertTBS set for an x509 entry")
+	}
+}
+
+func TestParseEntry_ShowMatch(t *testing.T) {
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, makeTestCert(t, "app.example.com", []string{"app.example.com", "*.dev.example.org"}, nil, nil))}
+
+	result, err := New([]string{"example.org"}).ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if result.MatchedFilter != "" || result.MatchedDomain != "" {
+		t.Errorf("match fields set without ShowMatch: %q, %q", result.MatchedFilter, result.MatchedDomain)
+	}
+	if result.MatchedName != "*.dev.example.org" {
+		t.Errorf("MatchedName = %q, want *.dev.example.org", result.MatchedName)
+	}
+
+	p := NewWithOptions([]string{"example.org"}, Options{ShowMatch: true})
+	result, err = p.ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if result.MatchedFilter != "example.org" || result.MatchedDomain != "*.dev.example.org" {
+		t.Errorf("matched = %q on %q, want example.org on *.dev.example.org", result.MatchedFilter, result.MatchedDomain)
+	}
+}
+
+func TestParseEntry_EKUFilter(t *testing.T) {
+	tmpl := testCertTemplate("signer.example.com", []string{"signer.example.com"}, nil, nil)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	leaf := makeMerkleLeaf(t, 0, signTestCert(t, tmpl))
+
+	result, err := New(nil).ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if len(result.KeyUsage) != 1 || result.KeyUsage[0] != "digitalSignature" {
+		t.Errorf("KeyUsage = %v, want [digitalSignature]", result.KeyUsage)
+	}
+	if len(result.ExtKeyUsage) != 1 || result.ExtKeyUsage[0] != "codeSigning" {
+		t.Errorf("ExtKeyUsage = %v, want [codeSigning]", result.ExtKeyUsage)
+	}
+
+	match := NewWithOptions(nil, Options{EKU: []string{"CODESIGNING"}})
+	if result, _ := match.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, ""); result == nil {
+		t.Error("expected match for -eku codeSigning (case-insensitive)")
+	}
+
+	noMatch := NewWithOptions(nil, Options{EKU: []string{"serverAuth"}})
+	if result, _ := noMatch.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, ""); result != nil {
+		t.Error("expected no match for -eku serverAuth")
+	}
+
+	byOID := NewWithOptions(nil, Options{EKU: []string{"1.3.6.1.5.5.7.3.3"}})
+	if result, _ := byOID.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, ""); result == nil {
+		t.Error("expected match for -eku 1.3.6.1.5.5.7.3.3 (codeSigning by OID)")
+	}
+	noMatch = NewWithOptions(nil, Options{EKU: []string{"1.3.6.1.5.5.7.3.1"}})
+	if result, _ := noMatch.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, ""); result != nil {
+		t.Error("expected no match for -eku 1.3.6.1.5.5.7.3.1 (serverAuth by OID)")
+	}
+}
+
+func TestParseEntry_ValidationLevel(t *testing.T) {
+	tmpl := testCertTemplate("ev.example.com", []string{"ev.example.com"}, nil, nil)
+	for _, oid := range [][]uint64{{1, 3, 6, 1, 4, 1, 99999, 1}, {2, 23, 140, 1, 1}} {
+		policy, err := x509.OIDFromInts(oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.Policies = append(tmpl.Policies, policy)
+	}
+	evLeaf := makeMerkleLeaf(t, 0, signTestCert(t, tmpl))
+	plainLeaf := makeMerkleLeaf(t, 0, makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil))
+
+	result, err := New(nil).ParseEntry(ctlog.RawEntry{LeafInput: evLeaf}, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if result.ValidationLevel != "EV" {
+		t.Errorf("ValidationLevel = %q, want EV", result.ValidationLevel)
+	}
+	if !slices.Equal(result.Policies, []string{"1.3.6.1.4.1.99999.1", "2.23.140.1.1"}) {
+		t.Errorf("Policies = %v, want the raw OIDs", result.Policies)
+	}
+
+	p := NewWithOptions(nil, Options{ValidationLevels: []string{"ov", "ev"}})
+	if result, _ := p.ParseEntry(ctlog.RawEntry{LeafInput: evLeaf}, 0, ""); result == nil {
+		t.Error("expected match for -validation-level ov,ev")
+	}
+	if result, _ := p.ParseEntry(ctlog.RawEntry{LeafInput: plainLeaf}, 1, ""); result != nil {
+		t.Error("expected certificate without a CA/B Forum policy to be filtered")
+	}
+}
+
+func TestParseEntry_CNOnly(t *testing.T) {
+	legacy := makeMerkleLeaf(t, 0, makeTestCert(t, "legacy.example.com", []string{"www.example.com"}, nil, nil))
+	modern := makeMerkleLeaf(t, 0, makeTestCert(t, "WWW.example.com", []string{"www.example.com"}, nil, nil))
+
+	tests := []struct {
+		name    string
+		leaf    string
+		domains []string
+		want    bool
+	}{
+		{"CN missing from SANs", legacy, nil, true},
+		{"CN repeated in SANs", modern, nil, false},
+		{"filter matches the CN", legacy, []string{"legacy.example.com"}, true},
+		{"filter matches a SAN", legacy, []string{"www.example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := New(tt.domains).ParseEntry(ctlog.RawEntry{LeafInput: tt.leaf}, 0, "")
+			if err != nil || result == nil {
+				t.Fatalf("ParseEntry() = %v, %v", result, err)
+			}
+			if result.CNOnly != tt.want {
+				t.Errorf("CNOnly = %v, want %v", result.CNOnly, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKnownExtKeyUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"serverAuth", true},
+		{"clientauth", true},
+		{"codeSigning", true},
+		{"1.3.6.1.5.5.7.3.1", true},
+		{"bogus", false},
+		{"1..2", false},
+		{".1.2", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsKnownExtKeyUsage(tt.name); got != tt.want {
+			t.Errorf("IsKnownExtKeyUsage(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesFilters_Issuer(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		issuer string
+		want   bool
+	}{
+		{"no filters", Options{}, "Let's Encrypt R3", true},
+		{"allow match", Options{Issuers: []string{"let's encrypt"}}, "Let's Encrypt R3", true},
+		{"allow miss", Options{Issuers: []string{"DigiCert"}}, "Let's Encrypt R3", false},
+		{"deny match", Options{DenyIssuers: []string{"ENCRYPT"}}, "Let's Encrypt R3", false},
+		{"deny miss", Options{DenyIssuers: []string{"DigiCert"}}, "Let's Encrypt R3", true},
+		{"deny wins over allow", Options{Issuers: []string{"encrypt"}, DenyIssuers: []string{"r3"}}, "Let's Encrypt R3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewWithOptions(nil, tt.opts)
+			got := p.matchesFilters(&ctlog.CertResult{Issuer: tt.issuer})
+			if got != tt.want {
+				t.Errorf("matchesFilters(issuer=%q) = %v, want %v", tt.issuer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTBSFingerprint_PrecertMatchesFinal(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(ext pkix.Extension) *x509.Certificate {
+		tmpl := testCertTemplate("example.com", []string{"example.com"}, nil, nil)
+		tmpl.ExtraExtensions = []pkix.Extension{ext}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	precert := sign(pkix.Extension{Id: oidPoison, Critical: true, Value: []byte{0x05, 0x00}})
+	final := sign(pkix.Extension{Id: oidSCTList, Value: []byte{0x04, 0x02, 0x00, 0x00}})
+
+	fp := tbsFingerprint(precert.RawTBSCertificate)
+	if fp == "" {
+		t.Fatal("expected a fingerprint for the precert TBS")
+	}
+	if got := tbsFingerprint(final.RawTBSCertificate); got != fp {
+		t.Errorf("final fingerprint %s != precert fingerprint %s", got, fp)
+	}
+
+	other := signTestCert(t, testCertTemplate("other.com", []string{"other.com"}, nil, nil))
+	otherCert, _ := x509.ParseCertificate(other)
+	if tbsFingerprint(otherCert.RawTBSCertificate) == fp {
+		t.Error("unrelated certificate has the same fingerprint")
+	}
+	if tbsFingerprint([]byte("garbage")) != "" {
+		t.Error("expected empty fingerprint for invalid DER")
+	}
+}
+
+func TestParseEntry_RevocationURLs(t *testing.T) {
+	tmpl := testCertTemplate("example.com", []string{"example.com"}, nil, nil)
+	tmpl.OCSPServer = []string{"http://ocsp.example.com"}
+	tmpl.CRLDistributionPoints = []string{"http://crl.example.com/ca.crl"}
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, signTestCert(t, tmpl))}
+
+	result, err := New(nil).ParseEntry(entry, 0, "https://log.example.com")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if len(result.OCSP) != 1 || result.OCSP[0] != "http://ocsp.example.com" {
+		t.Errorf("OCSP = %v", result.OCSP)
+	}
+	if len(result.CRL) != 1 || result.CRL[0] != "http://crl.example.com/ca.crl" {
+		t.Errorf("CRL = %v", result.CRL)
+	}
+}
+
+// buildSCTList encodes one minimal SCT per log ID into a
+// SignedCertificateTimestampList.
+func buildSCTList(logIDs ...[]byte) []byte {
+	var body []byte
+	for _, id := range logIDs {
+		sct := append([]byte{0}, id...)
+		sct = append(sct, make([]byte, 8+2+4)...) // timestamp, extensions, signature
+		body = binary.BigEndian.AppendUint16(body, uint16(len(sct)))
+		body = append(body, sct...)
+	}
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(body))), body...)
+}
+
+func TestParseSCTList(t *testing.T) {
+	id1 := bytes.Repeat([]byte{1}, sctLogIDLen)
+	id2 := bytes.Repeat([]byte{2}, sctLogIDLen)
+	valid := buildSCTList(id1, id2)
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{"two SCTs", valid, []string{base64.StdEncoding.EncodeToString(id1), base64.StdEncoding.EncodeToString(id2)}},
+		{"empty list", []byte{0, 0}, nil},
+		{"truncated", valid[:len(valid)-5], nil},
+		{"SCT shorter than log ID", []byte{0, 4, 0, 2, 0, 1}, nil},
+		{"too short", []byte{0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSCTList(tt.data)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("parseSCTList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEntry_SCTLogIDs(t *testing.T) {
+	id := bytes.Repeat([]byte{7}, sctLogIDLen)
+	value, err := asn1.Marshal(buildSCTList(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := testCertTemplate("example.com", []string{"example.com"}, nil, nil)
+	tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	entry := ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, signTestCert(t, tmpl))}
+
+	result, err := New(nil).ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() = %v, %v", result, err)
+	}
+	if want := base64.StdEncoding.EncodeToString(id); len(result.SCTLogIDs) != 1 || result.SCTLogIDs[0] != want {
+		t.Errorf("SCTLogIDs = %v, want [%s]", result.SCTLogIDs, want)
+	}
+
+	tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: []byte{0x04, 0x01, 0xff}}}
+	entry = ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, signTestCert(t, tmpl))}
+	result, err = New(nil).ParseEntry(entry, 0, "")
+	if err != nil || result == nil {
+		t.Fatalf("ParseEntry() with malformed SCT list = %v, %v", result, err)
+	}
+	if len(result.SCTLogIDs) != 0 {
+		t.Errorf("SCTLogIDs = %v, want none for a malformed list", result.SCTLogIDs)
+	}
+}
+
+func TestParseEntry_StrictTimestamps(t *testing.T) {
+	der := makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil)
+	withTimestamp := func(ts time.Time) ctlog.RawEntry {
+		leaf, err := base64.StdEncoding.DecodeString(makeMerkleLeaf(t, 0, der))
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.BigEndian.PutUint64(leaf[2:10], uint64(ts.UnixMilli()))
+		return ctlog.RawEntry{LeafInput: base64.StdEncoding.EncodeToString(leaf)}
+	}
+
+	tests := []struct {
+		name string
+		ts   time.Time
+		want bool
+	}{
+		{"now", time.Now(), true},
+		{"2014", time.Date(2014, 5, 1, 0, 0, 0, 0, time.UTC), true},
+		{"before CT", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"far future", time.Now().Add(365 * 24 * time.Hour), false},
+	}
+
+	p := NewWithOptions(nil, Options{StrictTimestamps: true})
+	for _, tt := range tests {
+		result, err := p.ParseEntry(withTimestamp(tt.ts), 0, "")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got := result != nil; got != tt.want {
+			t.Errorf("%s: kept = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got := p.SkippedByTimestamp(); got != 2 {
+		t.Errorf("SkippedByTimestamp() = %d, want 2", got)
+	}
+
+	if result, _ := New(nil).ParseEntry(withTimestamp(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)), 0, ""); result == nil {
+		t.Error("expected implausible timestamp to pass without StrictTimestamps")
+	}
+}
+
+func TestParseEntry_PrecertFromExtraData(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(exts ...pkix.Extension) []byte {
+		tmpl := testCertTemplate("www.example.com", []string{"www.example.com", "api.example.com"}, nil, []string{"admin@example.com"})
+		tmpl.ExtraExtensions = exts
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	finalDER := sign()
+	final, err := x509.ParseCertificate(finalDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precertDER := sign(pkix.Extension{Id: oidPoison, Critical: true, Value: []byte{0x05, 0x00}})
+
+	// A real precert leaf carries only the TBSCertificate, without poison.
+	leaf := makeMerkleLeaf(t, 1, final.RawTBSCertificate)
+	chainEntry := append([]byte{byte(len(precertDER) >> 16), byte(len(precertDER) >> 8), byte(len(precertDER))}, precertDER...)
+	chainEntry = append(chainEntry, 0, 0, 0)
+	extra := base64.StdEncoding.EncodeToString(chainEntry)
+
+	p := NewWithOptions(nil, Options{IncludeRaw: true, LinkPrecerts: true})
+
+	if result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, ""); result != nil || err != nil {
+		t.Errorf("TBS-only leaf = %v, %v; want it skipped as unparseable", result, err)
+	}
+
+	result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: leaf, ExtraData: extra}, 0, "")
+	if err != nil {
+		t.Fatalf("ParseEntry error: %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected precert parsed from extra_data")
+	}
+	want, err := p.ParseEntry(ctlog.RawEntry{LeafInput: makeMerkleLeaf(t, 0, finalDER)}, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.IsPrecert {
+		t.Error("expected IsPrecert = true")
+	}
+	if !slices.Equal(result.Domains, want.Domains) || !slices.Equal(result.Emails, want.Emails) || result.Serial != want.Serial {
+		t.Errorf("precert fields = %v %v %s, want %v %v %s", result.Domains, result.Emails, result.Serial, want.Domains, want.Emails, want.Serial)
+	}
+	if !bytes.Equal(result.PrecertTBS, final.RawTBSCertificate) {
+		t.Error("PrecertTBS should be the TBSCertificate from the leaf")
+	}
+	if result.TBSHash != want.TBSHash {
+		t.Errorf("TBSHash = %s, want %s (same as the final certificate)", result.TBSHash, want.TBSHash)
+	}
+}
+
+func TestParseEntry_Serial(t *testing.T) {
+	// testCertTemplate uses serial 12345, 0x3039.
+	leaf := makeMerkleLeaf(t, 0, makeTestCert(t, "www.example.com", []string{"www.example.com"}, nil, nil))
+
+	tests := []struct {
+		serials []string
+		want    bool
+	}{
+		{[]string{"3039"}, true},
+		{[]string{"00:30:39"}, true},
+		{[]string{"0x3039"}, true},
+		{[]string{"dead", "30 39"}, true},
+		{[]string{"3038"}, false},
+	}
+	for _, tt := range tests {
+		result, err := NewWithOptions(nil, Options{Serials: tt.serials}).ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result != nil; got != tt.want {
+			t.Errorf("Serials %v: matched = %v, want %v", tt.serials, got, tt.want)
+		}
+	}
+}
+
+func TestParseEntry_BrandMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		brandMatch   bool
+		wantMatch    bool
+		wantEmbedded bool
+	}{
+		{"www.example.com", true, true, false},
+		{"example-com-login.evil.net", false, false, false},
+		{"example-com-login.evil.net", true, true, true},
+		{"login.MyExample.net", true, true, true},
+		{"exampl.evil.net", true, false, false},
+	}
+	for _, tt := range tests {
+		leaf := makeMerkleLeaf(t, 0, makeTestCert(t, tt.name, []string{tt.name}, nil, nil))
+		p := NewWithOptions([]string{"example.com"}, Options{BrandMatch: tt.brandMatch, ShowMatch: true})
+		result, err := p.ParseEntry(ctlog.RawEntry{LeafInput: leaf}, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result != nil; got != tt.wantMatch {
+			t.Errorf("%s (brand match %v): matched = %v, want %v", tt.name, tt.brandMatch, got, tt.wantMatch)
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		if result.BrandEmbedded != tt.wantEmbedded {
+			t.Errorf("%s: BrandEmbedded = %v, want %v", tt.name, result.BrandEmbedded, tt.wantEmbedded)
+		}
+		if result.MatchedFilter != "example.com" {
+			t.Errorf("%s: MatchedFilter = %q, want example.com", tt.name, result.MatchedFilter)
+		}
+	}
+}
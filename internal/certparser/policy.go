@@ -0,0 +1,51 @@
+package certparser
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+// cabfLevels maps the CA/Browser Forum reserved policy OIDs to the
+// validation level they assert, strongest first.
+var cabfLevels = []struct {
+	oid   string
+	level string
+}{
+	{"2.23.140.1.1", "EV"},
+	{"2.23.140.1.2.2", "OV"},
+	{"2.23.140.1.2.3", "IV"},
+	{"2.23.140.1.2.1", "DV"},
+}
+
+// IsKnownValidationLevel reports whether name is a level understood by
+// the -validation-level filter (case-insensitive).
+func IsKnownValidationLevel(name string) bool {
+	for _, l := range cabfLevels {
+		if strings.EqualFold(l.level, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyOIDs returns the certificate policy OIDs in dotted form.
+func policyOIDs(cert *x509.Certificate) []string {
+	out := make([]string, 0, len(cert.Policies))
+	for _, oid := range cert.Policies {
+		out = append(out, oid.String())
+	}
+	return out
+}
+
+// validationLevel picks the strongest CABF level among policies, or ""
+// when the certificate asserts none.
+func validationLevel(policies []string) string {
+	for _, l := range cabfLevels {
+		for _, p := range policies {
+			if p == l.oid {
+				return l.level
+			}
+		}
+	}
+	return ""
+}
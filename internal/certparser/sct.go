@@ -0,0 +1,52 @@
+package certparser
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// sctLogIDLen is the size of an SCT log ID, the SHA-256 of the log's key.
+const sctLogIDLen = 32
+
+// sctLogIDs returns the base64 log IDs of the SCTs embedded in cert, in
+// the order they appear. A missing or malformed SCT list yields nil.
+func sctLogIDs(cert *x509.Certificate) []string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil
+		}
+		return parseSCTList(list)
+	}
+	return nil
+}
+
+// parseSCTList walks a TLS-encoded SignedCertificateTimestampList (RFC 6962
+// section 3.3): a uint16-prefixed list of uint16-prefixed SCTs, each
+// starting with a version byte and the log ID.
+func parseSCTList(data []byte) []string {
+	if len(data) < 2 || int(binary.BigEndian.Uint16(data)) != len(data)-2 {
+		return nil
+	}
+	data = data[2:]
+
+	var ids []string
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if n > len(data) || n < 1+sctLogIDLen {
+			return nil
+		}
+		ids = append(ids, base64.StdEncoding.EncodeToString(data[1:1+sctLogIDLen]))
+		data = data[n:]
+	}
+	return ids
+}
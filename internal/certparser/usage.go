@@ -0,0 +1,126 @@
+package certparser
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+var keyUsageNames = []struct {
+	bit  x509.KeyUsage
+	name string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCode",
+}
+
+// extKeyUsageOIDs maps the OIDs of the EKUs crypto/x509 knows to the names
+// results carry, so -eku 1.3.6.1.5.5.7.3.1 matches serverAuth.
+var extKeyUsageOIDs = map[string]string{
+	"2.5.29.37.0":            "any",
+	"1.3.6.1.5.5.7.3.1":      "serverAuth",
+	"1.3.6.1.5.5.7.3.2":      "clientAuth",
+	"1.3.6.1.5.5.7.3.3":      "codeSigning",
+	"1.3.6.1.5.5.7.3.4":      "emailProtection",
+	"1.3.6.1.5.5.7.3.5":      "ipsecEndSystem",
+	"1.3.6.1.5.5.7.3.6":      "ipsecTunnel",
+	"1.3.6.1.5.5.7.3.7":      "ipsecUser",
+	"1.3.6.1.5.5.7.3.8":      "timeStamping",
+	"1.3.6.1.5.5.7.3.9":      "OCSPSigning",
+	"1.3.6.1.4.1.311.10.3.3": "msSGC",
+	"2.16.840.1.113730.4.1":  "nsSGC",
+	"1.3.6.1.4.1.311.2.1.22": "msCodeCom",
+	"1.3.6.1.4.1.311.61.1.1": "msKernelCode",
+}
+
+// extKeyUsageFilters rewrites the OIDs of known EKUs in an -eku list to
+// their names; unknown OIDs are compared as they are.
+func extKeyUsageFilters(ekus []string) []string {
+	if len(ekus) == 0 {
+		return nil
+	}
+	out := make([]string, len(ekus))
+	for i, eku := range ekus {
+		if name, ok := extKeyUsageOIDs[eku]; ok {
+			eku = name
+		}
+		out[i] = eku
+	}
+	return out
+}
+
+// IsKnownExtKeyUsage reports whether name is an EKU purpose understood by
+// the -eku filter, either by name (case-insensitive) or as a dotted OID.
+func IsKnownExtKeyUsage(name string) bool {
+	for _, n := range extKeyUsageNames {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return isDottedOID(name)
+}
+
+func keyUsageStrings(ku x509.KeyUsage) []string {
+	var out []string
+	for _, u := range keyUsageNames {
+		if ku&u.bit != 0 {
+			out = append(out, u.name)
+		}
+	}
+	return out
+}
+
+func extKeyUsageStrings(cert *x509.Certificate) []string {
+	out := make([]string, 0, len(cert.ExtKeyUsage)+len(cert.UnknownExtKeyUsage))
+	for _, eku := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[eku]; ok {
+			out = append(out, name)
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		out = append(out, oid.String())
+	}
+	return out
+}
+
+func isDottedOID(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' || !strings.Contains(s, ".") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '.' {
+			if s[i-1] == '.' {
+				return false
+			}
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
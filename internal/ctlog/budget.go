@@ -0,0 +1,42 @@
+package ctlog
+
+import "sync/atomic"
+
+// RetryBudget caps the total number of retries across every client that
+// shares it. Once spent, failed requests return immediately.
+type RetryBudget struct {
+	limit     int64
+	used      atomic.Int64
+	exhausted atomic.Bool
+	onExhaust func()
+}
+
+func NewRetryBudget(limit int64) *RetryBudget {
+	return &RetryBudget{limit: limit}
+}
+
+// SetOnExhausted registers fn to be called once, the first time a retry is
+// refused.
+func (b *RetryBudget) SetOnExhausted(fn func()) {
+	b.onExhaust = fn
+}
+
+// Take reserves one retry and reports whether it was available.
+func (b *RetryBudget) Take() bool {
+	if b.used.Add(1) <= b.limit {
+		return true
+	}
+	b.used.Add(-1)
+	if b.exhausted.CompareAndSwap(false, true) && b.onExhaust != nil {
+		b.onExhaust()
+	}
+	return false
+}
+
+func (b *RetryBudget) Used() int64 {
+	return b.used.Load()
+}
+
+func (b *RetryBudget) Exhausted() bool {
+	return b.exhausted.Load()
+}
@@ -1,19 +1,64 @@
 package ctlog
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	retries    int
+	baseURL     string
+	httpClient  *http.Client
+	retries     int
+	retryBudget *RetryBudget
+	requestLog  func(format string, args ...any)
+	authToken   string
+	linkPages   int
+	limiter     *RequestLimiter
+
+	// validators holds the ETag/Last-Modified of the last response for
+	// each URL fetched conditionally; only get-sth is, as its answer is
+	// often unchanged between monitor polls.
+	cacheMu    sync.Mutex
+	validators map[string]*validator
+	lastSTH    *STH
+
+	// entriesStyle is the get-entries style in use once one is pinned by
+	// SetEntriesStyle (styleFixed) or found by falling back after a 405.
+	entriesStyle atomic.Pointer[EntriesStyle]
+	styleFixed   bool
+	// rejectedStyles holds the names of styles the log refused with a 4xx;
+	// later fallbacks do not probe them again.
+	rejectedStyles sync.Map
+}
+
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+// errNotModified is returned by doRequest when a conditional request gets
+// a 304; it is never retried.
+var errNotModified = errors.New("not modified")
+
+// ErrNonCompliant marks a 200 response that is not a valid RFC 6962
+// reply, e.g. an HTML error page from a proxy or a JSON body missing the
+// fields every log must send.
+var ErrNonCompliant = errors.New("non-CT-compliant response")
+
+// nonCompliant wraps ErrNonCompliant with what was wrong and where.
+func nonCompliant(url, format string, args ...any) error {
+	return fmt.Errorf("%w from %s: %s", ErrNonCompliant, url, fmt.Sprintf(format, args...))
 }
 
 func NewClient(baseURL string, timeout time.Duration, retries int) *Client {
@@ -31,45 +76,224 @@ func NewClient(baseURL string, timeout time.Duration, retries int) *Client {
 				TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
 			},
 		},
-		retries: retries,
+		retries:    retries,
+		validators: map[string]*validator{baseURL + "ct/v1/get-sth": {}},
+	}
+}
+
+// URL returns the log URL the client was created for, with a trailing
+// slash.
+func (c *Client) URL() string {
+	return c.baseURL
+}
+
+// SetMinTLSVersion sets the lowest TLS version the client will negotiate
+// (tls.VersionTLS12 by default).
+func (c *Client) SetMinTLSVersion(v uint16) {
+	c.httpClient.Transport.(*http.Transport).TLSClientConfig.MinVersion = v
+}
+
+// DisableKeepAlives makes every request open a new connection, for
+// proxies that stall on long-lived ones.
+func (c *Client) DisableKeepAlives() {
+	c.httpClient.Transport.(*http.Transport).DisableKeepAlives = true
+}
+
+// IsTLSVersionError reports whether err came from a server that only
+// speaks TLS versions below the configured minimum.
+func IsTLSVersionError(err error) bool {
+	if err == nil {
+		return false
 	}
+	// crypto/tls doesn't export the alert type, so match on the message for
+	// both the server-side alert and a locally rejected ServerHello.
+	msg := err.Error()
+	return strings.Contains(msg, "tls: protocol version not supported") ||
+		strings.Contains(msg, "tls: server selected unsupported protocol version")
+}
+
+// SetRetryBudget shares a run-wide retry cap with this client.
+func (c *Client) SetRetryBudget(b *RetryBudget) {
+	c.retryBudget = b
+}
+
+// SetRequestLog logs every HTTP request with its status, duration and
+// response size.
+func (c *Client) SetRequestLog(fn func(format string, args ...any)) {
+	c.requestLog = fn
+}
+
+// SetLinkPages makes GetRawEntries follow up to n Link rel="next" headers
+// per request and append those pages to the response. Paginated logs
+// otherwise return only their first page of a range.
+func (c *Client) SetLinkPages(n int) {
+	c.linkPages = n
+}
+
+// SetAuthToken sends "Authorization: Bearer <token>" with every request,
+// for private logs behind an authenticating proxy. The token is never
+// passed to the request log.
+func (c *Client) SetAuthToken(token string) {
+	c.authToken = token
+}
+
+// SetRequestLimiter makes every request wait for a slot of l, the cap worker
+// pools apply to get-entries, so that monitor polls, -recent searches and
+// batch probes count against it too. A get-entries call made by a pool
+// holding a slot of l runs in that slot, link-page follow-ups included.
+func (c *Client) SetRequestLimiter(l *RequestLimiter) {
+	c.limiter = l
 }
 
 func (c *Client) GetSTH(ctx context.Context) (*STH, error) {
 	url := c.baseURL + "ct/v1/get-sth"
 
 	body, err := c.doRequestWithRetry(ctx, url)
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if errors.Is(err, errNotModified) && c.lastSTH != nil {
+		sth := *c.lastSTH
+		return &sth, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("get-sth: %w", err)
 	}
 
 	var sth STH
+	if err := checkJSONObject(url, body); err != nil {
+		*c.validators[url] = validator{}
+		return nil, fmt.Errorf("get-sth: %w", err)
+	}
 	if err := json.Unmarshal(body, &sth); err != nil {
+		*c.validators[url] = validator{}
 		return nil, fmt.Errorf("parsing STH: %w", err)
 	}
+	// An empty log still has a signed timestamp, so a zero tree size with
+	// no timestamp means the JSON held none of the STH fields.
+	if sth.TreeSize == 0 && sth.Timestamp == 0 {
+		*c.validators[url] = validator{}
+		return nil, fmt.Errorf("get-sth: %w", nonCompliant(url, "no tree_size or timestamp"))
+	}
+	c.lastSTH = &sth
 	return &sth, nil
 }
 
 func (c *Client) GetRawEntries(ctx context.Context, start, end int64) (*GetEntriesResponse, error) {
-	url := fmt.Sprintf("%sct/v1/get-entries?start=%d&end=%d", c.baseURL, start, end)
+	release, err := c.limiter.acquireFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get-entries [%d-%d]: %w", start, end, err)
+	}
+	defer release()
 
-	body, err := c.doRequestWithRetry(ctx, url)
+	style := c.currentEntriesStyle()
+	body, url, next, err := c.fetchEntries(ctx, style, start, end, false)
+	if err != nil && wrongStyle(err) && !c.styleFixed {
+		c.rejectedStyles.Store(style.Name, true)
+		for _, alt := range EntriesStyles {
+			if _, rejected := c.rejectedStyles.Load(alt.Name); rejected || alt.Name == style.Name {
+				continue
+			}
+			var altErr error
+			if body, url, next, altErr = c.fetchEntries(ctx, alt, start, end, true); altErr == nil {
+				c.entriesStyle.Store(&alt)
+				c.logRequest("%s rejected %s get-entries requests, switched to %s", c.baseURL, style.Name, alt.Name)
+				err = nil
+				break
+			}
+			if errors.Is(altErr, errStyleRejected) {
+				c.rejectedStyles.Store(alt.Name, true)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get-entries [%d-%d]: %w", start, end, err)
+	}
+
+	resp, err := decodeEntries(url, body, start)
 	if err != nil {
 		return nil, fmt.Errorf("get-entries [%d-%d]: %w", start, end, err)
 	}
+	if next != "" && c.linkPages > 0 {
+		if err := c.followNextLinks(ctx, resp, url, next, start, end); err != nil {
+			return nil, fmt.Errorf("get-entries [%d-%d]: %w", start, end, err)
+		}
+	}
+	return resp, nil
+}
 
+// decodeEntries parses one get-entries body whose first entry is index
+// first.
+func decodeEntries(url string, body []byte, first int64) (*GetEntriesResponse, error) {
 	var resp GetEntriesResponse
+	if err := checkJSONObject(url, body); err != nil {
+		return nil, err
+	}
 	if err := json.Unmarshal(body, &resp); err != nil {
-		return nil, fmt.Errorf("parsing entries [%d-%d]: %w", start, end, err)
+		return nil, fmt.Errorf("parsing entries: %w", err)
+	}
+	if resp.Entries == nil {
+		return nil, nonCompliant(url, "no entries array")
+	}
+	for i, e := range resp.Entries {
+		if e.LeafInput == "" {
+			return nil, nonCompliant(url, "entry %d has no leaf_input", first+int64(i))
+		}
 	}
 	return &resp, nil
 }
 
+// SetEntriesStyle pins the get-entries request style. Without it the
+// client starts with EntriesGET and, if the log answers 405 Method Not
+// Allowed, tries the other EntriesStyles and keeps the first that works.
+func (c *Client) SetEntriesStyle(style EntriesStyle) {
+	c.entriesStyle.Store(&style)
+	c.styleFixed = true
+}
+
+func (c *Client) currentEntriesStyle() EntriesStyle {
+	if s := c.entriesStyle.Load(); s != nil {
+		return *s
+	}
+	return EntriesGET
+}
+
+// fetchEntries sends a get-entries request in the given style, with
+// retries, and returns the body, the URL it was sent to and the target of
+// a Link rel="next" header, if any. A probe of a fallback style is not
+// retried when the log refuses it with a 4xx other than 429.
+func (c *Client) fetchEntries(ctx context.Context, style EntriesStyle, start, end int64, probe bool) ([]byte, string, string, error) {
+	var url, next string
+	body, err := c.withRetry(ctx, func() ([]byte, error) {
+		req, err := style.Build(ctx, c.baseURL, start, end)
+		if err != nil {
+			return nil, err
+		}
+		url = req.URL.String()
+		body, header, err := c.send(req, url)
+		next = nextLink(header)
+		if probe && clientError(err) {
+			err = fmt.Errorf("%w: %w", errStyleRejected, err)
+		}
+		return body, err
+	})
+	return body, url, next, err
+}
+
 func (c *Client) doRequestWithRetry(ctx context.Context, url string) ([]byte, error) {
+	return c.withRetry(ctx, func() ([]byte, error) { return c.doRequest(ctx, url) })
+}
+
+// withRetry runs do until it succeeds, backing off between attempts. A
+// 304, a 405 or a rejected style probe is returned straight away: none
+// changes on a retry.
+func (c *Client) withRetry(ctx context.Context, do func() ([]byte, error)) ([]byte, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= c.retries; attempt++ {
 		if attempt > 0 {
+			if c.retryBudget != nil && !c.retryBudget.Take() {
+				return nil, fmt.Errorf("retry budget exhausted: %w", lastErr)
+			}
 			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
 
 			if backoff > 30*time.Second {
@@ -82,9 +306,9 @@ func (c *Client) doRequestWithRetry(ctx context.Context, url string) ([]byte, er
 			}
 		}
 
-		body, err := c.doRequest(ctx, url)
-		if err == nil {
-			return body, nil
+		body, err := do()
+		if err == nil || errors.Is(err, errNotModified) || errors.Is(err, errStyleRejected) || wrongStyle(err) {
+			return body, err
 		}
 		lastErr = err
 	}
@@ -95,21 +319,94 @@ func (c *Client) doRequestWithRetry(ctx context.Context, url string) ([]byte, er
 const maxResponseSize = 64 << 20
 
 func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
+	release, err := c.limiter.acquireFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	body, _, err := c.send(req, url)
+	return body, err
+}
+
+// send performs req, applying and updating the conditional request
+// validators registered for url, and returns the response body and
+// headers.
+func (c *Client) send(req *http.Request, url string) ([]byte, http.Header, error) {
 	req.Header.Set("User-Agent", "ct-hulhu")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
 
+	c.cacheMu.Lock()
+	v, conditional := c.validators[url]
+	var cached validator
+	if conditional {
+		cached = *v
+	}
+	c.cacheMu.Unlock()
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	started := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		c.logRequest("%s %s -> %v (%v)", req.Method, url, err, time.Since(started).Round(time.Millisecond))
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != (validator{}) {
+		c.logRequest("%s %s -> %d (%v)", req.Method, url, resp.StatusCode, time.Since(started).Round(time.Millisecond))
+		return nil, nil, errNotModified
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
+		c.logRequest("%s %s -> %d (%v)", req.Method, url, resp.StatusCode, time.Since(started).Round(time.Millisecond))
+		return nil, nil, &HTTPError{StatusCode: resp.StatusCode, URL: url}
 	}
 
-	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	c.logRequest("%s %s -> %d (%v, %d bytes)", req.Method, url, resp.StatusCode, time.Since(started).Round(time.Millisecond), len(body))
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "text/html" {
+		// Typically a proxy or captive portal error page sent with a 200.
+		err = nonCompliant(url, "Content-Type %s", mediaType)
+	}
+	if err == nil && conditional {
+		c.cacheMu.Lock()
+		*v = validator{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+		c.cacheMu.Unlock()
+	}
+	return body, resp.Header, err
+}
+
+func (c *Client) logRequest(format string, args ...any) {
+	if c.requestLog != nil {
+		c.requestLog("http: "+format, args...)
+	}
+}
+
+// checkJSONObject rejects bodies that cannot be a CT API reply before they
+// are unmarshalled, so they are reported as such rather than as a JSON
+// syntax error.
+func checkJSONObject(url string, body []byte) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nonCompliant(url, "body is not a JSON object (starts with %q)", snippet(trimmed, 32))
+	}
+	return nil
+}
+
+func snippet(b []byte, n int) string {
+	if len(b) > n {
+		b = b[:n]
+	}
+	return string(b)
 }
@@ -2,8 +2,16 @@ package ctlog
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -27,6 +35,48 @@ func TestGetSTH(t *testing.T) {
 	}
 }
 
+func TestGetSTH_ETag(t *testing.T) {
+	var full, notModified atomic.Int32
+	var treeSize atomic.Int64
+	treeSize.Store(100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := treeSize.Load()
+		etag := fmt.Sprintf(`"%d"`, size)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"tree_size":%d,"timestamp":1700000000000}`, size)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	for range 3 {
+		sth, err := client.GetSTH(context.Background())
+		if err != nil {
+			t.Fatalf("GetSTH() error: %v", err)
+		}
+		if sth.TreeSize != 100 {
+			t.Errorf("TreeSize = %d, want 100", sth.TreeSize)
+		}
+	}
+	if full.Load() != 1 || notModified.Load() != 2 {
+		t.Errorf("got %d full and %d 304 responses, want 1 and 2", full.Load(), notModified.Load())
+	}
+
+	treeSize.Store(200)
+	sth, err := client.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("GetSTH() error: %v", err)
+	}
+	if sth.TreeSize != 200 {
+		t.Errorf("TreeSize after change = %d, want 200", sth.TreeSize)
+	}
+}
+
 func TestGetSTH_ServerError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -40,6 +90,58 @@ func TestGetSTH_ServerError(t *testing.T) {
 	}
 }
 
+func TestNonCompliantResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		entries     bool
+	}{
+		{"html error page", "text/html; charset=utf-8", `<html><body>Service Unavailable</body></html>`, false},
+		{"html sent as json", "application/json", `<!DOCTYPE html><html></html>`, false},
+		{"sth without fields", "application/json", `{"status":"ok"}`, false},
+		{"entries without array", "application/json", `{"status":"ok"}`, true},
+		{"entry without leaf", "application/json", `{"entries":[{"extra_data":""}]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			client := NewClient(srv.URL, 5*time.Second, 0)
+			var err error
+			if tt.entries {
+				_, err = client.GetRawEntries(context.Background(), 0, 1)
+			} else {
+				_, err = client.GetSTH(context.Background())
+			}
+			if !errors.Is(err, ErrNonCompliant) {
+				t.Errorf("error = %v, want ErrNonCompliant", err)
+			}
+		})
+	}
+}
+
+func TestGetSTH_EmptyLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tree_size":0,"timestamp":1700000000000,"sha256_root_hash":"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	sth, err := client.GetSTH(context.Background())
+	if err != nil {
+		t.Fatalf("GetSTH() error: %v", err)
+	}
+	if sth.TreeSize != 0 {
+		t.Errorf("TreeSize = %d, want 0", sth.TreeSize)
+	}
+}
+
 func TestGetRawEntries(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/ct/v1/get-entries" {
@@ -67,6 +169,122 @@ func TestGetRawEntries(t *testing.T) {
 	}
 }
 
+func TestGetRawEntries_LinkPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			w.Header().Set("Link", `</ct/v1/get-entries?page=2>; rel="next", </ct/v1/get-entries>; rel="first"`)
+		case "2":
+			w.Header().Set("Link", `<?page=3>; rel=next`)
+		case "3":
+			w.Header().Set("Link", `<https://elsewhere.example.com/page4>; rel="next"`)
+		default:
+			t.Errorf("unexpected page %q", page)
+		}
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""},{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	resp, err := client.GetRawEntries(context.Background(), 0, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 2 {
+		t.Errorf("without SetLinkPages got %d entries, want 2", len(resp.Entries))
+	}
+
+	client.SetLinkPages(1)
+	if resp, err = client.GetRawEntries(context.Background(), 0, 9); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 4 {
+		t.Errorf("with 1 link page got %d entries, want 4", len(resp.Entries))
+	}
+
+	client.SetLinkPages(10)
+	if resp, err = client.GetRawEntries(context.Background(), 0, 9); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 6 {
+		t.Errorf("got %d entries, want 6 (links to other hosts are not followed)", len(resp.Entries))
+	}
+	if resp, err = client.GetRawEntries(context.Background(), 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 3 {
+		t.Errorf("got %d entries for [0-2], want 3", len(resp.Entries))
+	}
+}
+
+func TestGetRawEntries_PostFallback(t *testing.T) {
+	var gets, posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			gets.Add(1)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		posts.Add(1)
+		if r.FormValue("start") != "0" || r.FormValue("end") != "1" {
+			t.Errorf("unexpected form: start=%s end=%s", r.FormValue("start"), r.FormValue("end"))
+		}
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 3)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetRawEntries(context.Background(), 0, 1); err != nil {
+			t.Fatalf("GetRawEntries() error: %v", err)
+		}
+	}
+	if gets.Load() != 1 {
+		t.Errorf("got %d GET requests, want 1 (405 is not retried and the POST style sticks)", gets.Load())
+	}
+	if posts.Load() != 2 {
+		t.Errorf("got %d POST requests, want 2", posts.Load())
+	}
+
+	pinned := NewClient(srv.URL, 5*time.Second, 0)
+	pinned.SetEntriesStyle(EntriesGET)
+	if _, err := pinned.GetRawEntries(context.Background(), 0, 1); err == nil {
+		t.Error("expected an error with the GET style pinned")
+	}
+}
+
+func TestGetRawEntries_FallbackRejected(t *testing.T) {
+	var gets, forms, jsons atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodPost:
+			gets.Add(1)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Header.Get("Content-Type") == "application/json":
+			jsons.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			forms.Add(1)
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 3)
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetRawEntries(context.Background(), 0, 1); err == nil {
+			t.Fatal("expected an error when every style is refused")
+		}
+	}
+	if gets.Load() != 3 {
+		t.Errorf("got %d GET requests, want 3", gets.Load())
+	}
+	if forms.Load() != 1 || jsons.Load() != 1 {
+		t.Errorf("got %d form and %d JSON POSTs, want each probed once without retries", forms.Load(), jsons.Load())
+	}
+}
+
 func TestDoRequestWithRetry(t *testing.T) {
 	attempts := 0
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -152,3 +370,179 @@ func TestDoRequest_ResponseSizeLimit(t *testing.T) {
 		t.Errorf("body length = %d, want 1024", len(body))
 	}
 }
+
+func TestRetryBudget_SharedAcrossRequests(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	budget := NewRetryBudget(1)
+	var exhausted int
+	budget.SetOnExhausted(func() { exhausted++ })
+
+	client := NewClient(srv.URL, 5*time.Second, 3)
+	client.SetRetryBudget(budget)
+
+	if _, err := client.GetSTH(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2 (initial + 1 budgeted retry)", attempts)
+	}
+
+	attempts = 0
+	if _, err := client.GetSTH(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts after budget spent = %d, want 1", attempts)
+	}
+	if !budget.Exhausted() || exhausted != 1 {
+		t.Errorf("Exhausted() = %v, callback calls = %d, want true and 1", budget.Exhausted(), exhausted)
+	}
+	if budget.Used() != 1 {
+		t.Errorf("Used() = %d, want 1", budget.Used())
+	}
+}
+
+func TestSetRequestLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ct/v1/get-sth" {
+			w.Write([]byte(`{"tree_size":1}`))
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	var lines []string
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.SetRequestLog(func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+	client.GetSTH(context.Background())
+	client.GetRawEntries(context.Background(), 0, 9)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 request log lines, got %d: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "/ct/v1/get-sth -> 200") || !strings.Contains(lines[0], "15 bytes") {
+		t.Errorf("unexpected get-sth log line: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "get-entries?start=0&end=9 -> 429") {
+		t.Errorf("unexpected get-entries log line: %q", lines[1])
+	}
+}
+
+func TestSetMinTLSVersion(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tree_size":1}`))
+	}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.SetMinTLSVersion(tls.VersionTLS13)
+	_, err := client.GetSTH(context.Background())
+	if err == nil {
+		t.Fatal("expected handshake failure against a TLS 1.2-only server")
+	}
+	if !IsTLSVersionError(err) {
+		t.Errorf("IsTLSVersionError(%v) = false, want true", err)
+	}
+	if IsTLSVersionError(fmt.Errorf("HTTP 500")) {
+		t.Error("IsTLSVersionError() = true for an unrelated error")
+	}
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tree_size":1}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.DisableKeepAlives()
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetSTH(context.Background()); err != nil {
+			t.Fatalf("GetSTH() error: %v", err)
+		}
+	}
+	if got := conns.Load(); got != 3 {
+		t.Errorf("server saw %d connections, want 3 (one per request)", got)
+	}
+}
+
+func TestSetAuthToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"tree_size":1}`))
+	}))
+	defer srv.Close()
+
+	var logged strings.Builder
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.SetRequestLog(func(format string, args ...any) { fmt.Fprintf(&logged, format+"\n", args...) })
+	if _, err := client.GetSTH(context.Background()); err == nil {
+		t.Fatal("expected 401 without a token")
+	}
+	client.SetAuthToken("s3cret")
+	if _, err := client.GetSTH(context.Background()); err != nil {
+		t.Fatalf("GetSTH() with token: %v", err)
+	}
+	if strings.Contains(logged.String(), "s3cret") {
+		t.Errorf("request log leaks the token: %q", logged.String())
+	}
+}
+
+func TestFindIndexAt(t *testing.T) {
+	// Entry i is logged at base + i minutes.
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var index int64
+		fmt.Sscan(r.URL.Query().Get("start"), &index)
+		leaf := make([]byte, 12)
+		binary.BigEndian.PutUint64(leaf[2:10], uint64(base.Add(time.Duration(index)*time.Minute).UnixMilli()))
+		fmt.Fprintf(w, `{"entries":[{"leaf_input":%q,"extra_data":""}]}`, base64.StdEncoding.EncodeToString(leaf))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	tests := []struct {
+		at   time.Time
+		want int64
+	}{
+		{base.Add(-time.Hour), 0},
+		{base.Add(500 * time.Minute), 500},
+		{base.Add(500*time.Minute + time.Second), 501},
+		{base.Add(2000 * time.Minute), 1000},
+	}
+	for _, tt := range tests {
+		got, err := client.FindIndexAt(context.Background(), 1000, tt.at)
+		if err != nil {
+			t.Fatalf("FindIndexAt(%s) error: %v", tt.at, err)
+		}
+		if got != tt.want {
+			t.Errorf("FindIndexAt(%s) = %d, want %d", tt.at, got, tt.want)
+		}
+	}
+	if n := requests.Load(); n > 4*11 {
+		t.Errorf("made %d requests, want at most %d", n, 4*11)
+	}
+}
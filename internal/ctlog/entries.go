@@ -0,0 +1,176 @@
+package ctlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EntriesStyle builds the get-entries request for [start, end]. Logs that
+// follow RFC 6962 take a GET with start and end as query parameters; the
+// other styles cover implementations that only accept a POST.
+type EntriesStyle struct {
+	Name  string
+	Build func(ctx context.Context, baseURL string, start, end int64) (*http.Request, error)
+}
+
+var (
+	// EntriesGET is the RFC 6962 request: GET ct/v1/get-entries?start=&end=.
+	EntriesGET = EntriesStyle{Name: "get", Build: func(ctx context.Context, baseURL string, start, end int64) (*http.Request, error) {
+		url := fmt.Sprintf("%sct/v1/get-entries?start=%d&end=%d", baseURL, start, end)
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}}
+	// EntriesPostForm sends start and end as a form-encoded POST body.
+	EntriesPostForm = EntriesStyle{Name: "post-form", Build: func(ctx context.Context, baseURL string, start, end int64) (*http.Request, error) {
+		body := fmt.Sprintf("start=%d&end=%d", start, end)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"ct/v1/get-entries", strings.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		return req, err
+	}}
+	// EntriesPostJSON sends {"start": N, "end": M} as a POST body.
+	EntriesPostJSON = EntriesStyle{Name: "post-json", Build: func(ctx context.Context, baseURL string, start, end int64) (*http.Request, error) {
+		body := fmt.Sprintf(`{"start":%d,"end":%d}`, start, end)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"ct/v1/get-entries", strings.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	}}
+)
+
+// EntriesStyles lists the known request styles in the order auto-detection
+// tries them.
+var EntriesStyles = []EntriesStyle{EntriesGET, EntriesPostForm, EntriesPostJSON}
+
+// LookupEntriesStyle finds a style by name.
+func LookupEntriesStyle(name string) (EntriesStyle, bool) {
+	for _, s := range EntriesStyles {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return EntriesStyle{}, false
+}
+
+// HTTPError is a non-200, non-304 response.
+type HTTPError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s", e.StatusCode, e.URL)
+}
+
+// wrongStyle reports whether err says the log doesn't take this kind of
+// request at all, as opposed to a transient or range error.
+func wrongStyle(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusMethodNotAllowed
+}
+
+// errStyleRejected marks a fallback style the log refused outright.
+var errStyleRejected = errors.New("request style rejected")
+
+// clientError reports whether err is a 4xx response other than 429 Too
+// Many Requests, which a retry cannot fix.
+func clientError(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode >= 400 && httpErr.StatusCode < 500 &&
+		httpErr.StatusCode != http.StatusTooManyRequests
+}
+
+// followNextLinks appends the pages behind Link rel="next" headers to
+// resp until it holds the whole range [start, end], the log stops
+// linking, or c.linkPages pages have been fetched. A short result is
+// fine: the worker asks for the rest of the range again.
+func (c *Client) followNextLinks(ctx context.Context, resp *GetEntriesResponse, pageURL, next string, start, end int64) error {
+	want := end - start + 1
+	for pages := 0; next != "" && int64(len(resp.Entries)) < want; pages++ {
+		if pages == c.linkPages {
+			c.logRequest("stopped following Link rel=next after %d pages from %s", pages, pageURL)
+			break
+		}
+		target, ok := c.resolveLink(pageURL, next)
+		if !ok {
+			c.logRequest("not following Link rel=next to %s: not on %s", next, c.baseURL)
+			break
+		}
+		body, err := c.withRetry(ctx, func() ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+			if err != nil {
+				return nil, err
+			}
+			body, header, err := c.send(req, target)
+			next = nextLink(header)
+			return body, err
+		})
+		if err != nil {
+			return err
+		}
+		page, err := decodeEntries(target, body, start+int64(len(resp.Entries)))
+		if err != nil {
+			return err
+		}
+		if len(page.Entries) == 0 {
+			break
+		}
+		resp.Entries = append(resp.Entries, page.Entries...)
+		pageURL = target
+	}
+	if int64(len(resp.Entries)) > want {
+		resp.Entries = resp.Entries[:want]
+	}
+	return nil
+}
+
+// resolveLink resolves a Link target against the page it came from. Only
+// targets on the log's own scheme and host are followed, so the auth
+// token is never sent elsewhere.
+func (c *Client) resolveLink(pageURL, target string) (string, bool) {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return "", false
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	resolved := page.ResolveReference(ref)
+	base, err := url.Parse(c.baseURL)
+	if err != nil || resolved.Scheme != base.Scheme || resolved.Host != base.Host {
+		return "", false
+	}
+	return resolved.String(), true
+}
+
+// nextLink returns the target of the first rel="next" link in the Link
+// headers of h (RFC 8288), or "".
+func nextLink(h http.Header) string {
+	for _, value := range h.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, _ := strings.Cut(link, ";")
+			target = strings.TrimSpace(target)
+			if len(target) < 2 || target[0] != '<' || target[len(target)-1] != '>' {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(rel), `"`)) {
+					if strings.EqualFold(r, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package ctlog
+
+import "context"
+
+// RequestLimiter caps how many requests are in flight across every worker
+// pool and client that shares it, on top of each pool's own worker limit.
+type RequestLimiter struct {
+	slots chan struct{}
+}
+
+func NewRequestLimiter(n int) *RequestLimiter {
+	return &RequestLimiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until a slot is free or ctx is done.
+func (l *RequestLimiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *RequestLimiter) Release() {
+	<-l.slots
+}
+
+type heldSlotKey struct{}
+
+// holding marks ctx as running inside a slot of l, so that requests made
+// under it don't wait for a second one.
+func (l *RequestLimiter) holding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, heldSlotKey{}, l)
+}
+
+// acquireFor takes a slot for a request made under ctx, unless ctx already
+// holds one of l. The returned release is a no-op in that case. A nil
+// limiter never blocks.
+func (l *RequestLimiter) acquireFor(ctx context.Context) (release func(), err error) {
+	if l == nil || ctx.Value(heldSlotKey{}) == l {
+		return func() {}, nil
+	}
+	if err := l.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	return l.Release, nil
+}
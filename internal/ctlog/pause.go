@@ -0,0 +1,56 @@
+package ctlog
+
+import (
+	"context"
+	"sync"
+)
+
+// PauseGate lets an operator temporarily stop workers from picking up new
+// batches without cancelling the context.
+type PauseGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+func NewPauseGate() *PauseGate {
+	return &PauseGate{}
+}
+
+// Toggle flips the paused state and returns true if the gate is now paused.
+func (g *PauseGate) Toggle() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.resume)
+		return false
+	}
+	g.paused = true
+	g.resume = make(chan struct{})
+	return true
+}
+
+func (g *PauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks while the gate is paused.
+func (g *PauseGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	if !g.paused {
+		g.mu.Unlock()
+		return nil
+	}
+	resume := g.resume
+	g.mu.Unlock()
+
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
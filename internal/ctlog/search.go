@@ -0,0 +1,54 @@
+package ctlog
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// LeafTimestamp returns the timestamp of a base64 MerkleTreeLeaf.
+func LeafTimestamp(leafInput string) (time.Time, error) {
+	data, err := base64.StdEncoding.DecodeString(leafInput)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding leaf: %w", err)
+	}
+	if len(data) < 10 {
+		return time.Time{}, fmt.Errorf("leaf data too short: %d bytes", len(data))
+	}
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(data[2:10]))), nil
+}
+
+// FindIndexAt binary-searches the first treeSize entries for the first one
+// logged at or after t, fetching one entry per step. Logs only roughly
+// order entries by timestamp (within their merge delay), so the result is
+// an approximate boundary, not an exact one. It returns treeSize when
+// every entry is older than t.
+func (c *Client) FindIndexAt(ctx context.Context, treeSize int64, t time.Time) (int64, error) {
+	lo, hi := int64(0), treeSize
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ts, err := c.entryTimestamp(ctx, mid)
+		if err != nil {
+			return 0, err
+		}
+		if ts.Before(t) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+func (c *Client) entryTimestamp(ctx context.Context, index int64) (time.Time, error) {
+	resp, err := c.GetRawEntries(ctx, index, index)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(resp.Entries) == 0 {
+		return time.Time{}, fmt.Errorf("get-entries [%d-%d]: no entries returned", index, index)
+	}
+	return LeafTimestamp(resp.Entries[0].LeafInput)
+}
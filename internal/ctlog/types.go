@@ -32,15 +32,75 @@ type CertResult struct {
 	NotBefore  time.Time `json:"not_before"`
 	NotAfter   time.Time `json:"not_after"`
 	IsPrecert  bool      `json:"is_precert"`
-	LogURL     string    `json:"log_url,omitempty"`
-	Serial     string    `json:"serial,omitempty"`
+	IsCA       bool      `json:"is_ca"`
+	// IssuerKeyHash is the SHA-256 of the issuing CA's public key, only
+	// present for precert entries.
+	IssuerKeyHash string   `json:"issuer_key_hash,omitempty"`
+	KeyUsage      []string `json:"key_usage,omitempty"`
+	ExtKeyUsage   []string `json:"ext_key_usage,omitempty"`
+	OCSP          []string `json:"ocsp,omitempty"`
+	CRL           []string `json:"crl,omitempty"`
+	LogURL        string   `json:"log_url,omitempty"`
+	Serial        string   `json:"serial,omitempty"`
+	// TBSHash fingerprints the TBSCertificate without CT extensions; it is
+	// shared by a precert and its final certificate.
+	TBSHash string `json:"tbs_hash,omitempty"`
+	// ExpiredWhenLogged is set when NotAfter precedes the log timestamp,
+	// typically a backdated test or junk submission.
+	ExpiredWhenLogged bool `json:"expired_when_logged,omitempty"`
+	// SCTLogIDs lists the base64 IDs of the logs whose SCTs are embedded in
+	// the certificate. Precertificates carry none.
+	SCTLogIDs []string `json:"sct_log_ids,omitempty"`
+	// Policies lists the certificate policy OIDs; ValidationLevel is the
+	// CA/Browser Forum level (DV, OV, IV or EV) they assert, if any.
+	Policies        []string `json:"policies,omitempty"`
+	ValidationLevel string   `json:"validation_level,omitempty"`
+	// DER is the leaf certificate as logged; PrecertTBS is the
+	// TBSCertificate of a precert entry. Only filled on request.
+	DER        []byte `json:"der,omitempty"`
+	PrecertTBS []byte `json:"precert_tbs,omitempty"`
+	// MatchedFilter and MatchedDomain name the -d filter that selected this
+	// result and the certificate name it matched, when requested.
+	MatchedFilter string `json:"matched_filter,omitempty"`
+	MatchedDomain string `json:"matched_domain,omitempty"`
+	// MatchedName is the certificate name that matched a -d filter. Unlike
+	// MatchedDomain it is always set and never serialized; it keys
+	// -max-per-domain.
+	MatchedName string `json:"-"`
+	// BrandEmbedded is set when the result only matched because a domain
+	// filter's brand label appears inside one of its names.
+	BrandEmbedded bool `json:"brand_embedded,omitempty"`
+	// CNOnly is set when the CommonName is not among the DNS SANs and, with
+	// a domain filter, it is the name that matched: a legacy certificate
+	// that relies on the CN.
+	CNOnly bool `json:"cn_only,omitempty"`
+}
+
+// EntryRef points at an entry in a CT log.
+type EntryRef struct {
+	LogURL string `json:"log_url"`
+	Index  int64  `json:"index"`
 }
 
 type CertInfo struct {
-	Cert      *x509.Certificate
-	IsPrecert bool
-	Index     int64
-	Timestamp time.Time
+	Cert          *x509.Certificate
+	IsPrecert     bool
+	Index         int64
+	Timestamp     time.Time
+	IssuerKeyHash string
+	// TBS is the TBSCertificate from a precert leaf when Cert was parsed
+	// from the precertificate in extra_data instead.
+	TBS []byte
+}
+
+// LoggedTBS returns the TBSCertificate as the log recorded it. For a
+// precertificate parsed from extra_data this is the leaf's copy, without
+// the poison extension.
+func (c *CertInfo) LoggedTBS() []byte {
+	if c.TBS != nil {
+		return c.TBS
+	}
+	return c.Cert.RawTBSCertificate
 }
 
 type ScrapeProgress struct {
@@ -49,4 +109,7 @@ type ScrapeProgress struct {
 	LastIndex   int64     `json:"last_index"`
 	EntriesDone int64     `json:"entries_done"`
 	LastUpdated time.Time `json:"last_updated"`
+	// Reverse marks state written by a newest-first scrape, where LastIndex
+	// is the lowest index reached.
+	Reverse bool `json:"reverse,omitempty"`
 }
@@ -14,24 +14,54 @@ type EntryBatch struct {
 }
 
 type WorkerPool struct {
-	client     *Client
-	batchSize  int
-	maxWorkers int
-	rateLimit  int
+	client         *Client
+	batchSize      int
+	maxWorkers     int
+	rateLimit      int
+	initialWorkers int
+	rampInterval   time.Duration
+	reverse        bool
+	targetRate     int
 
 	activeWorkers  atomic.Int32
 	errCount       atomic.Int32
 	successCount   atomic.Int32
 	droppedEntries atomic.Int64
+	fetchedEntries atomic.Int64
+	retiring       atomic.Int32
+	delay          atomic.Int64
+	droppedMu      sync.Mutex
+	droppedRanges  []Range
 	debugLog       func(format string, args ...any)
+	pause          *PauseGate
+	stallTimeout   time.Duration
+	stallLog       func(format string, args ...any)
+	limiter        *RequestLimiter
+	rangeLog       func(status string, rg Range)
 }
 
 func NewWorkerPool(client *Client, batchSize, maxWorkers, rateLimit int) *WorkerPool {
 	return &WorkerPool{
-		client:     client,
-		batchSize:  batchSize,
-		maxWorkers: maxWorkers,
-		rateLimit:  rateLimit,
+		client:         client,
+		batchSize:      batchSize,
+		maxWorkers:     maxWorkers,
+		rateLimit:      rateLimit,
+		initialWorkers: 1,
+		rampInterval:   500 * time.Millisecond,
+	}
+}
+
+// SetInitialWorkers sets how many workers start immediately before ramping
+// up towards maxWorkers.
+func (wp *WorkerPool) SetInitialWorkers(n int) {
+	wp.initialWorkers = max(1, n)
+}
+
+// SetRampInterval sets how often one more worker is added while the error
+// rate stays low.
+func (wp *WorkerPool) SetRampInterval(d time.Duration) {
+	if d > 0 {
+		wp.rampInterval = d
 	}
 }
 
@@ -39,10 +69,68 @@ func (wp *WorkerPool) SetDebugLog(fn func(format string, args ...any)) {
 	wp.debugLog = fn
 }
 
+// SetReverse makes FetchRange issue batches from the end of the range down
+// to the start. Entries within a batch stay in ascending order.
+func (wp *WorkerPool) SetReverse(reverse bool) {
+	wp.reverse = reverse
+}
+
+// SetTargetRate replaces the error-rate ramp with a controller that adds
+// and retires workers (up to maxWorkers) and paces requests to hold
+// roughly rate entries per second. 0 keeps the default ramp.
+func (wp *WorkerPool) SetTargetRate(rate int) {
+	wp.targetRate = rate
+}
+
+// SetStallTimeout arms a watchdog on every get-entries call: one that
+// hasn't returned after d is reported through warn and cancelled, so the
+// range is dropped instead of holding its worker forever.
+func (wp *WorkerPool) SetStallTimeout(d time.Duration, warn func(format string, args ...any)) {
+	wp.stallTimeout = d
+	wp.stallLog = warn
+}
+
+// SetRequestLimiter shares a cap on concurrent requests with other pools.
+// A slot is held for one get-entries call, including the client's retries.
+func (wp *WorkerPool) SetRequestLimiter(l *RequestLimiter) {
+	wp.limiter = l
+}
+
+// SetRangeLog registers fn to be called with "fetched" for every range a
+// get-entries call returned and "dropped" for every range given up on. It
+// is called from the workers concurrently.
+func (wp *WorkerPool) SetRangeLog(fn func(status string, rg Range)) {
+	wp.rangeLog = fn
+}
+
+func (wp *WorkerPool) SetPauseGate(g *PauseGate) {
+	wp.pause = g
+}
+
 func (wp *WorkerPool) DroppedEntries() int64 {
 	return wp.droppedEntries.Load()
 }
 
+// Range is an inclusive span of entry indices.
+type Range struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// DroppedRanges returns the spans given up on after retries, in the order
+// they failed.
+func (wp *WorkerPool) DroppedRanges() []Range {
+	wp.droppedMu.Lock()
+	defer wp.droppedMu.Unlock()
+	return append([]Range(nil), wp.droppedRanges...)
+}
+
+func (wp *WorkerPool) logRange(status string, rg Range) {
+	if wp.rangeLog != nil {
+		wp.rangeLog(status, rg)
+	}
+}
+
 func (wp *WorkerPool) debug(format string, args ...any) {
 	if wp.debugLog != nil {
 		wp.debugLog(format, args...)
@@ -56,23 +144,37 @@ type workItem struct {
 const maxItemRetries = 3
 
 func (wp *WorkerPool) FetchRange(ctx context.Context, start, end int64, results chan<- EntryBatch) error {
-	defer close(results)
-
 	if start >= end {
+		close(results)
+		return nil
+	}
+	return wp.fetchItems(ctx, wp.batches(start, end), results)
+}
+
+// FetchRanges fetches each of the given spans, e.g. ones another pool
+// dropped, and closes results when done.
+func (wp *WorkerPool) FetchRanges(ctx context.Context, ranges []Range, results chan<- EntryBatch) error {
+	var items []workItem
+	for _, r := range ranges {
+		items = append(items, wp.batches(r.Start, r.End+1)...)
+	}
+	if len(items) == 0 {
+		close(results)
 		return nil
 	}
+	return wp.fetchItems(ctx, items, results)
+}
+
+func (wp *WorkerPool) fetchItems(ctx context.Context, items []workItem, results chan<- EntryBatch) error {
+	defer close(results)
 
 	work := make(chan workItem, wp.maxWorkers*2)
 
 	go func() {
 		defer close(work)
-		for pos := start; pos < end; pos += int64(wp.batchSize) {
-			batchEnd := pos + int64(wp.batchSize) - 1
-			if batchEnd >= end {
-				batchEnd = end - 1
-			}
+		for _, item := range items {
 			select {
-			case work <- workItem{start: pos, end: batchEnd}:
+			case work <- item:
 			case <-ctx.Done():
 				return
 			}
@@ -88,7 +190,7 @@ func (wp *WorkerPool) FetchRange(ctx context.Context, start, end int64, results
 
 	var wg sync.WaitGroup
 
-	initialWorkers := min(1, wp.maxWorkers)
+	initialWorkers := min(wp.initialWorkers, wp.maxWorkers)
 
 	workersDone := make(chan struct{})
 	go func() {
@@ -100,9 +202,13 @@ func (wp *WorkerPool) FetchRange(ctx context.Context, start, end int64, results
 			wp.activeWorkers.Add(1)
 		}
 
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(wp.rampInterval)
 		defer ticker.Stop()
 
+		lastTick := time.Now()
+		var lastFetched int64
+		var lastErrors int32
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -113,8 +219,19 @@ func (wp *WorkerPool) FetchRange(ctx context.Context, start, end int64, results
 				successes := wp.successCount.Load()
 				errors := wp.errCount.Load()
 
+				if wp.targetRate > 0 {
+					fetched := wp.fetchedEntries.Load()
+					rate := float64(fetched-lastFetched) / time.Since(lastTick).Seconds()
+					if wp.steer(current, rate, errors-lastErrors) > 0 {
+						wg.Add(1)
+						go wp.worker(ctx, work, results, rateLimiter, &wg)
+						wp.activeWorkers.Add(1)
+					}
+					lastTick, lastFetched, lastErrors = time.Now(), fetched, errors
+				}
+
 				total := successes + errors
-				if current < wp.maxWorkers && total > 0 {
+				if wp.targetRate == 0 && current < wp.maxWorkers && total > 0 {
 					errorRate := float64(errors) / float64(total)
 					if errorRate < 0.1 {
 						wp.debug("ramping up: %d -> %d workers (error rate: %.1f%%)",
@@ -143,6 +260,62 @@ func (wp *WorkerPool) FetchRange(ctx context.Context, start, end int64, results
 	}
 }
 
+const (
+	minPaceDelay = 10 * time.Millisecond
+	maxPaceDelay = 5 * time.Second
+)
+
+// steer nudges the pool towards targetRate after one controller tick. It
+// returns +1 when a worker should be started; slowing down retires a
+// worker or, with a single worker left, grows the delay between requests.
+// Errors during the tick always count as a reason to slow down.
+func (wp *WorkerPool) steer(current int, rate float64, newErrors int32) int {
+	target := float64(wp.targetRate)
+	delay := time.Duration(wp.delay.Load())
+	live := current - int(wp.retiring.Load())
+
+	switch {
+	case newErrors > 0 || rate > target*1.1:
+		if live > 1 {
+			wp.retiring.Add(1)
+			wp.debug("target rate: retiring a worker (%.0f/%d entries/sec, %d new errors)", rate, wp.targetRate, newErrors)
+			return -1
+		}
+		delay = min(max(delay*3/2, minPaceDelay), maxPaceDelay)
+		wp.delay.Store(int64(delay))
+		wp.debug("target rate: pacing requests %v apart (%.0f/%d entries/sec)", delay, rate, wp.targetRate)
+	case rate < target*0.9:
+		if delay > 0 {
+			delay /= 2
+			if delay < minPaceDelay {
+				delay = 0
+			}
+			wp.delay.Store(int64(delay))
+			return 0
+		}
+		if live < wp.maxWorkers {
+			wp.debug("target rate: adding a worker (%.0f/%d entries/sec)", rate, wp.targetRate)
+			return 1
+		}
+	}
+	return 0
+}
+
+func (wp *WorkerPool) batches(start, end int64) []workItem {
+	size := int64(wp.batchSize)
+	items := make([]workItem, 0, (end-start+size-1)/size)
+	if wp.reverse {
+		for hi := end; hi > start; hi -= size {
+			items = append(items, workItem{start: max(start, hi-size), end: hi - 1})
+		}
+		return items
+	}
+	for pos := start; pos < end; pos += size {
+		items = append(items, workItem{start: pos, end: min(pos+size, end) - 1})
+	}
+	return items
+}
+
 func (wp *WorkerPool) worker(
 	ctx context.Context,
 	work <-chan workItem,
@@ -162,6 +335,20 @@ func (wp *WorkerPool) worker(
 		default:
 		}
 
+		if d := time.Duration(wp.delay.Load()); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if wp.pause != nil {
+			if err := wp.pause.Wait(ctx); err != nil {
+				return
+			}
+		}
+
 		if rateLimiter != nil {
 			select {
 			case <-rateLimiter:
@@ -171,6 +358,10 @@ func (wp *WorkerPool) worker(
 		}
 
 		wp.fetchWithRetry(ctx, item, results)
+
+		if n := wp.retiring.Load(); n > 0 && wp.retiring.CompareAndSwap(n, n-1) {
+			return
+		}
 	}
 }
 
@@ -184,11 +375,15 @@ func (wp *WorkerPool) fetchWithRetry(ctx context.Context, item workItem, results
 		default:
 		}
 
-		resp, err := wp.client.GetRawEntries(ctx, currentStart, item.end)
+		resp, err := wp.getEntries(ctx, currentStart, item.end)
 		if err != nil {
 			wp.errCount.Add(1)
 			dropped := item.end - currentStart + 1
 			wp.droppedEntries.Add(dropped)
+			wp.droppedMu.Lock()
+			wp.droppedRanges = append(wp.droppedRanges, Range{Start: currentStart, End: item.end})
+			wp.droppedMu.Unlock()
+			wp.logRange("dropped", Range{Start: currentStart, End: item.end})
 			wp.debug("batch [%d-%d] failed, dropping: %v", currentStart, item.end, err)
 			return
 		}
@@ -198,7 +393,9 @@ func (wp *WorkerPool) fetchWithRetry(ctx context.Context, item workItem, results
 		if len(resp.Entries) == 0 {
 			return
 		}
+		wp.fetchedEntries.Add(int64(len(resp.Entries)))
 
+		wp.logRange("fetched", Range{Start: currentStart, End: currentStart + int64(len(resp.Entries)) - 1})
 		wp.debug("batch [%d-%d] fetched %d entries", currentStart, currentStart+int64(len(resp.Entries))-1, len(resp.Entries))
 		select {
 		case results <- EntryBatch{StartIndex: currentStart, Entries: resp.Entries}:
@@ -210,6 +407,43 @@ func (wp *WorkerPool) fetchWithRetry(ctx context.Context, item workItem, results
 	}
 }
 
+// getEntries fetches [start, end] under the stall watchdog, if one is set.
+// Time spent waiting for a shared request slot doesn't count as a stall.
+func (wp *WorkerPool) getEntries(ctx context.Context, start, end int64) (*GetEntriesResponse, error) {
+	if wp.limiter != nil {
+		if err := wp.limiter.Acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer wp.limiter.Release()
+		ctx = wp.limiter.holding(ctx)
+	}
+	if wp.stallTimeout <= 0 {
+		return wp.client.GetRawEntries(ctx, start, end)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled atomic.Bool
+	watchdog := time.AfterFunc(wp.stallTimeout, func() {
+		stalled.Store(true)
+		if wp.stallLog != nil {
+			wp.stallLog("batch [%d-%d] stalled: no response after %v, cancelling", start, end, wp.stallTimeout)
+		}
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	resp, err := wp.client.GetRawEntries(ctx, start, end)
+	if err != nil && stalled.Load() {
+		return nil, fmt.Errorf("stalled after %v: %w", wp.stallTimeout, err)
+	}
+	return resp, err
+}
+
+func (wp *WorkerPool) RequestStats() (successes, errors int32) {
+	return wp.successCount.Load(), wp.errCount.Load()
+}
+
 func (wp *WorkerPool) ErrorInfo() string {
 	errors := wp.errCount.Load()
 	successes := wp.successCount.Load()
@@ -2,9 +2,13 @@ package ctlog
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -85,6 +89,108 @@ func TestFetchRange_Basic(t *testing.T) {
 	}
 }
 
+func TestRequestLimiter_SharedAcrossPools(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	limiter := NewRequestLimiter(2)
+	var wg sync.WaitGroup
+	for range 2 {
+		pool := NewWorkerPool(NewClient(srv.URL, 5*time.Second, 0), 1, 4, 0)
+		pool.SetInitialWorkers(4)
+		pool.SetRequestLimiter(limiter)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results := make(chan EntryBatch, 16)
+			if err := pool.FetchRange(context.Background(), 0, 8, results); err != nil {
+				t.Errorf("FetchRange error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak concurrent requests = %d, want at most 2", got)
+	}
+}
+
+func TestRequestLimiter_GetSTH(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"tree_size":1,"timestamp":1}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	limiter := NewRequestLimiter(1)
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.SetRequestLimiter(limiter)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetSTH(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetSTH() with no free slot = %v, want it to wait for one", err)
+	}
+	limiter.Release()
+}
+
+func TestRequestLimiter_GetRawEntries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	limiter := NewRequestLimiter(1)
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	client.SetRequestLimiter(limiter)
+
+	if err := limiter.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetRawEntries(ctx, 0, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetRawEntries() with no free slot = %v, want it to wait for one", err)
+	}
+	limiter.Release()
+	if n := requests.Load(); n != 0 {
+		t.Errorf("%d requests sent without a slot, want 0", n)
+	}
+
+	// A pool sharing the limiter with its client must not wait for a
+	// second slot inside the one it holds.
+	pool := NewWorkerPool(client, 1, 1, 0)
+	pool.SetRequestLimiter(limiter)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := make(chan EntryBatch, 4)
+	if err := pool.FetchRange(ctx, 0, 2, results); err != nil {
+		t.Fatalf("FetchRange error: %v", err)
+	}
+	if n := len(results); n != 2 {
+		t.Errorf("got %d batches, want 2", n)
+	}
+}
+
 func TestFetchRange_ContextCancel(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(2 * time.Second)
@@ -176,3 +282,231 @@ func TestFetchRange_RateLimit(t *testing.T) {
 	for range results {
 	}
 }
+
+func TestPauseGate(t *testing.T) {
+	g := NewPauseGate()
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() on open gate: %v", err)
+	}
+
+	if !g.Toggle() || !g.Paused() {
+		t.Fatal("expected gate to be paused after first Toggle")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); err == nil {
+		t.Fatal("expected Wait() to block until context deadline while paused")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- g.Wait(context.Background()) }()
+	if g.Toggle() {
+		t.Fatal("expected gate to be resumed after second Toggle")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait() after resume: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait() did not return after resume")
+	}
+}
+
+func TestSetInitialWorkers(t *testing.T) {
+	client := NewClient("https://example.com", 5*time.Second, 0)
+	pool := NewWorkerPool(client, 256, 8, 0)
+
+	if pool.initialWorkers != 1 {
+		t.Errorf("default initialWorkers = %d, want 1", pool.initialWorkers)
+	}
+	pool.SetInitialWorkers(8)
+	if pool.initialWorkers != 8 {
+		t.Errorf("initialWorkers = %d, want 8", pool.initialWorkers)
+	}
+	pool.SetInitialWorkers(0)
+	if pool.initialWorkers != 1 {
+		t.Errorf("initialWorkers clamped = %d, want 1", pool.initialWorkers)
+	}
+}
+
+func TestSetRampInterval(t *testing.T) {
+	client := NewClient("https://example.com", 5*time.Second, 0)
+	pool := NewWorkerPool(client, 256, 4, 0)
+
+	if pool.rampInterval != 500*time.Millisecond {
+		t.Errorf("default rampInterval = %v, want 500ms", pool.rampInterval)
+	}
+	pool.SetRampInterval(100 * time.Millisecond)
+	if pool.rampInterval != 100*time.Millisecond {
+		t.Errorf("rampInterval = %v, want 100ms", pool.rampInterval)
+	}
+	pool.SetRampInterval(0)
+	if pool.rampInterval != 100*time.Millisecond {
+		t.Errorf("rampInterval after zero = %v, want unchanged 100ms", pool.rampInterval)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	client := NewClient("https://example.com", 5*time.Second, 0)
+	pool := NewWorkerPool(client, 10, 1, 0)
+
+	got := pool.batches(5, 30)
+	want := []workItem{{5, 14}, {15, 24}, {25, 29}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("forward batches = %v, want %v", got, want)
+	}
+
+	pool.SetReverse(true)
+	got = pool.batches(5, 30)
+	want = []workItem{{20, 29}, {10, 19}, {5, 9}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reverse batches = %v, want %v", got, want)
+	}
+}
+
+func TestFetchRanges_RecoversDropped(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() && r.URL.Query().Get("start") == "10" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 5*time.Second, 0)
+	pool := NewWorkerPool(client, 10, 1, 0)
+	results := make(chan EntryBatch, 100)
+	if err := pool.FetchRange(context.Background(), 0, 30, results); err != nil {
+		t.Fatalf("FetchRange error: %v", err)
+	}
+	for range results {
+	}
+
+	dropped := pool.DroppedRanges()
+	if len(dropped) != 1 || dropped[0] != (Range{Start: 10, End: 19}) {
+		t.Fatalf("DroppedRanges() = %v, want [{10 19}]", dropped)
+	}
+
+	fail.Store(false)
+	retry := NewWorkerPool(client, 10, 1, 0)
+	results = make(chan EntryBatch, 100)
+	if err := retry.FetchRanges(context.Background(), dropped, results); err != nil {
+		t.Fatalf("FetchRanges error: %v", err)
+	}
+	var got int
+	for b := range results {
+		got += len(b.Entries)
+	}
+	if got != 10 || retry.DroppedEntries() != 0 {
+		t.Errorf("retry fetched %d entries with %d dropped, want 10 and 0", got, retry.DroppedEntries())
+	}
+}
+
+func TestSetStallTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start") == "10" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"entries":[{"leaf_input":"dGVzdA==","extra_data":""}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, 10*time.Second, 0)
+	pool := NewWorkerPool(client, 10, 1, 0)
+	var warnings []string
+	pool.SetStallTimeout(100*time.Millisecond, func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	})
+
+	results := make(chan EntryBatch, 100)
+	started := time.Now()
+	if err := pool.FetchRange(context.Background(), 0, 30, results); err != nil {
+		t.Fatalf("FetchRange error: %v", err)
+	}
+	for range results {
+	}
+
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("FetchRange took %v, watchdog did not free the stalled batch", elapsed)
+	}
+	dropped := pool.DroppedRanges()
+	if len(dropped) != 1 || dropped[0] != (Range{Start: 10, End: 19}) {
+		t.Errorf("DroppedRanges() = %v, want [{10 19}]", dropped)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "[10-19]") {
+		t.Errorf("warnings = %q, want one naming [10-19]", warnings)
+	}
+}
+
+func TestSteer(t *testing.T) {
+	client := NewClient("https://example.com", 5*time.Second, 0)
+	pool := NewWorkerPool(client, 10, 3, 0)
+	pool.SetTargetRate(1000)
+
+	if got := pool.steer(1, 500, 0); got != 1 {
+		t.Errorf("below target: steer() = %d, want 1", got)
+	}
+	if got := pool.steer(3, 500, 0); got != 0 {
+		t.Errorf("below target at max workers: steer() = %d, want 0", got)
+	}
+	if got := pool.steer(3, 1500, 0); got != -1 || pool.retiring.Load() != 1 {
+		t.Errorf("above target: steer() = %d, retiring = %d, want -1 and 1", got, pool.retiring.Load())
+	}
+	pool.retiring.Store(0)
+	if got := pool.steer(2, 1000, 1); got != -1 {
+		t.Errorf("errors on target: steer() = %d, want -1", got)
+	}
+	pool.retiring.Store(0)
+
+	pool.steer(1, 1500, 0)
+	if d := time.Duration(pool.delay.Load()); d != minPaceDelay {
+		t.Errorf("single worker above target: delay = %v, want %v", d, minPaceDelay)
+	}
+	if got := pool.steer(1, 500, 0); got != 0 || pool.delay.Load() != 0 {
+		t.Errorf("below target while pacing: steer() = %d, delay = %v, want 0 and no delay", got, time.Duration(pool.delay.Load()))
+	}
+}
+
+func TestSetRangeLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int
+		fmt.Sscan(r.URL.Query().Get("start"), &start)
+		fmt.Sscan(r.URL.Query().Get("end"), &end)
+		if start == 10 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		entries := strings.Repeat(`{"leaf_input":"dGVzdA==","extra_data":""},`, end-start+1)
+		fmt.Fprintf(w, `{"entries":[%s]}`, strings.TrimSuffix(entries, ","))
+	}))
+	defer srv.Close()
+
+	pool := NewWorkerPool(NewClient(srv.URL, 5*time.Second, 0), 10, 1, 0)
+	var mu sync.Mutex
+	var got []string
+	pool.SetRangeLog(func(status string, rg Range) {
+		mu.Lock()
+		got = append(got, fmt.Sprintf("%s %d-%d", status, rg.Start, rg.End))
+		mu.Unlock()
+	})
+	results := make(chan EntryBatch, 100)
+	if err := pool.FetchRange(context.Background(), 0, 30, results); err != nil {
+		t.Fatalf("FetchRange error: %v", err)
+	}
+	for range results {
+	}
+
+	want := []string{"fetched 0-9", "dropped 10-19", "fetched 20-29"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("range log = %v, want %v", got, want)
+	}
+}
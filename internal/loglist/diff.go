@@ -0,0 +1,55 @@
+package loglist
+
+// Change is one difference between two log lists.
+type Change struct {
+	// Kind is "added", "removed" or "state".
+	Kind     string `json:"change"`
+	Operator string `json:"operator"`
+	// Description and URL come from the newer list, or the older one for
+	// removed logs.
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	OldState    string `json:"old_state,omitempty"`
+	NewState    string `json:"new_state,omitempty"`
+}
+
+// Diff compares two log lists by log ID. Added and state-changed logs are
+// listed in the order of the newer list, followed by removed logs in the
+// order of the older one.
+func Diff(older, newer *LogList) []Change {
+	prev := make(map[string]LogWithOperator)
+	for _, l := range FilterLogs(older, "all") {
+		prev[l.Log.LogID] = l
+	}
+
+	var changes []Change
+	seen := make(map[string]bool)
+	for _, l := range FilterLogs(newer, "all") {
+		seen[l.Log.LogID] = true
+		state := l.Log.CurrentState()
+		old, ok := prev[l.Log.LogID]
+		switch {
+		case !ok:
+			changes = append(changes, newChange("added", l, "", state))
+		case old.Log.CurrentState() != state:
+			changes = append(changes, newChange("state", l, old.Log.CurrentState(), state))
+		}
+	}
+	for _, l := range FilterLogs(older, "all") {
+		if !seen[l.Log.LogID] {
+			changes = append(changes, newChange("removed", l, l.Log.CurrentState(), ""))
+		}
+	}
+	return changes
+}
+
+func newChange(kind string, l LogWithOperator, oldState, newState string) Change {
+	return Change{
+		Kind:        kind,
+		Operator:    l.Operator,
+		Description: l.Log.Description,
+		URL:         l.Log.FullURL(),
+		OldState:    oldState,
+		NewState:    newState,
+	}
+}
@@ -1,7 +1,10 @@
 package loglist
 
 import (
+	"bufio"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +14,10 @@ import (
 
 const DefaultLogListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
 
+// MaxAge is how old a log list's NewestTimestamp may be before it is
+// considered stale. The published list is regenerated daily.
+const MaxAge = 90 * 24 * time.Hour
+
 type Fetcher struct {
 	client *http.Client
 }
@@ -23,12 +30,22 @@ func NewFetcher(timeout time.Duration) *Fetcher {
 	}
 }
 
+// SetMinTLSVersion sets the lowest TLS version used to fetch the log list.
+func (f *Fetcher) SetMinTLSVersion(v uint16) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{MinVersion: v}
+	f.client.Transport = t
+}
+
 func (f *Fetcher) Fetch(ctx context.Context, url string) (*LogList, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("User-Agent", "ct-hulhu")
+	// Asking explicitly turns off the transport's transparent gzip, so
+	// decoding happens below for Content-Encoding and for .gz files alike.
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	resp, err := f.client.Do(req)
 	if err != nil {
@@ -40,10 +57,9 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) (*LogList, error) {
 		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
 	}
 
-	const maxLogListSize = 4 << 20
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLogListSize))
+	body, err := readLogList(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+		return nil, err
 	}
 
 	var logList LogList
@@ -54,6 +70,33 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) (*LogList, error) {
 	return &logList, nil
 }
 
+// maxLogListSize caps the decompressed log list.
+const maxLogListSize = 4 << 20
+
+// readLogList reads a log list body, gunzipping it if it starts with the
+// gzip magic bytes, whatever the headers say.
+func readLogList(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing log list: %w", err)
+		}
+		defer gr.Close()
+		src = gr
+	}
+
+	body, err := io.ReadAll(io.LimitReader(src, maxLogListSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if len(body) > maxLogListSize {
+		return nil, fmt.Errorf("log list is larger than %d MB", maxLogListSize>>20)
+	}
+	return body, nil
+}
+
 func (f *Fetcher) FetchDefault(ctx context.Context) (*LogList, error) {
 	return f.Fetch(ctx, DefaultLogListURL)
 }
@@ -73,7 +116,48 @@ func FilterLogs(logList *LogList, stateFilter string) []LogWithOperator {
 	return result
 }
 
+// FilterLogsUsableAt returns the logs that were scrapeable at t.
+func FilterLogsUsableAt(logList *LogList, t time.Time) []LogWithOperator {
+	var result []LogWithOperator
+	for _, op := range logList.Operators {
+		for _, log := range op.Logs {
+			if log.UsableAt(t) {
+				result = append(result, LogWithOperator{
+					Log:      log,
+					Operator: op.Name,
+				})
+			}
+		}
+	}
+	return result
+}
+
 type LogWithOperator struct {
 	Log      Log
 	Operator string
 }
+
+// OperatorSummary counts an operator's logs by CurrentState.
+type OperatorSummary struct {
+	Name   string
+	Logs   int
+	States map[string]int
+}
+
+// SummarizeOperators groups logs by operator, in the order operators first
+// appear.
+func SummarizeOperators(logs []LogWithOperator) []OperatorSummary {
+	var summaries []OperatorSummary
+	index := make(map[string]int)
+	for _, l := range logs {
+		i, ok := index[l.Operator]
+		if !ok {
+			i = len(summaries)
+			index[l.Operator] = i
+			summaries = append(summaries, OperatorSummary{Name: l.Operator, States: make(map[string]int)})
+		}
+		summaries[i].Logs++
+		summaries[i].States[l.Log.CurrentState()]++
+	}
+	return summaries
+}
@@ -1,9 +1,12 @@
 package loglist
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,6 +26,10 @@ func TestLogMatchesState(t *testing.T) {
 		{LogState{Qualified: &StateInfo{}}, "qualified", true},
 		{LogState{}, "usable", false},
 		{LogState{}, "all", true},
+		{LogState{ReadOnly: &ReadOnlyInfo{}}, "usable,readonly", true},
+		{LogState{Usable: &StateInfo{}}, "usable, readonly", true},
+		{LogState{Retired: &StateInfo{}}, "usable,readonly", false},
+		{LogState{Retired: &StateInfo{}}, "usable,all", true},
 	}
 
 	for _, tt := range tests {
@@ -34,6 +41,36 @@ func TestLogMatchesState(t *testing.T) {
 	}
 }
 
+func TestLogUsableAt(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		state LogState
+		at    time.Time
+		want  bool
+	}{
+		{"usable after transition", LogState{Usable: &StateInfo{Timestamp: jun}}, dec, true},
+		{"usable before transition", LogState{Usable: &StateInfo{Timestamp: jun}}, jan, false},
+		{"qualified after transition", LogState{Qualified: &StateInfo{Timestamp: jun}}, dec, true},
+		{"readonly before freeze", LogState{ReadOnly: &ReadOnlyInfo{Timestamp: jun}}, jan, true},
+		{"readonly after freeze", LogState{ReadOnly: &ReadOnlyInfo{Timestamp: jun}}, dec, true},
+		{"retired before retirement", LogState{Retired: &StateInfo{Timestamp: jun}}, jan, true},
+		{"retired after retirement", LogState{Retired: &StateInfo{Timestamp: jun}}, dec, false},
+		{"pending", LogState{Pending: &StateInfo{Timestamp: jan}}, dec, false},
+		{"rejected", LogState{Rejected: &StateInfo{Timestamp: jan}}, dec, false},
+	}
+
+	for _, tt := range tests {
+		log := Log{State: tt.state}
+		if got := log.UsableAt(tt.at); got != tt.want {
+			t.Errorf("%s: UsableAt() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestLogCurrentState(t *testing.T) {
 	tests := []struct {
 		state LogState
@@ -104,6 +141,97 @@ func TestFilterLogs(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	older := &LogList{Operators: []Operator{{
+		Name: "Google",
+		Logs: []Log{
+			{LogID: "a", Description: "Argon", URL: "https://ct.example.com/argon/", State: LogState{Usable: &StateInfo{}}},
+			{LogID: "b", Description: "Xenon", URL: "https://ct.example.com/xenon/", State: LogState{Usable: &StateInfo{}}},
+			{LogID: "c", Description: "Old", URL: "https://ct.example.com/old/", State: LogState{Retired: &StateInfo{}}},
+		},
+	}}}
+	newer := &LogList{Operators: []Operator{{
+		Name: "Google",
+		Logs: []Log{
+			{LogID: "a", Description: "Argon", URL: "https://ct.example.com/argon/", State: LogState{Usable: &StateInfo{}}},
+			{LogID: "b", Description: "Xenon", URL: "https://ct.example.com/xenon/", State: LogState{ReadOnly: &ReadOnlyInfo{}}},
+			{LogID: "d", Description: "New", URL: "https://ct.example.com/new/", State: LogState{Pending: &StateInfo{}}},
+		},
+	}}}
+
+	got := Diff(older, newer)
+	want := []Change{
+		{Kind: "state", Operator: "Google", Description: "Xenon", URL: "https://ct.example.com/xenon/", OldState: "usable", NewState: "readonly"},
+		{Kind: "added", Operator: "Google", Description: "New", URL: "https://ct.example.com/new/", NewState: "pending"},
+		{Kind: "removed", Operator: "Google", Description: "Old", URL: "https://ct.example.com/old/", OldState: "retired"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if changes := Diff(newer, newer); len(changes) != 0 {
+		t.Errorf("Diff() of identical lists = %+v, want none", changes)
+	}
+}
+
+func TestSummarizeOperators(t *testing.T) {
+	logList := &LogList{
+		Operators: []Operator{
+			{
+				Name: "Google",
+				Logs: []Log{
+					{State: LogState{Usable: &StateInfo{}}},
+					{State: LogState{Usable: &StateInfo{}}},
+					{State: LogState{Retired: &StateInfo{}}},
+				},
+			},
+			{
+				Name: "Sectigo",
+				Logs: []Log{{State: LogState{ReadOnly: &ReadOnlyInfo{}}}},
+			},
+		},
+	}
+
+	got := SummarizeOperators(FilterLogs(logList, "all"))
+	if len(got) != 2 || got[0].Name != "Google" || got[1].Name != "Sectigo" {
+		t.Fatalf("SummarizeOperators() = %+v, want Google then Sectigo", got)
+	}
+	if got[0].Logs != 3 || got[0].States["usable"] != 2 || got[0].States["retired"] != 1 {
+		t.Errorf("Google summary = %+v, want 3 logs (2 usable, 1 retired)", got[0])
+	}
+	if got[1].Logs != 1 || got[1].States["readonly"] != 1 {
+		t.Errorf("Sectigo summary = %+v, want 1 readonly log", got[1])
+	}
+}
+
+func TestNewestTimestamp(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+	logList := &LogList{
+		Operators: []Operator{{
+			Logs: []Log{
+				{State: LogState{Usable: &StateInfo{Timestamp: day(3)}}},
+				{State: LogState{ReadOnly: &ReadOnlyInfo{Timestamp: day(9)}}},
+				{State: LogState{Retired: &StateInfo{Timestamp: day(5)}}},
+			},
+		}},
+	}
+
+	if got := logList.NewestTimestamp(); !got.Equal(day(9)) {
+		t.Errorf("NewestTimestamp() = %v, want %v", got, day(9))
+	}
+	logList.Timestamp = day(20)
+	if got := logList.NewestTimestamp(); !got.Equal(day(20)) {
+		t.Errorf("NewestTimestamp() with log_list_timestamp = %v, want %v", got, day(20))
+	}
+	if got := (&LogList{}).NewestTimestamp(); !got.IsZero() {
+		t.Errorf("NewestTimestamp() of empty list = %v, want zero", got)
+	}
+}
+
 func TestFetch(t *testing.T) {
 	const logListJSON = `{
 		"version": "3",
@@ -183,3 +311,57 @@ func TestNewFetcher(t *testing.T) {
 		t.Errorf("timeout = %v, want 10s", f.client.Timeout)
 	}
 }
+
+func TestFetch_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(`{"version": "3", "operators": [{"name": "TestOp"}]}`))
+	gw.Close()
+	compressed := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		encoding string
+	}{
+		{"Content-Encoding gzip", "gzip"},
+		{"gzip file without encoding header", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != "gzip" {
+					t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(compressed)
+			}))
+			defer srv.Close()
+
+			logList, err := NewFetcher(5*time.Second).Fetch(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Fetch error: %v", err)
+			}
+			if len(logList.Operators) != 1 || logList.Operators[0].Name != "TestOp" {
+				t.Errorf("Operators = %+v, want TestOp", logList.Operators)
+			}
+		})
+	}
+}
+
+func TestFetch_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(bytes.Repeat([]byte(" "), maxLogListSize+1))
+	gw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	if _, err := NewFetcher(5*time.Second).Fetch(context.Background(), srv.URL); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Fetch() error = %v, want a size limit error", err)
+	}
+}
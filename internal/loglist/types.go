@@ -9,9 +9,24 @@ import (
 
 type LogList struct {
 	Version   string     `json:"version"`
+	Timestamp time.Time  `json:"log_list_timestamp"`
 	Operators []Operator `json:"operators"`
 }
 
+// NewestTimestamp returns the latest of the list's own timestamp and every
+// log state change it records, as an estimate of how current it is.
+func (l *LogList) NewestTimestamp() time.Time {
+	newest := l.Timestamp
+	for _, op := range l.Operators {
+		for _, log := range op.Logs {
+			if t := log.StateTimestamp(); t.After(newest) {
+				newest = t
+			}
+		}
+	}
+	return newest
+}
+
 type Operator struct {
 	Name  string   `json:"name"`
 	Email []string `json:"email"`
@@ -71,6 +86,26 @@ func (l *Log) CurrentState() string {
 	}
 }
 
+// StateTimestamp returns when the log entered its current state.
+func (l *Log) StateTimestamp() time.Time {
+	switch {
+	case l.State.Usable != nil:
+		return l.State.Usable.Timestamp
+	case l.State.ReadOnly != nil:
+		return l.State.ReadOnly.Timestamp
+	case l.State.Qualified != nil:
+		return l.State.Qualified.Timestamp
+	case l.State.Retired != nil:
+		return l.State.Retired.Timestamp
+	case l.State.Pending != nil:
+		return l.State.Pending.Timestamp
+	case l.State.Rejected != nil:
+		return l.State.Rejected.Timestamp
+	default:
+		return time.Time{}
+	}
+}
+
 func (l *Log) FullURL() string {
 	url := l.URL
 
@@ -84,9 +119,40 @@ func (l *Log) FullURL() string {
 	return url
 }
 
+// Frozen reports whether the log is read-only or retired, so its tree
+// will never grow again.
+func (l *Log) Frozen() bool {
+	return l.State.ReadOnly != nil || l.State.Retired != nil
+}
+
+// MatchesState reports whether the log is in one of the comma-separated
+// states in filter; "all" matches every log.
 func (l *Log) MatchesState(filter string) bool {
-	if filter == "all" {
+	current := l.CurrentState()
+	for _, state := range strings.Split(filter, ",") {
+		state = strings.TrimSpace(state)
+		if state == "all" || state == current {
+			return true
+		}
+	}
+	return false
+}
+
+// UsableAt reports whether the log was usable, qualified or read-only at t.
+// The log list only records when the current state began, so earlier
+// history is inferred: a read-only log was usable before it was frozen and
+// a retired one was scrapeable until it retired.
+func (l *Log) UsableAt(t time.Time) bool {
+	switch {
+	case l.State.Usable != nil:
+		return !t.Before(l.State.Usable.Timestamp)
+	case l.State.Qualified != nil:
+		return !t.Before(l.State.Qualified.Timestamp)
+	case l.State.ReadOnly != nil:
 		return true
+	case l.State.Retired != nil:
+		return t.Before(l.State.Retired.Timestamp)
+	default:
+		return false
 	}
-	return l.CurrentState() == filter
 }
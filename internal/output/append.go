@@ -0,0 +1,105 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// openAppend opens path for appending and marks every result line it
+// already holds as seen. A trailing partial line, e.g. from a crash
+// mid-write, is terminated so new output starts on a line of its own.
+func (w *Writer) openAppend(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening output file: %w", err)
+	}
+
+	if err := w.preload(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading existing output: %w", err)
+	}
+
+	if size, err := f.Seek(0, io.SeekEnd); err == nil && size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	return f, nil
+}
+
+// seedDedup marks every result line of an earlier run's output file as
+// seen, without writing to it.
+func (w *Writer) seedDedup(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening seed file: %w", err)
+	}
+	defer f.Close()
+	if err := w.preload(f); err != nil {
+		return fmt.Errorf("reading seed file: %w", err)
+	}
+	return nil
+}
+
+// preload marks the dedup keys of every line in r as seen. They are
+// counted in preloaded so Stats reports new results only.
+func (w *Writer) preload(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		for _, key := range w.lineKeys(sc.Text()) {
+			if _, exists := w.seen[key]; exists || len(w.seen) >= maxDedup {
+				continue
+			}
+			w.seen[key] = struct{}{}
+			w.preloaded++
+		}
+	}
+	return sc.Err()
+}
+
+// lineKeys rebuilds the dedup keys a previously written line was stored
+// under. Modes keyed by log index (certs, usage, table, group-by) can't be
+// rebuilt from their output and return nil.
+func (w *Writer) lineKeys(line string) []string {
+	if line == "" || isMetaLine(line) {
+		return nil
+	}
+	if w.jsonMode {
+		var jr JSONResult
+		if err := json.Unmarshal([]byte(line), &jr); err != nil {
+			return nil
+		}
+		id := jr.Serial
+		if id == "" {
+			id = fmt.Sprintf("idx:%d", jr.Index)
+		}
+		return []string{fmt.Sprintf("j:%s:%s", id, jr.LogURL)}
+	}
+	if w.opts.Template != nil {
+		return []string{w.uniqueKey("t:", line)}
+	}
+	if w.opts.Table || w.opts.GroupByCert {
+		return nil
+	}
+
+	switch w.fields {
+	case "domains":
+		return []string{w.uniqueKey("d:", line)}
+	case "ips":
+		return []string{w.uniqueKey("i:", line)}
+	case "emails":
+		return []string{w.uniqueKey("e:", line)}
+	case "revocation":
+		return []string{w.uniqueKey("r:", line)}
+	case "serials":
+		return []string{w.uniqueKey("s:", line)}
+	case "all":
+		return []string{w.uniqueKey("d:", line), w.uniqueKey("i:", line), w.uniqueKey("e:", line)}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Meta records what a scrape covered, for the optional "_meta" line
+// written after a log's JSON results. Start and End are the lowest and
+// highest entry index actually fetched.
+type Meta struct {
+	LogURL      string `json:"log_url"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	TreeSize    int64  `json:"tree_size"`
+	ToolVersion string `json:"tool_version"`
+	StartedAt   string `json:"started_at"`
+}
+
+const metaPrefix = `{"_meta":`
+
+// WriteMeta writes meta as a {"_meta": {...}} line of its own. Consumers
+// that only want results skip lines with a "_meta" key.
+func (w *Writer) WriteMeta(meta Meta) error {
+	data, err := json.Marshal(struct {
+		Meta Meta `json:"_meta"`
+	}{meta})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bw.Write(data)
+	return w.bw.WriteByte('\n')
+}
+
+func isMetaLine(line string) bool {
+	return strings.HasPrefix(line, metaPrefix)
+}
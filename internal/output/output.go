@@ -2,57 +2,240 @@ package output
 
 import (
 	"bufio"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
 	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+	"github.com/TheArqsz/ct-hulhu/internal/psl"
 )
 
 const maxDedup = 1_000_000
 
+// maxLinks bounds how many certificates are remembered for precert linking;
+// the oldest are forgotten first.
+const maxLinks = 100_000
+
 type Writer struct {
 	mu          sync.Mutex
 	bw          *bufio.Writer
 	closer      io.Closer
 	jsonMode    bool
 	fields      string
+	opts        Options
 	seen        map[string]struct{}
 	dedupWarned bool
+	stopFlush   chan struct{}
+	flushDone   chan struct{}
+	links       map[string]ctlog.EntryRef
+	linkOrder   []string
+	tableHeader bool
+	preloaded   int
+	offered     int64
+	emitted     int64
+	perDomain   map[string]int
+	capKey      string
+	capSkipped  int64
+}
+
+// Options tunes how results are rendered.
+type Options struct {
+	// RollupV4Bits and RollupV6Bits collapse IPs in line output into their
+	// containing prefix (0 = emit individual addresses).
+	RollupV4Bits int
+	RollupV6Bits int
+	// Timestamps prefixes line output with the discovery time and adds
+	// discovered_at to JSON output.
+	Timestamps bool
+	// FlushInterval flushes buffered output on a timer. Callers that set it
+	// don't need to call Flush after every write; Close always flushes.
+	FlushInterval time.Duration
+	// Template renders one line per result, overriding fields. Build it
+	// with ParseTemplate.
+	Template *template.Template
+	// LinkPrecerts pairs precerts with final certificates seen earlier in
+	// the run (by CertResult.TBSHash) and adds final_for/precert_of to JSON.
+	LinkPrecerts bool
+	// Table renders each certificate as an aligned row (CN, issuer,
+	// not-after, number of domains) for reading in a terminal.
+	Table bool
+	// GroupByCert writes one tab-separated line per certificate (serial,
+	// CN, then comma-joined domains, IPs and emails) instead of one line
+	// per value.
+	GroupByCert bool
+	// Sep separates the values of list columns in -f certs, -f usage and
+	// grouped output ("" = ",").
+	Sep string
+	// BufferSize is the write buffer size in bytes (0 = bufio default,
+	// 4 KB). Larger buffers mean fewer write syscalls on big scrapes.
+	BufferSize int
+	// FoldCase dedups line output ignoring case, so Admin@Example.com
+	// and admin@example.com print once. The first spelling seen is kept.
+	FoldCase bool
+	// MaxPerDomain stops writing results for a domain once it has produced
+	// this many lines (0 = no cap). Results are counted under
+	// CertResult.MatchedDomain, or the common name when that is empty.
+	MaxPerDomain int
+	// ExportSeen writes the dedup set to this file on Close, one value per
+	// line and sorted, without the internal per-mode key prefixes.
+	ExportSeen string
+	// Proto writes each result as a varint length-prefixed protobuf
+	// CertResult message (proto/cert_result.proto) instead of text.
+	Proto bool
+	// Append keeps an existing output file and skips results it already
+	// holds, e.g. when resuming a scrape. Stats counts only new results.
+	Append bool
+	// SeedDedup marks the lines of this earlier output file as seen, so
+	// values it holds are not written again. It must use the same output
+	// mode as this run.
+	SeedDedup string
+	// FileOnly writes only to the output file. By default results go to
+	// stdout as well.
+	FileOnly bool
+}
+
+// ParseTemplate compiles a text/template over ctlog.CertResult and runs it
+// once against templateSample so unknown fields are caught up front.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, templateSample()); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// templateSample is a result with every list filled, so templates such as
+// {{index .Domains 0}} pass the startup check. Results with fewer values
+// are reported when they are rendered.
+func templateSample() *ctlog.CertResult {
+	one := []string{"example.com"}
+	return &ctlog.CertResult{
+		Domains:     one,
+		IPs:         []string{"192.0.2.1"},
+		Emails:      []string{"admin@example.com"},
+		KeyUsage:    one,
+		ExtKeyUsage: one,
+		OCSP:        one,
+		CRL:         one,
+		SCTLogIDs:   one,
+		Policies:    one,
+		DER:         []byte{0},
+		PrecertTBS:  []byte{0},
+	}
 }
 
 func NewWriter(outputPath string, jsonMode bool, fields string) (*Writer, error) {
+	return NewWriterWithOptions(outputPath, jsonMode, fields, Options{})
+}
+
+func NewWriterWithOptions(outputPath string, jsonMode bool, fields string, opts Options) (*Writer, error) {
 	w := &Writer{
 		jsonMode: jsonMode,
 		fields:   fields,
+		opts:     opts,
 		seen:     make(map[string]struct{}),
 	}
+	if opts.LinkPrecerts {
+		w.links = make(map[string]ctlog.EntryRef)
+	}
+	if opts.MaxPerDomain > 0 {
+		w.perDomain = make(map[string]int)
+	}
+	if opts.SeedDedup != "" {
+		if err := w.seedDedup(opts.SeedDedup); err != nil {
+			return nil, err
+		}
+	}
 
 	if outputPath != "" {
-		f, err := os.Create(outputPath)
+		var f *os.File
+		var err error
+		if opts.Append {
+			f, err = w.openAppend(outputPath)
+		} else if f, err = os.Create(outputPath); err != nil {
+			err = fmt.Errorf("creating output file: %w", err)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("creating output file: %w", err)
+			return nil, err
 		}
-		w.bw = bufio.NewWriter(io.MultiWriter(f, os.Stdout))
+		var out io.Writer = f
+		if !opts.FileOnly {
+			out = io.MultiWriter(f, os.Stdout)
+		}
+		w.bw = bufio.NewWriterSize(out, opts.BufferSize)
 		w.closer = f
 	} else {
-		w.bw = bufio.NewWriter(os.Stdout)
+		w.bw = bufio.NewWriterSize(os.Stdout, opts.BufferSize)
+	}
+
+	if opts.FlushInterval > 0 {
+		w.stopFlush = make(chan struct{})
+		w.flushDone = make(chan struct{})
+		go w.flushLoop(opts.FlushInterval)
 	}
 
 	return w, nil
 }
 
+func (w *Writer) flushLoop(interval time.Duration) {
+	defer close(w.flushDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopFlush:
+			return
+		case <-ticker.C:
+			w.Flush()
+		}
+	}
+}
+
 func (w *Writer) WriteResult(result *ctlog.CertResult) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if w.perDomain != nil {
+		domain := capDomain(result)
+		if !w.underDomainCap(domain) {
+			w.capSkipped++
+			return
+		}
+		w.capKey = domain
+		defer func() { w.capKey = "" }()
+	}
+
+	if w.opts.Proto {
+		w.writeProto(result)
+		return
+	}
 	if w.jsonMode {
 		w.writeJSON(result)
 		return
 	}
+	if w.opts.Template != nil {
+		w.writeTemplate(result)
+		return
+	}
+	if w.opts.Table {
+		w.writeTableRow(result)
+		return
+	}
+	if w.opts.GroupByCert {
+		w.writeGroupedLine(result)
+		return
+	}
 
 	switch w.fields {
 	case "domains":
@@ -63,6 +246,14 @@ func (w *Writer) WriteResult(result *ctlog.CertResult) {
 		w.writeEmails(result)
 	case "certs":
 		w.writeCertLine(result)
+	case "usage":
+		w.writeUsageLine(result)
+	case "policy":
+		w.writePolicyLine(result)
+	case "revocation":
+		w.writeRevocation(result)
+	case "serials":
+		w.writeSerial(result)
 	case "all":
 		w.writeDomains(result)
 		w.writeIPs(result)
@@ -72,88 +263,270 @@ func (w *Writer) WriteResult(result *ctlog.CertResult) {
 	}
 }
 
+// capDomain is the name a result counts against for -max-per-domain.
+func capDomain(result *ctlog.CertResult) string {
+	if result.MatchedName != "" {
+		return result.MatchedName
+	}
+	return strings.ToLower(result.CommonName)
+}
+
+func (w *Writer) underDomainCap(domain string) bool {
+	return domain == "" || w.perDomain[domain] < w.opts.MaxPerDomain
+}
+
+// countDomainLine adds a line written for domain and warns once when it
+// reaches the cap. Like dedup, only the first maxDedup domains are tracked.
+func (w *Writer) countDomainLine(domain string) {
+	if domain == "" {
+		return
+	}
+	if _, ok := w.perDomain[domain]; !ok && len(w.perDomain) >= maxDedup {
+		return
+	}
+	w.perDomain[domain]++
+	if w.perDomain[domain] == w.opts.MaxPerDomain {
+		fmt.Fprintf(os.Stderr, "[WRN] %s reached the per-domain cap (%d lines), skipping further results for it\n", Sanitize(domain), w.opts.MaxPerDomain)
+	}
+}
+
 func (w *Writer) writeUnique(prefix string, items []string, sanitize bool) {
 	for _, item := range items {
-		key := prefix + item
-		if _, exists := w.seen[key]; exists {
+		if !w.claim(w.uniqueKey(prefix, item)) {
 			continue
 		}
-		if len(w.seen) < maxDedup {
-			w.seen[key] = struct{}{}
-		} else if !w.dedupWarned {
-			w.dedupWarned = true
-			fmt.Fprintf(os.Stderr, "[WRN] deduplication limit reached (%d entries), duplicates may appear in output\n", maxDedup)
-		}
 		if sanitize {
 			item = Sanitize(item)
 		}
+		w.writeTimestamp()
 		fmt.Fprintln(w.bw, item)
 	}
 }
 
+func (w *Writer) uniqueKey(prefix, item string) string {
+	if w.opts.FoldCase {
+		item = strings.ToLower(item)
+	}
+	return prefix + item
+}
+
 func (w *Writer) writeDomains(result *ctlog.CertResult) { w.writeUnique("d:", result.Domains, true) }
-func (w *Writer) writeIPs(result *ctlog.CertResult)     { w.writeUnique("i:", result.IPs, false) }
-func (w *Writer) writeEmails(result *ctlog.CertResult)  { w.writeUnique("e:", result.Emails, true) }
+func (w *Writer) writeIPs(result *ctlog.CertResult) {
+	if w.opts.RollupV4Bits > 0 || w.opts.RollupV6Bits > 0 {
+		w.writeUnique("i:", rollupIPs(result.IPs, w.opts.RollupV4Bits, w.opts.RollupV6Bits), false)
+		return
+	}
+	w.writeUnique("i:", result.IPs, false)
+}
+func (w *Writer) writeEmails(result *ctlog.CertResult) { w.writeUnique("e:", result.Emails, true) }
+
+// writeRevocation emits each unique OCSP responder and CRL distribution
+// point as "ocsp <url>" or "crl <url>".
+func (w *Writer) writeRevocation(result *ctlog.CertResult) {
+	w.writeUnique("r:", prefixAll("ocsp ", result.OCSP), true)
+	w.writeUnique("r:", prefixAll("crl ", result.CRL), true)
+}
+
+// writeSerial writes the certificate serial. A certificate without one is
+// written, and deduplicated, as idx:<index>:<log URL> instead, the key
+// JSON mode uses for it.
+func (w *Writer) writeSerial(result *ctlog.CertResult) {
+	serial := result.Serial
+	if serial == "" {
+		serial = fmt.Sprintf("idx:%d:%s", result.Index, result.LogURL)
+	}
+	w.writeUnique("s:", []string{serial}, false)
+}
+
+func prefixAll(prefix string, items []string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = prefix + item
+	}
+	return out
+}
 
 func (w *Writer) writeCertLine(result *ctlog.CertResult) {
 	key := fmt.Sprintf("c:%s:%d", result.LogURL, result.Index)
-	if _, exists := w.seen[key]; exists {
+	if !w.claim(key) {
 		return
 	}
-	w.checkDedupLimit()
-	if len(w.seen) < maxDedup {
-		w.seen[key] = struct{}{}
-	}
 
-	domains := Sanitize(strings.Join(result.Domains, ","))
-	fmt.Fprintf(w.bw, "[%s] %s issuer=%s domains=%s\n",
+	domains := w.join(sanitizeSlice(result.Domains))
+	ca := ""
+	if result.IsCA {
+		ca = " ca=true"
+	}
+	w.writeTimestamp()
+	fmt.Fprintf(w.bw, "[%s] %s issuer=%s%s domains=%s\n",
 		result.NotAfter.Format("2006-01-02"),
 		Sanitize(result.CommonName),
 		Sanitize(result.Issuer),
+		ca,
 		domains,
 	)
 }
 
+func (w *Writer) writeUsageLine(result *ctlog.CertResult) {
+	key := fmt.Sprintf("u:%s:%d", result.LogURL, result.Index)
+	if !w.claim(key) {
+		return
+	}
+
+	w.writeTimestamp()
+	fmt.Fprintf(w.bw, "%s ku=%s eku=%s domains=%s\n",
+		Sanitize(result.CommonName),
+		w.join(result.KeyUsage),
+		w.join(result.ExtKeyUsage),
+		w.join(sanitizeSlice(result.Domains)),
+	)
+}
+
+// writePolicyLine emits the validation level and policy OIDs of each
+// certificate. Unlisted OIDs stay dotted, so private CA policies show too.
+func (w *Writer) writePolicyLine(result *ctlog.CertResult) {
+	key := fmt.Sprintf("p:%s:%d", result.LogURL, result.Index)
+	if !w.claim(key) {
+		return
+	}
+
+	w.writeTimestamp()
+	fmt.Fprintf(w.bw, "%s level=%s policies=%s domains=%s\n",
+		Sanitize(result.CommonName),
+		result.ValidationLevel,
+		w.join(result.Policies),
+		w.join(sanitizeSlice(result.Domains)),
+	)
+}
+
+func (w *Writer) writeGroupedLine(result *ctlog.CertResult) {
+	key := fmt.Sprintf("g:%s:%d", result.LogURL, result.Index)
+	if !w.claim(key) {
+		return
+	}
+
+	w.writeTimestamp()
+	fmt.Fprintf(w.bw, "%s\t%s\t%s\t%s\t%s\n",
+		result.Serial,
+		Sanitize(result.CommonName),
+		w.join(sanitizeSlice(result.Domains)),
+		w.join(result.IPs),
+		w.join(sanitizeSlice(result.Emails)),
+	)
+}
+
+func (w *Writer) writeTemplate(result *ctlog.CertResult) {
+	var b strings.Builder
+	if err := w.opts.Template.Execute(&b, result); err != nil {
+		fmt.Fprintf(os.Stderr, "[WRN] rendering template for entry %d: %v\n", result.Index, err)
+		return
+	}
+	w.writeUnique("t:", []string{b.String()}, true)
+}
+
+// link remembers result and returns the counterpart seen earlier, if any:
+// the final certificate for a precert, or the precert for a final one.
+func (w *Writer) link(result *ctlog.CertResult) *ctlog.EntryRef {
+	self, other := "f:", "p:"
+	if result.IsPrecert {
+		self, other = other, self
+	}
+
+	var ref *ctlog.EntryRef
+	if r, ok := w.links[other+result.TBSHash]; ok {
+		ref = &r
+	}
+
+	key := self + result.TBSHash
+	if _, exists := w.links[key]; !exists {
+		if len(w.linkOrder) >= maxLinks {
+			delete(w.links, w.linkOrder[0])
+			w.linkOrder = w.linkOrder[1:]
+		}
+		w.links[key] = ctlog.EntryRef{LogURL: result.LogURL, Index: result.Index}
+		w.linkOrder = append(w.linkOrder, key)
+	}
+	return ref
+}
+
+func (w *Writer) writeTimestamp() {
+	if w.opts.Timestamps {
+		w.bw.WriteString(time.Now().UTC().Format(time.RFC3339))
+		w.bw.WriteByte(' ')
+	}
+}
+
 type JSONResult struct {
-	Domains    []string `json:"domains,omitempty"`
-	IPs        []string `json:"ips,omitempty"`
-	Emails     []string `json:"emails,omitempty"`
-	CommonName string   `json:"cn,omitempty"`
-	Issuer     string   `json:"issuer,omitempty"`
-	NotBefore  string   `json:"not_before,omitempty"`
-	NotAfter   string   `json:"not_after,omitempty"`
-	Serial     string   `json:"serial,omitempty"`
-	IsPrecert  bool     `json:"is_precert"`
-	LogURL     string   `json:"log_url,omitempty"`
-	Index      int64    `json:"index"`
+	Domains []string `json:"domains,omitempty"`
+	// RegisteredDomain holds the eTLD+1 of each entry in Domains, in the same
+	// order, with "" for names that have none.
+	RegisteredDomain []string `json:"registered_domain,omitempty"`
+	IPs              []string `json:"ips,omitempty"`
+	Emails           []string `json:"emails,omitempty"`
+	CommonName       string   `json:"cn,omitempty"`
+	CNOnly           bool     `json:"cn_only,omitempty"`
+	Issuer           string   `json:"issuer,omitempty"`
+	NotBefore        string   `json:"not_before,omitempty"`
+	NotAfter         string   `json:"not_after,omitempty"`
+	Serial           string   `json:"serial,omitempty"`
+	IsPrecert        bool     `json:"is_precert"`
+	IsCA             bool     `json:"is_ca"`
+	IssuerKeyHash    string   `json:"issuer_key_hash,omitempty"`
+	KeyUsage         []string `json:"key_usage,omitempty"`
+	ExtKeyUsage      []string `json:"ext_key_usage,omitempty"`
+	Policies         []string `json:"policies,omitempty"`
+	ValidationLevel  string   `json:"validation_level,omitempty"`
+	OCSP             []string `json:"ocsp,omitempty"`
+	CRL              []string `json:"crl,omitempty"`
+	LogURL           string   `json:"log_url,omitempty"`
+	Index            int64    `json:"index"`
+	// ExpiredWhenLogged marks certificates whose validity had ended before
+	// the log timestamp.
+	ExpiredWhenLogged bool     `json:"expired_when_logged,omitempty"`
+	SCTLogIDs         []string `json:"sct_log_ids,omitempty"`
+	// DERB64 is the base64 leaf certificate DER; precert entries carry the
+	// TBSCertificate in PrecertTBSB64 instead, which is not a full cert.
+	DERB64        string `json:"der_b64,omitempty"`
+	PrecertTBSB64 string `json:"precert_tbs_b64,omitempty"`
+	MatchedFilter string `json:"matched_filter,omitempty"`
+	MatchedDomain string `json:"matched_domain,omitempty"`
+	BrandEmbedded bool   `json:"brand_embedded,omitempty"`
+	// DiscoveredAt is when ct-hulhu saw the entry, as opposed to the time
+	// it was logged.
+	DiscoveredAt string `json:"discovered_at,omitempty"`
+	// FinalFor on a final certificate points at its precert; PrecertOf on a
+	// precert points at its final certificate. Only the later of the pair
+	// carries the link.
+	FinalFor  *ctlog.EntryRef `json:"final_for,omitempty"`
+	PrecertOf *ctlog.EntryRef `json:"precert_of,omitempty"`
 }
 
-func (w *Writer) writeJSON(result *ctlog.CertResult) {
+// certKey dedups per-certificate output by serial and log, or by index
+// for certificates without a serial.
+func certKey(prefix string, result *ctlog.CertResult) string {
 	id := result.Serial
 	if id == "" {
 		id = fmt.Sprintf("idx:%d", result.Index)
 	}
-	key := fmt.Sprintf("j:%s:%s", id, result.LogURL)
-	if _, exists := w.seen[key]; exists {
+	return fmt.Sprintf("%s%s:%s", prefix, id, result.LogURL)
+}
+
+func (w *Writer) writeJSON(result *ctlog.CertResult) {
+	if !w.claim(certKey("j:", result)) {
 		return
 	}
-	w.checkDedupLimit()
-	if len(w.seen) < maxDedup {
-		w.seen[key] = struct{}{}
-	}
 
-	jr := JSONResult{
-		Domains:    sanitizeSlice(result.Domains),
-		IPs:        result.IPs,
-		Emails:     sanitizeSlice(result.Emails),
-		CommonName: Sanitize(result.CommonName),
-		Issuer:     Sanitize(result.Issuer),
-		NotBefore:  result.NotBefore.Format("2006-01-02T15:04:05Z"),
-		NotAfter:   result.NotAfter.Format("2006-01-02T15:04:05Z"),
-		Serial:     result.Serial,
-		IsPrecert:  result.IsPrecert,
-		LogURL:     result.LogURL,
-		Index:      result.Index,
+	jr := NewJSONResult(result)
+	if w.opts.Timestamps {
+		jr.DiscoveredAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if w.links != nil && result.TBSHash != "" {
+		ref := w.link(result)
+		if result.IsPrecert {
+			jr.PrecertOf = ref
+		} else {
+			jr.FinalFor = ref
+		}
 	}
 
 	data, err := json.Marshal(jr)
@@ -165,9 +538,63 @@ func (w *Writer) writeJSON(result *ctlog.CertResult) {
 	w.bw.WriteByte('\n')
 }
 
+// NewJSONResult renders result as one JSON output record, with text
+// fields sanitized. Fields that depend on the writer (discovered_at and
+// precert links) are left empty.
+func NewJSONResult(result *ctlog.CertResult) JSONResult {
+	domains := sanitizeSlice(result.Domains)
+	jr := JSONResult{
+		Domains:          domains,
+		RegisteredDomain: registeredDomains(domains),
+		IPs:              result.IPs,
+		Emails:           sanitizeSlice(result.Emails),
+		CommonName:       Sanitize(result.CommonName),
+		CNOnly:           result.CNOnly,
+		Issuer:           Sanitize(result.Issuer),
+		NotBefore:        result.NotBefore.Format("2006-01-02T15:04:05Z"),
+		NotAfter:         result.NotAfter.Format("2006-01-02T15:04:05Z"),
+		Serial:           result.Serial,
+		IsPrecert:        result.IsPrecert,
+		IsCA:             result.IsCA,
+		IssuerKeyHash:    result.IssuerKeyHash,
+		KeyUsage:         result.KeyUsage,
+		ExtKeyUsage:      result.ExtKeyUsage,
+		Policies:         result.Policies,
+		ValidationLevel:  result.ValidationLevel,
+		OCSP:             sanitizeSlice(result.OCSP),
+		CRL:              sanitizeSlice(result.CRL),
+		LogURL:           result.LogURL,
+		Index:            result.Index,
+
+		ExpiredWhenLogged: result.ExpiredWhenLogged,
+		SCTLogIDs:         result.SCTLogIDs,
+		MatchedFilter:     result.MatchedFilter,
+		MatchedDomain:     Sanitize(result.MatchedDomain),
+		BrandEmbedded:     result.BrandEmbedded,
+	}
+	if len(result.DER) > 0 {
+		jr.DERB64 = base64.StdEncoding.EncodeToString(result.DER)
+	}
+	if len(result.PrecertTBS) > 0 {
+		jr.PrecertTBSB64 = base64.StdEncoding.EncodeToString(result.PrecertTBS)
+	}
+	return jr
+}
+
 func (w *Writer) Close() error {
+	if w.stopFlush != nil {
+		close(w.stopFlush)
+		<-w.flushDone
+		w.stopFlush = nil
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if w.opts.ExportSeen != "" {
+		if err := w.exportSeen(w.opts.ExportSeen); err != nil {
+			fmt.Fprintf(os.Stderr, "[WRN] exporting seen set: %v\n", err)
+		}
+	}
 	if err := w.bw.Flush(); err != nil {
 		return err
 	}
@@ -177,6 +604,35 @@ func (w *Writer) Close() error {
 	return nil
 }
 
+// exportSeen writes every remembered dedup key with its prefix ("d:",
+// "i:", "j:", ...) stripped. Keys of per-certificate modes keep their
+// log URL and index or serial, e.g. "https://log/:42".
+func (w *Writer) exportSeen(path string) error {
+	keys := make([]string, 0, len(w.seen))
+	for key := range w.seen {
+		if _, value, ok := strings.Cut(key, ":"); ok {
+			key = value
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	for _, key := range keys {
+		bw.WriteString(key)
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func (w *Writer) Flush() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -186,7 +642,44 @@ func (w *Writer) Flush() error {
 func (w *Writer) Stats() (total int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return len(w.seen)
+	return len(w.seen) - w.preloaded
+}
+
+// claim marks key as written and reports whether it is new. Past maxDedup
+// new keys are no longer remembered, so later duplicates get through. A
+// line past the -max-per-domain cap of the current result is refused.
+func (w *Writer) claim(key string) bool {
+	if w.capKey != "" && !w.underDomainCap(w.capKey) {
+		return false
+	}
+	w.offered++
+	if _, exists := w.seen[key]; exists {
+		return false
+	}
+	w.emitted++
+	if w.capKey != "" {
+		w.countDomainLine(w.capKey)
+	}
+	w.checkDedupLimit()
+	if len(w.seen) < maxDedup {
+		w.seen[key] = struct{}{}
+	}
+	return true
+}
+
+// DedupStats reports how many values were offered for output and how
+// many of them were written; the rest were duplicates.
+func (w *Writer) DedupStats() (emitted, offered int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.emitted, w.offered
+}
+
+// CapSkipped reports how many results were dropped by MaxPerDomain.
+func (w *Writer) CapSkipped() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.capSkipped
 }
 
 func (w *Writer) checkDedupLimit() {
@@ -196,6 +689,60 @@ func (w *Writer) checkDedupLimit() {
 	}
 }
 
+func registeredDomains(domains []string) []string {
+	list := psl.Default()
+	out := make([]string, len(domains))
+	found := false
+	for i, d := range domains {
+		out[i] = list.RegisteredDomain(d)
+		if out[i] != "" {
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return out
+}
+
+// rollupIPs maps each address to its containing prefix. Addresses that can't
+// be parsed, or whose family has no rollup configured, are kept as-is.
+func rollupIPs(ips []string, v4Bits, v6Bits int) []string {
+	out := make([]string, 0, len(ips))
+	for _, s := range ips {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			out = append(out, s)
+			continue
+		}
+		addr = addr.Unmap()
+		bits := v6Bits
+		if addr.Is4() {
+			bits = v4Bits
+		}
+		if bits <= 0 {
+			out = append(out, addr.String())
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			out = append(out, addr.String())
+			continue
+		}
+		out = append(out, prefix.String())
+	}
+	return out
+}
+
+// join joins the values of a multi-value column with Options.Sep.
+func (w *Writer) join(items []string) string {
+	sep := w.opts.Sep
+	if sep == "" {
+		sep = ","
+	}
+	return strings.Join(items, sep)
+}
+
 func sanitizeSlice(ss []string) []string {
 	out := make([]string, len(ss))
 	for i, s := range ss {
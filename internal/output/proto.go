@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// Protobuf wire types used by the -proto encoding.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// writeProto emits result as a varint length-prefixed CertResult message
+// (proto/cert_result.proto). Text fields are sanitized as in JSON output.
+func (w *Writer) writeProto(result *ctlog.CertResult) {
+	if !w.claim(certKey("j:", result)) {
+		return
+	}
+
+	var discoveredAt time.Time
+	if w.opts.Timestamps {
+		discoveredAt = time.Now()
+	}
+	msg := appendProtoResult(nil, result, discoveredAt)
+	w.bw.Write(binary.AppendUvarint(nil, uint64(len(msg))))
+	w.bw.Write(msg)
+}
+
+// appendProtoResult appends the CertResult message encoding of result to
+// b, without a length prefix. A zero discoveredAt leaves the field out.
+func appendProtoResult(b []byte, result *ctlog.CertResult, discoveredAt time.Time) []byte {
+	domains := sanitizeSlice(result.Domains)
+	b = protoInt(b, 1, result.Index)
+	b = protoString(b, 2, result.LogURL)
+	b = protoTime(b, 3, result.Timestamp)
+	b = protoStrings(b, 4, domains)
+	b = protoStrings(b, 5, registeredDomains(domains))
+	b = protoStrings(b, 6, result.IPs)
+	b = protoStrings(b, 7, sanitizeSlice(result.Emails))
+	b = protoString(b, 8, Sanitize(result.CommonName))
+	b = protoString(b, 9, Sanitize(result.Issuer))
+	b = protoTime(b, 10, result.NotBefore)
+	b = protoTime(b, 11, result.NotAfter)
+	b = protoString(b, 12, result.Serial)
+	b = protoBool(b, 13, result.IsPrecert)
+	b = protoBool(b, 14, result.IsCA)
+	b = protoString(b, 15, result.IssuerKeyHash)
+	b = protoStrings(b, 16, result.KeyUsage)
+	b = protoStrings(b, 17, result.ExtKeyUsage)
+	b = protoStrings(b, 18, result.Policies)
+	b = protoString(b, 19, result.ValidationLevel)
+	b = protoStrings(b, 20, sanitizeSlice(result.OCSP))
+	b = protoStrings(b, 21, sanitizeSlice(result.CRL))
+	b = protoBool(b, 22, result.ExpiredWhenLogged)
+	b = protoStrings(b, 23, result.SCTLogIDs)
+	b = protoBool(b, 24, result.CNOnly)
+	b = protoBytes(b, 25, result.DER)
+	b = protoBytes(b, 26, result.PrecertTBS)
+	b = protoString(b, 27, result.MatchedFilter)
+	b = protoString(b, 28, Sanitize(result.MatchedDomain))
+	b = protoTime(b, 29, discoveredAt)
+	b = protoBool(b, 30, result.BrandEmbedded)
+	return b
+}
+
+// The helpers below skip zero values, as proto3 does for scalar fields.
+
+func protoTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func protoInt(b []byte, field int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return binary.AppendUvarint(protoTag(b, field, wireVarint), uint64(v))
+}
+
+func protoBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return append(protoTag(b, field, wireVarint), 1)
+}
+
+func protoTime(b []byte, field int, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	return protoInt(b, field, t.UnixMilli())
+}
+
+func protoBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(protoTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func protoString(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = binary.AppendUvarint(protoTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// protoStrings writes one field per element, including empty ones, so
+// the list keeps its length.
+func protoStrings(b []byte, field int, vs []string) []byte {
+	for _, v := range vs {
+		b = binary.AppendUvarint(protoTag(b, field, wireBytes), uint64(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// Column widths for table output. Longer values are cut with "..".
+const (
+	tableCNWidth     = 40
+	tableIssuerWidth = 30
+)
+
+var tableFormat = fmt.Sprintf("%%-%ds  %%-%ds  %%-10s  %%s\n", tableCNWidth, tableIssuerWidth)
+
+// writeTableRow renders one certificate as an aligned row, printing the
+// header before the first one.
+func (w *Writer) writeTableRow(result *ctlog.CertResult) {
+	key := fmt.Sprintf("tb:%s:%d", result.LogURL, result.Index)
+	if !w.claim(key) {
+		return
+	}
+
+	if !w.tableHeader {
+		w.tableHeader = true
+		fmt.Fprintf(w.bw, tableFormat, "COMMON NAME", "ISSUER", "NOT AFTER", "DOMAINS")
+	}
+	fmt.Fprintf(w.bw, tableFormat,
+		cell(Sanitize(result.CommonName), tableCNWidth),
+		cell(Sanitize(result.Issuer), tableIssuerWidth),
+		result.NotAfter.Format("2006-01-02"),
+		fmt.Sprint(len(result.Domains)),
+	)
+}
+
+func cell(s string, width int) string {
+	if s == "" {
+		return "-"
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-2]) + ".."
+}
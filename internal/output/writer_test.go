@@ -2,12 +2,18 @@ package output
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
 )
@@ -48,6 +54,90 @@ func TestWriter_DomainDedup(t *testing.T) {
 	}
 }
 
+func TestWriter_DedupStats(t *testing.T) {
+	w, err := NewWriter(filepath.Join(t.TempDir(), "out.txt"), false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.WriteResult(testResult([]string{"example.com", "sub.example.com"}))
+	w.WriteResult(testResult([]string{"example.com", "www.example.com"}))
+	if emitted, offered := w.DedupStats(); emitted != 3 || offered != 4 {
+		t.Errorf("DedupStats() = (%d, %d), want (3, 4)", emitted, offered)
+	}
+}
+
+func TestWriter_MaxPerDomain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{MaxPerDomain: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, names := range [][]string{
+		{"a.saas.example.com"},
+		{"b.saas.example.com"},
+		{"c.saas.example.com"},
+		{"www.example.com"},
+	} {
+		r := testResult(names)
+		if names[0] != "www.example.com" {
+			r.MatchedName = "saas.example.com"
+		}
+		w.WriteResult(r)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 3 || lines[2] != "www.example.com" {
+		t.Errorf("expected a, b and www, got %v", lines)
+	}
+	if n := w.CapSkipped(); n != 1 {
+		t.Errorf("CapSkipped() = %d, want 1", n)
+	}
+}
+
+func TestWriter_MaxPerDomainCountsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{MaxPerDomain: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"a.example.com", "b.example.com", "c.example.com"})
+	r.MatchedName = "a.example.com"
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := nonEmptyLines(string(data)); len(lines) != 2 {
+		t.Errorf("one certificate wrote %v past a cap of 2 lines", lines)
+	}
+}
+
+func TestWriter_ExportSeen(t *testing.T) {
+	dir := t.TempDir()
+	seenPath := filepath.Join(dir, "seen.txt")
+	w, err := NewWriterWithOptions(filepath.Join(dir, "out.txt"), false, "all", Options{ExportSeen: seenPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"www.example.com", "example.com"}))
+	w.WriteResult(testResult([]string{"example.com"}))
+	w.Close()
+
+	data, err := os.ReadFile(seenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.2.3.4", "admin@example.com", "example.com", "www.example.com"}
+	if got := nonEmptyLines(string(data)); !slices.Equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+}
+
 func TestWriter_IPOutput(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "out.txt")
@@ -85,6 +175,28 @@ func TestWriter_EmailOutput(t *testing.T) {
 	}
 }
 
+func TestWriter_FoldCase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "emails", Options{FoldCase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"Admin@Example.com", "admin@example.com", "ADMIN@EXAMPLE.COM", "other@example.com"} {
+		r := testResult([]string{"example.com"})
+		r.Emails = []string{email}
+		w.WriteResult(r)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 2 || lines[0] != "Admin@Example.com" || lines[1] != "other@example.com" {
+		t.Errorf("expected [Admin@Example.com other@example.com], got %v", lines)
+	}
+}
+
 func TestWriter_AllFields(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "out.txt")
@@ -125,6 +237,53 @@ func TestWriter_CertLine(t *testing.T) {
 	}
 }
 
+func TestWriter_UsageLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriter(path, false, "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com"})
+	r.KeyUsage = []string{"digitalSignature"}
+	r.ExtKeyUsage = []string{"serverAuth", "clientAuth"}
+	w.WriteResult(r)
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 usage line, got %d: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "ku=digitalSignature") || !strings.Contains(lines[0], "eku=serverAuth,clientAuth") {
+		t.Errorf("usage line missing key usages: %q", lines[0])
+	}
+}
+
+func TestWriter_PolicyLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriter(path, false, "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com"})
+	r.Policies = []string{"2.23.140.1.2.2", "1.3.6.1.4.1.99999.1"}
+	r.ValidationLevel = "OV"
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	want := "example.com level=OV policies=2.23.140.1.2.2,1.3.6.1.4.1.99999.1 domains=example.com"
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("expected [%s], got %v", want, lines)
+	}
+}
+
 func TestWriter_JSONMode(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "out.json")
@@ -155,6 +314,67 @@ func TestWriter_JSONMode(t *testing.T) {
 	}
 }
 
+func TestWriter_JSONMode_Raw(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	w, err := NewWriter(path, true, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := testResult([]string{"example.com"})
+	cert.DER = []byte{0x30, 0x01, 0x02}
+	precert := testResult([]string{"example.com"})
+	precert.Serial = "def456"
+	precert.IsPrecert = true
+	precert.PrecertTBS = []byte{0x30, 0x03}
+	w.WriteResult(cert)
+	w.WriteResult(precert)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %d", len(lines))
+	}
+	var jr JSONResult
+	if err := json.Unmarshal([]byte(lines[0]), &jr); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if jr.DERB64 != "MAEC" || jr.PrecertTBSB64 != "" {
+		t.Errorf("cert der_b64 = %q, precert_tbs_b64 = %q, want MAEC and empty", jr.DERB64, jr.PrecertTBSB64)
+	}
+	jr = JSONResult{}
+	if err := json.Unmarshal([]byte(lines[1]), &jr); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if jr.DERB64 != "" || jr.PrecertTBSB64 != "MAM=" {
+		t.Errorf("precert der_b64 = %q, precert_tbs_b64 = %q, want empty and MAM=", jr.DERB64, jr.PrecertTBSB64)
+	}
+}
+
+func TestWriter_JSONMode_RegisteredDomain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	w, err := NewWriter(path, true, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteResult(testResult([]string{"www.example.co.uk", "*.user.github.io", "co.uk"}))
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	var jr JSONResult
+	if err := json.Unmarshal(bytes.TrimSpace(data), &jr); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := []string{"example.co.uk", "user.github.io", ""}
+	if strings.Join(jr.RegisteredDomain, "|") != strings.Join(want, "|") {
+		t.Errorf("RegisteredDomain = %q, want %q", jr.RegisteredDomain, want)
+	}
+}
+
 func TestWriter_JSONMode_EmptySerial(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "out.json")
@@ -244,3 +464,699 @@ func nonEmptyLines(s string) []string {
 	}
 	return lines
 }
+
+func TestWriter_IPRollup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "ips", Options{RollupV4Bits: 24, RollupV6Bits: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com"})
+	r.IPs = []string{"10.0.0.1", "10.0.0.200", "10.0.1.5", "2001:db8::1", "2001:db8::2"}
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	want := []string{"10.0.0.0/24", "10.0.1.0/24", "2001:db8::/64"}
+	if strings.Join(lines, " ") != strings.Join(want, " ") {
+		t.Errorf("rolled-up IPs = %v, want %v", lines, want)
+	}
+}
+
+func TestWriter_Timestamps(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteResult(testResult([]string{"example.com"}))
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %v", len(lines), lines)
+	}
+	ts, domain, ok := strings.Cut(lines[0], " ")
+	if !ok || domain != "example.com" {
+		t.Fatalf("unexpected line %q", lines[0])
+	}
+	if _, err := time.Parse(time.RFC3339, ts); err != nil {
+		t.Errorf("line prefix %q is not RFC3339: %v", ts, err)
+	}
+}
+
+func TestWriter_JSONTimestamps(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	w, err := NewWriterWithOptions(path, true, "domains", Options{Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteResult(testResult([]string{"example.com"}))
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	var jr JSONResult
+	if err := json.Unmarshal(bytes.TrimSpace(data), &jr); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339, jr.DiscoveredAt); err != nil {
+		t.Errorf("discovered_at %q is not RFC3339: %v", jr.DiscoveredAt, err)
+	}
+}
+
+func TestWriter_FlushInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.WriteResult(testResult([]string{"example.com"}))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		data, _ := os.ReadFile(path)
+		if bytes.Contains(data, []byte("example.com")) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected timed flush to write buffered output")
+}
+
+func TestWriter_BufferSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{BufferSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.bw.Size(); got != 1<<20 {
+		t.Errorf("buffer size = %d, want %d", got, 1<<20)
+	}
+
+	w.WriteResult(testResult([]string{"example.com"}))
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("output written before flush: %q", data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "example.com\n" {
+		t.Errorf("output = %q, want example.com after close", data)
+	}
+}
+
+// BenchmarkWriter_BufferSize writes JSON results to a file with the default
+// write buffer and with larger -output-buffer-size values.
+func BenchmarkWriter_BufferSize(b *testing.B) {
+	for _, size := range []int{0, 64 << 10, 1 << 20} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			w, err := NewWriterWithOptions(filepath.Join(b.TempDir(), "out.json"), true, "", Options{BufferSize: size, FileOnly: true})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer w.Close()
+			r := testResult([]string{"www.example.com", "example.com", "api.example.com"})
+			r.Serial = ""
+			for i := int64(0); b.Loop(); i++ {
+				r.Index = i
+				w.WriteResult(r)
+			}
+		})
+	}
+}
+
+func TestWriter_Template(t *testing.T) {
+	tmpl, err := ParseTemplate("{{.CommonName}} {{.NotAfter.Format \"2006-01-02\"}} {{range .Domains}}{{.}} {{end}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{Template: tmpl})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com", "www.example.com"})
+	w.WriteResult(r)
+	w.WriteResult(r)
+	r.CommonName = "evil\x1b[31m.com"
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 template lines, got %d: %v", len(lines), lines)
+	}
+	if lines[0] != "example.com 2025-12-31 example.com www.example.com " {
+		t.Errorf("unexpected template line %q", lines[0])
+	}
+	if strings.Contains(lines[1], "\x1b") {
+		t.Errorf("template output not sanitized: %q", lines[1])
+	}
+}
+
+func TestWriter_Table(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{Table: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com", "www.example.com"})
+	w.WriteResult(r)
+	w.WriteResult(r)
+	r2 := testResult([]string{"long.example.com"})
+	r2.Index = 2
+	r2.CommonName = strings.Repeat("a", 60) + ".example.com"
+	w.WriteResult(r2)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d: %v", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[0], "COMMON NAME") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	fields := strings.Fields(lines[1])
+	if want := []string{"example.com", "Test", "CA", "2025-12-31", "2"}; strings.Join(fields, " ") != strings.Join(want, " ") {
+		t.Errorf("row fields = %v, want %v", fields, want)
+	}
+	col := strings.Index(lines[0], "NOT AFTER")
+	if strings.Index(lines[1], "2025-12-31") != col || strings.Index(lines[2], "2025-12-31") != col {
+		t.Errorf("rows not aligned with header:\n%s", strings.Join(lines, "\n"))
+	}
+	if !strings.Contains(lines[2], "..") {
+		t.Errorf("long common name not truncated: %q", lines[2])
+	}
+}
+
+func TestCell_MultiByte(t *testing.T) {
+	got := cell(strings.Repeat("ü", 50)+".example.com", tableCNWidth)
+	if !utf8.ValidString(got) {
+		t.Errorf("cell() split a rune: %q", got)
+	}
+	if n := utf8.RuneCountInString(got); n != tableCNWidth {
+		t.Errorf("cell() is %d runes wide, want %d", n, tableCNWidth)
+	}
+	if got := cell("zürich.example.ch", 20); got != "zürich.example.ch" {
+		t.Errorf("cell() = %q, want short value unchanged", got)
+	}
+}
+
+func TestWriter_GroupByCert(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{GroupByCert: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com", "www.example.com"})
+	w.WriteResult(r)
+	w.WriteResult(r)
+	r2 := testResult([]string{"other.com"})
+	r2.Index = 2
+	r2.IPs = nil
+	r2.Emails = []string{"a\tb@other.com"}
+	w.WriteResult(r2)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []string{
+		"abc123\texample.com\texample.com,www.example.com\t1.2.3.4\tadmin@example.com",
+		"abc123\texample.com\tother.com\t\tab@other.com",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("grouped lines = %q, want %q", lines, want)
+	}
+}
+
+func TestParseTemplate_Invalid(t *testing.T) {
+	if _, err := ParseTemplate("{{.CommonName"); err == nil {
+		t.Error("expected parse error for unclosed action")
+	}
+	if _, err := ParseTemplate("{{.NoSuchField}}"); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	for _, text := range []string{"{{index .Domains 0}}", "{{(index .IPs 0)}} {{index .Emails 0}}"} {
+		if _, err := ParseTemplate(text); err != nil {
+			t.Errorf("ParseTemplate(%q) error: %v", text, err)
+		}
+	}
+}
+
+func TestWriter_LinkPrecerts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+	w, err := NewWriterWithOptions(path, true, "domains", Options{LinkPrecerts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pre := testResult([]string{"example.com"})
+	pre.IsPrecert = true
+	pre.Index = 10
+	pre.TBSHash = "aa"
+	final := testResult([]string{"example.com"})
+	final.Index = 12
+	final.Serial = "abc124"
+	final.TBSHash = "aa"
+	unrelated := testResult([]string{"other.com"})
+	unrelated.Serial = "def"
+	unrelated.TBSHash = "bb"
+
+	w.WriteResult(pre)
+	w.WriteResult(final)
+	w.WriteResult(unrelated)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	var got []JSONResult
+	for _, line := range lines {
+		var jr JSONResult
+		if err := json.Unmarshal([]byte(line), &jr); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, jr)
+	}
+	if got[0].FinalFor != nil || got[0].PrecertOf != nil {
+		t.Errorf("first of the pair should carry no link: %s", lines[0])
+	}
+	if got[1].FinalFor == nil || got[1].FinalFor.Index != 10 || got[1].FinalFor.LogURL != pre.LogURL {
+		t.Errorf("final cert should link to precert index 10: %s", lines[1])
+	}
+	if got[2].FinalFor != nil || got[2].PrecertOf != nil {
+		t.Errorf("unrelated cert should carry no link: %s", lines[2])
+	}
+}
+
+func TestWriter_Revocation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriter(path, false, "revocation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com"})
+	r.OCSP = []string{"http://ocsp.example.com"}
+	r.CRL = []string{"http://crl.example.com/ca.crl", "http://crl\x1b[2J.example.com"}
+	w.WriteResult(r)
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 revocation lines, got %d: %v", len(lines), lines)
+	}
+	if lines[0] != "ocsp http://ocsp.example.com" || lines[1] != "crl http://crl.example.com/ca.crl" {
+		t.Errorf("unexpected revocation lines: %v", lines)
+	}
+	if strings.Contains(lines[2], "\x1b") {
+		t.Errorf("revocation URL not sanitized: %q", lines[2])
+	}
+}
+
+func TestWriter_Serials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriter(path, false, "serials")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := testResult([]string{"example.com"})
+	w.WriteResult(r)
+	r.Index = 2
+	w.WriteResult(r)
+	r.Serial = "def456"
+	w.WriteResult(r)
+	r.Serial = ""
+	w.WriteResult(r)
+	w.WriteResult(r)
+	r.Index = 3
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := nonEmptyLines(string(data))
+	want := "abc123,def456,idx:2:https://ct.example.com/log/,idx:3:https://ct.example.com/log/"
+	if strings.Join(lines, ",") != want {
+		t.Errorf("serial lines = %v, want %s", lines, want)
+	}
+}
+
+func TestWriter_Append(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(path, []byte("example.com\nold.example.com"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWriterWithOptions(path, false, "domains", Options{Append: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"example.com", "new.example.com"}))
+	if got := w.Stats(); got != 1 {
+		t.Errorf("Stats() = %d, want 1 new result", got)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	if want := "example.com\nold.example.com\nnew.example.com\n"; string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+}
+
+func TestWriter_AppendJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.json")
+
+	w, err := NewWriter(path, true, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"example.com"}))
+	w.Close()
+
+	w, err = NewWriterWithOptions(path, true, "domains", Options{Append: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"example.com"}))
+	other := testResult([]string{"example.com"})
+	other.Serial = "def456"
+	w.WriteResult(other)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	if lines := nonEmptyLines(string(data)); len(lines) != 2 {
+		t.Errorf("expected 2 JSON lines after resumed run, got %d", len(lines))
+	}
+}
+
+func TestWriter_SeedDedup(t *testing.T) {
+	dir := t.TempDir()
+	seed := filepath.Join(dir, "scrape.txt")
+	if err := os.WriteFile(seed, []byte("example.com\nold.example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "monitor.txt")
+
+	w, err := NewWriterWithOptions(path, false, "domains", Options{SeedDedup: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"old.example.com", "new.example.com"}))
+	if got := w.Stats(); got != 1 {
+		t.Errorf("Stats() = %d, want 1 new result", got)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	if want := "new.example.com\n"; string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+	if data, _ := os.ReadFile(seed); string(data) != "example.com\nold.example.com\n" {
+		t.Errorf("seed file changed: %q", data)
+	}
+
+	if _, err := NewWriterWithOptions("", false, "domains", Options{SeedDedup: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected an error for a missing seed file")
+	}
+}
+
+func TestWriter_Sep(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	w, err := NewWriterWithOptions(path, false, "certs", Options{Sep: "\t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.WriteResult(testResult([]string{"example.com", "www.example.com"}))
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "domains=example.com\twww.example.com") {
+		t.Errorf("cert line does not use the separator: %q", data)
+	}
+}
+
+func TestWriter_WriteMeta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	w, err := NewWriter(path, true, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteMeta(Meta{LogURL: "https://log.example.com/", Start: 0, End: 99, TreeSize: 100, ToolVersion: "v1.2.3"}); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteResult(testResult([]string{"example.com"}))
+	w.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want meta + 1 result", len(lines))
+	}
+	var meta struct {
+		Meta Meta `json:"_meta"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Meta.LogURL != "https://log.example.com/" || meta.Meta.End != 99 || meta.Meta.ToolVersion != "v1.2.3" {
+		t.Errorf("meta = %+v", meta.Meta)
+	}
+	if w.lineKeys(lines[0]) != nil {
+		t.Error("meta line should not produce dedup keys")
+	}
+}
+
+func TestWriter_Proto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.pb")
+	w, err := NewWriterWithOptions(path, false, "domains", Options{Proto: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testResult([]string{"example.com", "www.example.com"})
+	r.Index = 300
+	w.WriteResult(r)
+	w.WriteResult(r)
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	size, n := binary.Uvarint(data)
+	if n <= 0 || int(size) != len(data)-n {
+		t.Fatalf("length prefix %d does not cover the %d-byte message (one result expected)", size, len(data)-n)
+	}
+
+	// Collect varint and length-delimited fields by number.
+	ints := make(map[uint64]uint64)
+	strs := make(map[uint64][]string)
+	for msg := data[n:]; len(msg) > 0; {
+		tag, k := binary.Uvarint(msg)
+		msg = msg[k:]
+		v, k := binary.Uvarint(msg)
+		msg = msg[k:]
+		switch tag & 7 {
+		case 0:
+			ints[tag>>3] = v
+		case 2:
+			strs[tag>>3] = append(strs[tag>>3], string(msg[:v]))
+			msg = msg[v:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	if ints[1] != 300 {
+		t.Errorf("index = %d, want 300", ints[1])
+	}
+	if got := strs[4]; len(got) != 2 || got[0] != "example.com" || got[1] != "www.example.com" {
+		t.Errorf("domains = %v", got)
+	}
+	if got := strs[8]; len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("common_name = %v", got)
+	}
+	if ints[11] != uint64(r.NotAfter.UnixMilli()) {
+		t.Errorf("not_after_ms = %d, want %d", ints[11], r.NotAfter.UnixMilli())
+	}
+	if _, ok := ints[13]; ok {
+		t.Error("is_precert written although false")
+	}
+}
+
+// TestProto_MatchesSchema decodes a fully populated message field by field
+// and checks each field against its number and type in
+// proto/cert_result.proto. The encoder is written by hand, so nothing else
+// keeps the two in step.
+func TestProto_MatchesSchema(t *testing.T) {
+	schemaText, err := os.ReadFile(filepath.Join("..", "..", "proto", "cert_result.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type protoField struct {
+		number   uint64
+		typ      string
+		repeated bool
+	}
+	schema := make(map[string]protoField)
+	fieldRe := regexp.MustCompile(`^\s*(repeated\s+)?(\w+)\s+(\w+)\s*=\s*(\d+);`)
+	for _, line := range strings.Split(string(schemaText), "\n") {
+		if m := fieldRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.ParseUint(m[4], 10, 64)
+			schema[m[3]] = protoField{number: n, typ: m[2], repeated: m[1] != ""}
+		}
+	}
+	if len(schema) == 0 {
+		t.Fatal("no fields found in cert_result.proto")
+	}
+
+	logged := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	discovered := logged.Add(time.Minute)
+	result := &ctlog.CertResult{
+		Index:             42,
+		LogURL:            "https://ct.example.com/log/",
+		Timestamp:         logged,
+		Domains:           []string{"www.example.com", "api.example.org"},
+		IPs:               []string{"192.0.2.1"},
+		Emails:            []string{"admin@example.com"},
+		CommonName:        "www.example.com",
+		Issuer:            "Test CA",
+		NotBefore:         logged.Add(-time.Hour),
+		NotAfter:          logged.Add(90 * 24 * time.Hour),
+		Serial:            "3039",
+		IsPrecert:         true,
+		IsCA:              true,
+		IssuerKeyHash:     "aabbcc",
+		KeyUsage:          []string{"digitalSignature"},
+		ExtKeyUsage:       []string{"serverAuth", "clientAuth"},
+		Policies:          []string{"2.23.140.1.2.1"},
+		ValidationLevel:   "DV",
+		OCSP:              []string{"http://ocsp.example.com"},
+		CRL:               []string{"http://crl.example.com/ca.crl"},
+		ExpiredWhenLogged: true,
+		SCTLogIDs:         []string{"bG9nMQ=="},
+		CNOnly:            true,
+		DER:               []byte{0x30, 0x82, 0x01},
+		PrecertTBS:        []byte{0x30, 0x81},
+		MatchedFilter:     "example.com",
+		MatchedDomain:     "www.example.com",
+		BrandEmbedded:     true,
+	}
+	want := map[string]any{
+		"index":               uint64(42),
+		"log_url":             []string{"https://ct.example.com/log/"},
+		"timestamp_ms":        uint64(logged.UnixMilli()),
+		"domains":             []string{"www.example.com", "api.example.org"},
+		"registered_domains":  []string{"example.com", "example.org"},
+		"ips":                 []string{"192.0.2.1"},
+		"emails":              []string{"admin@example.com"},
+		"common_name":         []string{"www.example.com"},
+		"issuer":              []string{"Test CA"},
+		"not_before_ms":       uint64(result.NotBefore.UnixMilli()),
+		"not_after_ms":        uint64(result.NotAfter.UnixMilli()),
+		"serial":              []string{"3039"},
+		"is_precert":          uint64(1),
+		"is_ca":               uint64(1),
+		"issuer_key_hash":     []string{"aabbcc"},
+		"key_usage":           []string{"digitalSignature"},
+		"ext_key_usage":       []string{"serverAuth", "clientAuth"},
+		"policies":            []string{"2.23.140.1.2.1"},
+		"validation_level":    []string{"DV"},
+		"ocsp":                []string{"http://ocsp.example.com"},
+		"crl":                 []string{"http://crl.example.com/ca.crl"},
+		"expired_when_logged": uint64(1),
+		"sct_log_ids":         []string{"bG9nMQ=="},
+		"cn_only":             uint64(1),
+		"der":                 []string{"\x30\x82\x01"},
+		"precert_tbs":         []string{"\x30\x81"},
+		"matched_filter":      []string{"example.com"},
+		"matched_domain":      []string{"www.example.com"},
+		"discovered_at_ms":    uint64(discovered.UnixMilli()),
+		"brand_embedded":      uint64(1),
+	}
+
+	wire := make(map[uint64]uint64)
+	ints := make(map[uint64]uint64)
+	strs := make(map[uint64][]string)
+	for msg := appendProtoResult(nil, result, discovered); len(msg) > 0; {
+		tag, k := binary.Uvarint(msg)
+		msg = msg[k:]
+		v, k := binary.Uvarint(msg)
+		msg = msg[k:]
+		wire[tag>>3] = tag & 7
+		switch tag & 7 {
+		case 0:
+			ints[tag>>3] = v
+		case 2:
+			strs[tag>>3] = append(strs[tag>>3], string(msg[:v]))
+			msg = msg[v:]
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", tag>>3, tag&7)
+		}
+	}
+
+	numbers := make(map[uint64]bool)
+	for name, f := range schema {
+		numbers[f.number] = true
+		expected, ok := want[name]
+		if !ok {
+			t.Errorf("%s = %d: in the schema but not covered by this test", name, f.number)
+			continue
+		}
+		switch f.typ {
+		case "int64", "bool":
+			if f.repeated || wire[f.number] != 0 || ints[f.number] != expected {
+				t.Errorf("%s = %d: got varint %d (wire type %d), want %v", name, f.number, ints[f.number], wire[f.number], expected)
+			}
+		case "string", "bytes":
+			if wire[f.number] != 2 || !slices.Equal(strs[f.number], expected.([]string)) {
+				t.Errorf("%s = %d: got %q (wire type %d), want %q", name, f.number, strs[f.number], wire[f.number], expected)
+			}
+			if !f.repeated && len(strs[f.number]) > 1 {
+				t.Errorf("%s = %d: singular field written %d times", name, f.number, len(strs[f.number]))
+			}
+		default:
+			t.Errorf("%s = %d: type %s has no check here", name, f.number, f.typ)
+		}
+	}
+	for name := range want {
+		if _, ok := schema[name]; !ok {
+			t.Errorf("%s: expected by this test but missing from the schema", name)
+		}
+	}
+	for number := range wire {
+		if !numbers[number] {
+			t.Errorf("field %d written but not in the schema", number)
+		}
+	}
+}
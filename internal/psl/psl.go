@@ -0,0 +1,182 @@
+package psl
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// embeddedList is the upstream public_suffix_list.dat, unmodified. To
+// refresh it, replace the file with a current copy from
+// https://publicsuffix.org/list/public_suffix_list.dat and run the tests.
+//
+//go:embed public_suffix_list.dat
+var embeddedList string
+
+type ruleKind uint8
+
+const (
+	ruleNormal ruleKind = iota + 1
+	ruleWildcard
+	ruleException
+)
+
+// List is a parsed set of public suffix rules.
+type List struct {
+	rules map[string]ruleKind
+}
+
+var (
+	defaultOnce sync.Once
+	defaultList *List
+)
+
+// Default returns the list set by SetDefault, or else the one embedded in
+// the binary. If the embedded list can't be read, it returns Fallback.
+func Default() *List {
+	defaultOnce.Do(func() {
+		l, err := Parse(strings.NewReader(embeddedList))
+		if err != nil || l.Len() == 0 {
+			l = Fallback()
+		}
+		defaultList = l
+	})
+	return defaultList
+}
+
+// SetDefault makes Default return l. Call it before Default is first
+// used, e.g. at startup with a list from Load.
+func SetDefault(l *List) {
+	defaultOnce.Do(func() {})
+	defaultList = l
+}
+
+// Fallback returns a list without rules, so only the implicit "*" rule
+// applies and the registered domain is the last two labels. That is
+// wrong for suffixes like co.uk or github.io but needs no data.
+func Fallback() *List {
+	return &List{rules: make(map[string]ruleKind)}
+}
+
+// Load reads a list file in the publicsuffix.org format, such as a fresh
+// copy of public_suffix_list.dat.
+func Load(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	if l.Len() == 0 {
+		return nil, errors.New("no rules found")
+	}
+	return l, nil
+}
+
+// Parse reads rules in the publicsuffix.org list format.
+func Parse(r io.Reader) (*List, error) {
+	l := &List{rules: make(map[string]ruleKind)}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(line, "!"):
+			l.rules[line[1:]] = ruleException
+		case strings.HasPrefix(line, "*."):
+			l.rules[line[2:]] = ruleWildcard
+		default:
+			if _, exists := l.rules[line]; !exists {
+				l.rules[line] = ruleNormal
+			}
+		}
+	}
+	return l, scanner.Err()
+}
+
+// Len returns the number of rules in the list.
+func (l *List) Len() int {
+	return len(l.rules)
+}
+
+// PublicSuffix returns the public suffix of name, applying the implicit "*"
+// rule when nothing else matches.
+func (l *List) PublicSuffix(name string) string {
+	labels := strings.Split(name, ".")
+	suffixLen := 1
+
+	for i := len(labels) - 1; i >= 0; i-- {
+		candidate := strings.Join(labels[i:], ".")
+		switch l.rules[candidate] {
+		case ruleException:
+			return strings.Join(labels[i+1:], ".")
+		case ruleNormal:
+			suffixLen = len(labels) - i
+		case ruleWildcard:
+			if i > 0 {
+				if l.rules[strings.Join(labels[i-1:], ".")] == ruleException {
+					return candidate
+				}
+				suffixLen = len(labels) - i + 1
+			}
+		}
+	}
+	return strings.Join(labels[len(labels)-suffixLen:], ".")
+}
+
+// RegisteredDomain returns the eTLD+1 of name (e.g. example.co.uk for
+// www.example.co.uk). A leading wildcard label is ignored. It returns "" for
+// IP addresses, public suffixes themselves and names that aren't valid
+// hostnames.
+func (l *List) RegisteredDomain(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	name = strings.TrimPrefix(name, "*.")
+	if !validHostname(name) {
+		return ""
+	}
+	if _, err := netip.ParseAddr(name); err == nil {
+		return ""
+	}
+
+	suffix := l.PublicSuffix(name)
+	if len(suffix) >= len(name) {
+		return ""
+	}
+	rest := name[:len(name)-len(suffix)-1]
+	if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return rest + "." + suffix
+}
+
+func validHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c >= 0x80) {
+				return false
+			}
+		}
+	}
+	return true
+}
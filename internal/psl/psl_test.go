@@ -0,0 +1,114 @@
+package psl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisteredDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"www.example.com", "example.com"},
+		{"a.b.c.example.com", "example.com"},
+		{"*.example.com", "example.com"},
+		{"WWW.Example.COM.", "example.com"},
+		{"www.example.co.uk", "example.co.uk"},
+		{"example.co.uk", "example.co.uk"},
+		{"co.uk", ""},
+		{"com", ""},
+		{"user.github.io", "user.github.io"},
+		{"project.user.github.io", "user.github.io"},
+		{"github.io", ""},
+		{"foo.bar.ck", "foo.bar.ck"},
+		{"www.ck", "www.ck"},
+		{"a.www.ck", "www.ck"},
+		{"example.unlisted-tld", "example.unlisted-tld"},
+		{"shop.example.co.at", "example.co.at"},
+		{"www.example.com.es", "example.com.es"},
+		{"www.example.com.pl", "example.com.pl"},
+		{"10.0.0.1", ""},
+		{"2001:db8::1", ""},
+		{"", ""},
+		{"bad..name.com", ""},
+		{"has space.com", ""},
+	}
+
+	l := Default()
+	for _, tt := range tests {
+		if got := l.RegisteredDomain(tt.name); got != tt.want {
+			t.Errorf("RegisteredDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	l, err := Parse(strings.NewReader("// comment\n\nfoo.test\n*.wild.test\n!keep.wild.test other-stuff\n"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if l.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", l.Len())
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a.b.foo.test", "b.foo.test"},
+		{"a.b.wild.test", "a.b.wild.test"},
+		{"x.keep.wild.test", "keep.wild.test"},
+	}
+	for _, tt := range tests {
+		if got := l.RegisteredDomain(tt.name); got != tt.want {
+			t.Errorf("RegisteredDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	l := Fallback()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"www.example.com", "example.com"},
+		{"www.example.co.uk", "co.uk"},
+		{"com", ""},
+	}
+	for _, tt := range tests {
+		if got := l.RegisteredDomain(tt.name); got != tt.want {
+			t.Errorf("RegisteredDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "list.dat")
+	if err := os.WriteFile(path, []byte("// test list\nco.uk\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if got := l.RegisteredDomain("www.example.co.uk"); got != "example.co.uk" {
+		t.Errorf("RegisteredDomain() = %q, want example.co.uk", got)
+	}
+
+	empty := filepath.Join(dir, "empty.dat")
+	if err := os.WriteFile(empty, []byte("// nothing here\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(empty); err == nil {
+		t.Error("expected an error for a list without rules")
+	}
+	if _, err := Load(filepath.Join(dir, "missing.dat")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
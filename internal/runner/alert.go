@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/TheArqsz/ct-hulhu/internal/certparser"
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+	"github.com/TheArqsz/ct-hulhu/internal/output"
+)
+
+// alertOutput is the -alert-output file of monitor mode: results that also
+// match the smaller -alert-domains watchlist, in the main output format.
+// It has its own dedup set, so a value printed on stdout still makes it
+// into the alert file.
+type alertOutput struct {
+	path   string
+	parser *certparser.Parser
+	writer *output.Writer
+}
+
+func (r *Runner) openAlertOutput() (*alertOutput, error) {
+	opts := r.writerOptions()
+	opts.FileOnly = true
+	opts.Table = false
+	opts.Append = false
+	opts.MaxPerDomain = 0
+	opts.ExportSeen = ""
+	opts.SeedDedup = ""
+	writer, err := output.NewWriterWithOptions(r.opts.AlertOutput, r.opts.JSON, r.opts.Fields, opts)
+	if err != nil {
+		return nil, err
+	}
+	parser := certparser.NewWithOptions(r.opts.AlertDomains, certparser.Options{
+		ExactMatch:     r.opts.ExactMatch,
+		SubdomainsOnly: r.opts.SubdomainsOnly,
+	})
+	return &alertOutput{path: r.opts.AlertOutput, parser: parser, writer: writer}, nil
+}
+
+// unreachableAlertDomains lists the alert domains that parser, built from
+// the -d filters, would never let through. Neither the name itself nor a
+// subdomain of it matches.
+func unreachableAlertDomains(parser *certparser.Parser, alertDomains []string) []string {
+	var out []string
+	for _, d := range alertDomains {
+		name := strings.ToLower(strings.TrimPrefix(d, "*."))
+		probe := &ctlog.CertResult{Domains: []string{name, "x." + name}}
+		if !parser.MatchesDomain(probe) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (a *alertOutput) send(result *ctlog.CertResult) {
+	if a == nil || !a.parser.MatchesDomain(result) {
+		return
+	}
+	a.writer.WriteResult(result)
+}
+
+func (a *alertOutput) flush() {
+	if a == nil {
+		return
+	}
+	a.writer.Flush()
+}
+
+func (a *alertOutput) close() {
+	if a == nil {
+		return
+	}
+	a.writer.Close()
+	log.Info("-alert-output: %d results written to %s", a.writer.Stats(), a.path)
+}
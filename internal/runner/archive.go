@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// archivedLeaf is the file -archive-leaves writes per matching entry: the
+// log's own base64 fields, byte for byte as served.
+type archivedLeaf struct {
+	LogURL    string `json:"log_url"`
+	Index     int64  `json:"index"`
+	LeafInput string `json:"leaf_input"`
+	ExtraData string `json:"extra_data"`
+}
+
+// leafArchive keeps the raw CT evidence for matching entries in a
+// directory, one file per entry, until maxBytes have been written.
+type leafArchive struct {
+	dir      string
+	maxBytes int64
+	written  atomic.Int64
+	files    atomic.Int64
+	fullOnce sync.Once
+}
+
+func newLeafArchive(dir string, maxMB int) (*leafArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &leafArchive{dir: dir, maxBytes: int64(maxMB) << 20}, nil
+}
+
+// save writes one entry. Files are named after the log and index, so
+// archiving the same entry again overwrites it with identical content.
+func (a *leafArchive) save(logURL string, index int64, e ctlog.RawEntry) {
+	if a == nil {
+		return
+	}
+	data, err := json.Marshal(archivedLeaf{LogURL: logURL, Index: index, LeafInput: e.LeafInput, ExtraData: e.ExtraData})
+	if err != nil {
+		return
+	}
+	if a.maxBytes > 0 && a.written.Add(int64(len(data))) > a.maxBytes {
+		a.fullOnce.Do(func() {
+			log.Warning("archive-leaves: reached -archive-max-mb %d MB, no further leaves are archived", a.maxBytes>>20)
+		})
+		return
+	}
+	path := filepath.Join(a.dir, fmt.Sprintf("%s_%d.json", safeLogName(logURL), index))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Warning("archive-leaves: %v", err)
+		return
+	}
+	a.files.Add(1)
+}
+
+func (a *leafArchive) report() {
+	if a == nil {
+		return
+	}
+	log.Info("archived %d leaves to %s", a.files.Load(), a.dir)
+}
@@ -0,0 +1,159 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+const autotuneBudget = 10 * time.Second
+
+var (
+	autotuneBatchSizes = []int{128, 256, 512, 1024}
+	autotuneWorkers    = []int{2, 4, 8, 16, 32}
+)
+
+type tuneResult struct {
+	workers   int
+	batchSize int
+	rate      float64
+	errorRate float64
+}
+
+// autotune runs short calibration fetches against the log, first varying the
+// batch size at a fixed worker count and then the worker count at the best
+// batch size, and returns the combination with the highest entries/sec.
+// Each trial picks up where the previous one stopped, so no trial is timed
+// against entries the log has just served from its cache. Calibration
+// entries are discarded. Trials use a client of their own without retries,
+// so they leave the run's -max-total-retries budget alone.
+func (r *Runner) autotune(ctx context.Context, logURL string, start, end int64) (workers, batchSize int) {
+	workers, batchSize = r.opts.Workers, r.opts.BatchSize
+	client := r.tuneClient(logURL)
+	trialDur := autotuneBudget / time.Duration(len(autotuneBatchSizes)+len(autotuneWorkers))
+
+	log.Info("autotune: calibrating for ~%v...", autotuneBudget)
+
+	best := tuneResult{workers: workers, batchSize: batchSize}
+	from := start
+	for _, bs := range autotuneBatchSizes {
+		var res tuneResult
+		res, from = r.tuneTrial(ctx, client, from, start, end, 4, bs, trialDur)
+		if ctx.Err() != nil {
+			return workers, batchSize
+		}
+		if betterTrial(res, best) {
+			best = res
+		}
+	}
+
+	bestBatch := best.batchSize
+	for _, w := range autotuneWorkers {
+		var res tuneResult
+		res, from = r.tuneTrial(ctx, client, from, start, end, w, bestBatch, trialDur)
+		if ctx.Err() != nil {
+			return workers, batchSize
+		}
+		if betterTrial(res, best) {
+			best = res
+		}
+	}
+
+	if best.rate == 0 {
+		log.Warning("autotune: no successful calibration runs, keeping -w %d -bs %d", workers, batchSize)
+		return workers, batchSize
+	}
+
+	log.Info("autotune: selected -w %d -bs %d (%.0f entries/sec, %.1f%% errors)",
+		best.workers, best.batchSize, best.rate, best.errorRate*100)
+	return best.workers, best.batchSize
+}
+
+// tuneClient is the client calibration trials share: the run's settings,
+// but no retries.
+func (r *Runner) tuneClient(logURL string) *ctlog.Client {
+	return r.newClientWith(logURL, time.Duration(r.opts.Timeout)*time.Second, 0)
+}
+
+// tuneTrial fetches from index from onwards for d and returns the measured
+// result and the index the next trial should start at: past the last entry
+// fetched, or back at start once the range [start, end) is used up.
+// Requests cut off by the end of the trial are not counted as errors.
+func (r *Runner) tuneTrial(ctx context.Context, client *ctlog.Client, from, start, end int64, workers, batchSize int, d time.Duration) (tuneResult, int64) {
+	trialCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	pool := r.newPool(client, batchSize, workers)
+	pool.SetInitialWorkers(workers)
+	pool.SetReverse(false)
+	pool.SetTargetRate(0)
+	var successes, errors atomic.Int64
+	pool.SetRangeLog(func(status string, _ ctlog.Range) {
+		switch {
+		case status == "fetched":
+			successes.Add(1)
+		case trialCtx.Err() == nil:
+			errors.Add(1)
+		}
+	})
+	results := make(chan ctlog.EntryBatch, workers*2)
+
+	began := time.Now()
+	go pool.FetchRange(trialCtx, from, end, results)
+
+	var entries int
+	next := from
+	for batch := range results {
+		entries += len(batch.Entries)
+		next = max(next, batch.StartIndex+int64(len(batch.Entries)))
+	}
+	if next >= end {
+		next = start
+	}
+	elapsed := time.Since(began)
+
+	res := tuneResult{workers: workers, batchSize: batchSize}
+	if total := successes.Load() + errors.Load(); total > 0 {
+		res.errorRate = float64(errors.Load()) / float64(total)
+	}
+	if elapsed > 0 {
+		res.rate = float64(entries) / elapsed.Seconds()
+	}
+	log.Debug("autotune: -w %d -bs %d -> %.0f entries/sec, %.1f%% errors",
+		workers, batchSize, res.rate, res.errorRate*100)
+	return res, next
+}
+
+// betterTrial prefers higher throughput among trials with an acceptable
+// error rate.
+func betterTrial(candidate, best tuneResult) bool {
+	if candidate.errorRate > 0.1 {
+		return false
+	}
+	return candidate.rate > best.rate
+}
+
+// probeBatchLimit asks for one batch of batchSize entries and, when the log
+// sends back fewer, lowers batchSize to that count. A log that caps
+// get-entries truncates every larger request anyway, and each truncated
+// batch costs an extra round trip for the rest. The probe starts at a
+// batch-aligned index, since some logs also cut responses short at tile
+// boundaries.
+func (r *Runner) probeBatchLimit(ctx context.Context, client *ctlog.Client, batchSize int, start, treeSize int64) int {
+	probeStart := start - start%int64(batchSize)
+	if batchSize <= 1 || probeStart+int64(batchSize) > treeSize {
+		return batchSize
+	}
+	resp, err := client.GetRawEntries(ctx, probeStart, probeStart+int64(batchSize)-1)
+	if err != nil {
+		log.Debug("batch size probe failed, keeping -bs %d: %v", batchSize, err)
+		return batchSize
+	}
+	if n := len(resp.Entries); n > 0 && n < batchSize {
+		log.Warning("log returned %d entries for a %d-entry request, lowering -bs to %d for this log", n, batchSize, n)
+		return n
+	}
+	return batchSize
+}
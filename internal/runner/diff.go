@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/output"
+)
+
+// diffOutputs compares two line output files and prints the lines only in
+// the new one as "+ line" and the lines only in the old one as "- line".
+func (r *Runner) diffOutputs() error {
+	oldSet, err := readOutputSet(r.opts.Diff)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", r.opts.Diff, err)
+	}
+	newSet, err := readOutputSet(r.opts.diffNew)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", r.opts.diffNew, err)
+	}
+
+	added := setDifference(newSet, oldSet)
+	removed := setDifference(oldSet, newSet)
+
+	if r.opts.JSON {
+		data, err := json.Marshal(map[string][]string{"added": added, "removed": removed})
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, line := range added {
+			fmt.Println("+ " + line)
+		}
+		for _, line := range removed {
+			fmt.Println("- " + line)
+		}
+	}
+	log.Info("%d added, %d removed", len(added), len(removed))
+	return nil
+}
+
+// readOutputSet loads the unique lines of an output file. The discovery
+// time that -monitor-timestamps puts in front of each line is dropped so
+// the same value seen at different times compares equal.
+func readOutputSet(path string) (map[string]struct{}, error) {
+	lines, err := readLinesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]struct{}, len(lines))
+	for _, line := range lines {
+		if ts, rest, ok := strings.Cut(line, " "); ok {
+			if _, err := time.Parse(time.RFC3339, ts); err == nil {
+				line = rest
+			}
+		}
+		set[output.Sanitize(line)] = struct{}{}
+	}
+	return set, nil
+}
+
+// setDifference returns the sorted members of a that are not in b.
+func setDifference(a, b map[string]struct{}) []string {
+	diff := []string{}
+	for line := range a {
+		if _, ok := b[line]; !ok {
+			diff = append(diff, line)
+		}
+	}
+	slices.Sort(diff)
+	return diff
+}
@@ -0,0 +1,148 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+	"github.com/TheArqsz/ct-hulhu/internal/output"
+)
+
+// execQueue bounds how many results wait for a slow -exec command before
+// parse workers block on it.
+const execQueue = 256
+
+// execGrace is how long the command gets to finish after its stdin is
+// closed at the end of the run.
+const execGrace = 30 * time.Second
+
+// execHook streams matching results as JSON lines to the stdin of one
+// long-running command. If the command exits early, a warning is logged
+// and later results are counted as dropped instead of sent.
+type execHook struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan []byte
+	written chan struct{}
+	exited  chan struct{}
+	waited  chan struct{}
+	closing atomic.Bool
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+func startExecHook(command string) (*execHook, error) {
+	cmd := shellCommand(command)
+	// The command's own output goes to stderr so it can't mix with
+	// results on stdout.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	h := &execHook{
+		command: command,
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan []byte, execQueue),
+		written: make(chan struct{}),
+		exited:  make(chan struct{}),
+		waited:  make(chan struct{}),
+	}
+	go h.wait()
+	go h.write()
+	return h, nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// wait reaps the command. It closes exited as soon as the command is gone
+// and waited once any warning about it has been logged.
+func (h *execHook) wait() {
+	defer close(h.waited)
+	err := h.cmd.Wait()
+	close(h.exited)
+	if h.closing.Load() {
+		return
+	}
+	if err != nil {
+		log.Warning("-exec command exited early: %v; results are no longer sent to it", err)
+	} else {
+		log.Warning("-exec command exited early; results are no longer sent to it")
+	}
+}
+
+// write feeds queued lines to the command. After a failed write it keeps
+// draining the queue so senders never block on a dead command.
+func (h *execHook) write() {
+	defer close(h.written)
+	failed := false
+	for data := range h.lines {
+		if !failed {
+			if _, err := h.stdin.Write(data); err == nil {
+				h.sent.Add(1)
+				continue
+			}
+			failed = true
+		}
+		h.dropped.Add(1)
+	}
+}
+
+func (h *execHook) send(result *ctlog.CertResult) {
+	if h == nil {
+		return
+	}
+	select {
+	case <-h.exited:
+		h.dropped.Add(1)
+		return
+	default:
+	}
+	data, err := json.Marshal(output.NewJSONResult(result))
+	if err != nil {
+		return
+	}
+	h.lines <- append(data, '\n')
+}
+
+// close ends the stream, gives the command execGrace to finish with what
+// it was sent, and reports the counts.
+func (h *execHook) close() {
+	if h == nil {
+		return
+	}
+	h.closing.Store(true)
+	close(h.lines)
+	<-h.written
+	h.stdin.Close()
+	select {
+	case <-h.exited:
+	case <-time.After(execGrace):
+		log.Warning("-exec command still running %v after the last result, killing it", execGrace)
+		h.cmd.Process.Kill()
+		<-h.exited
+	}
+	<-h.waited
+	if n := h.dropped.Load(); n > 0 {
+		log.Warning("-exec: %d results sent, %d dropped after the command exited", h.sent.Load(), n)
+		return
+	}
+	log.Info("-exec: %d results sent to %s", h.sent.Load(), h.command)
+}
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"cmp"
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// ledger is the on-disk work queue behind -durable-queue. It records which
+// entries of a log have been processed as merged ranges, so a restart
+// fetches exactly the batches that are still missing, whatever order
+// workers finished them in. Anything not in Done is pending; Failed lists
+// the pending ranges that were dropped after retries in the last run.
+type ledger struct {
+	LogURL      string        `json:"log_url"`
+	Done        []ctlog.Range `json:"done"`
+	Failed      []ctlog.Range `json:"failed,omitempty"`
+	LastUpdated time.Time     `json:"last_updated"`
+
+	mu sync.Mutex
+}
+
+// markDone adds an inclusive range to Done, merging it with any ranges it
+// overlaps or touches.
+func (l *ledger) markDone(rg ctlog.Range) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i, _ := slices.BinarySearchFunc(l.Done, rg.Start, func(d ctlog.Range, start int64) int {
+		return cmp.Compare(d.Start, start)
+	})
+	l.Done = slices.Insert(l.Done, i, rg)
+
+	merged := l.Done[:0]
+	for _, d := range l.Done {
+		if n := len(merged); n > 0 && d.Start <= merged[n-1].End+1 {
+			merged[n-1].End = max(merged[n-1].End, d.End)
+			continue
+		}
+		merged = append(merged, d)
+	}
+	l.Done = merged
+}
+
+// pending returns the parts of [start, end) not yet marked done.
+func (l *ledger) pending(start, end int64) []ctlog.Range {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []ctlog.Range
+	pos := start
+	for _, d := range l.Done {
+		if d.End < pos {
+			continue
+		}
+		if d.Start >= end {
+			break
+		}
+		if d.Start > pos {
+			out = append(out, ctlog.Range{Start: pos, End: d.Start - 1})
+		}
+		pos = d.End + 1
+	}
+	if pos < end {
+		out = append(out, ctlog.Range{Start: pos, End: end - 1})
+	}
+	return out
+}
+
+// setFailed records which of the dropped ranges are still not done.
+func (l *ledger) setFailed(dropped []ctlog.Range) {
+	var failed []ctlog.Range
+	for _, rg := range dropped {
+		failed = append(failed, l.pending(rg.Start, rg.End+1)...)
+	}
+	l.mu.Lock()
+	l.Failed = failed
+	l.mu.Unlock()
+}
+
+func rangeSize(ranges []ctlog.Range) int64 {
+	var n int64
+	for _, rg := range ranges {
+		n += rg.End - rg.Start + 1
+	}
+	return n
+}
+
+func (r *Runner) ledgerPath(logURL string) string {
+	return r.statePath(logURL, ".ledger.json")
+}
+
+func (r *Runner) loadLedger(logURL string) *ledger {
+	l := &ledger{LogURL: logURL}
+	data, err := os.ReadFile(r.ledgerPath(logURL))
+	if err != nil {
+		return l
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		log.Warning("durable queue: ignoring unreadable ledger for %s: %v", logURL, err)
+		return &ledger{LogURL: logURL}
+	}
+	return l
+}
+
+// saveLedger writes the ledger through a temporary file so a crash never
+// leaves a truncated ledger behind.
+func (r *Runner) saveLedger(l *ledger) {
+	l.mu.Lock()
+	l.LastUpdated = time.Now()
+	data, err := json.Marshal(l)
+	l.mu.Unlock()
+	if err != nil {
+		log.Debug("failed to marshal ledger: %v", err)
+		return
+	}
+	if err := os.MkdirAll(r.opts.StateDir, 0o700); err != nil {
+		log.Debug("failed to create state directory: %v", err)
+		return
+	}
+	path := r.ledgerPath(l.LogURL)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Debug("failed to write ledger: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Debug("failed to replace ledger: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TheArqsz/ct-hulhu/internal/loglist"
+	"github.com/TheArqsz/ct-hulhu/internal/output"
+)
+
+// logListSnapshot is where -list-logs-diff keeps the list it saw last.
+const logListSnapshot = "log_list.json"
+
+// listLogsDiff compares the current log list with the copy saved by the
+// previous run, prints added, removed and state-changed logs, and saves
+// the current list for next time.
+func (r *Runner) listLogsDiff(ctx context.Context) error {
+	log.Info("fetching CT log list...")
+
+	current, err := r.fetchLogList(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.opts.StateDir, logListSnapshot)
+	previous, err := readLogList(path)
+	switch {
+	case os.IsNotExist(err):
+		log.Info("no saved log list in %s yet, saving the current one to compare against next time", r.opts.StateDir)
+	case err != nil:
+		return fmt.Errorf("reading saved log list: %w", err)
+	default:
+		printLogListChanges(loglist.Diff(previous, current), r.opts.JSON)
+	}
+
+	if err := writeLogList(path, current); err != nil {
+		return fmt.Errorf("saving log list: %w", err)
+	}
+	return nil
+}
+
+func readLogList(path string) (*loglist.LogList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list loglist.LogList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func writeLogList(path string, list *loglist.LogList) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func printLogListChanges(changes []loglist.Change, jsonMode bool) {
+	if jsonMode {
+		for _, c := range changes {
+			c.Operator = output.Sanitize(c.Operator)
+			c.Description = output.Sanitize(c.Description)
+			data, err := json.Marshal(c)
+			if err != nil {
+				log.Debug("json marshal error: %v", err)
+				continue
+			}
+			fmt.Println(string(data))
+		}
+		return
+	}
+
+	if len(changes) == 0 {
+		log.Info("no changes since the saved log list")
+		return
+	}
+	fmt.Printf("%-8s %-22s %-50s %-45s %s\n", "CHANGE", "STATE", "DESCRIPTION", "URL", "OPERATOR")
+	fmt.Println(strings.Repeat("-", 150))
+	for _, c := range changes {
+		state := c.NewState
+		switch c.Kind {
+		case "state":
+			state = c.OldState + " -> " + c.NewState
+		case "removed":
+			state = c.OldState
+		}
+		fmt.Printf("%-8s %-22s %-50s %-45s %s\n",
+			c.Kind,
+			state,
+			truncate(output.Sanitize(c.Description), 48),
+			truncate(c.URL, 43),
+			output.Sanitize(c.Operator),
+		)
+	}
+}
@@ -0,0 +1,47 @@
+package runner
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/output"
+)
+
+const memoryCheckInterval = 2 * time.Second
+
+// watchMemory enforces -max-memory on a best-effort basis. The limit is
+// handed to the Go runtime as a soft memory limit so the GC works harder
+// as it gets close, and the heap is checked periodically: when it is
+// still over the limit, buffered output is flushed and a full collection
+// is forced. The dedup set is never trimmed, so a run that really needs
+// more than the limit keeps growing instead of losing state.
+func (r *Runner) watchMemory(ctx context.Context, writer *output.Writer) {
+	if r.opts.MaxMemory <= 0 {
+		return
+	}
+	limit := uint64(r.opts.MaxMemory) << 20
+	debug.SetMemoryLimit(int64(limit))
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	var ms runtime.MemStats
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runtime.ReadMemStats(&ms)
+		if ms.Alloc <= limit {
+			continue
+		}
+		before := ms.Alloc
+		writer.Flush()
+		debug.FreeOSMemory()
+		runtime.ReadMemStats(&ms)
+		log.Warning("memory: heap at %d MB exceeds -max-memory %d MB, flushed output and forced GC (now %d MB)",
+			before>>20, r.opts.MaxMemory, ms.Alloc>>20)
+	}
+}
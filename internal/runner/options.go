@@ -1,11 +1,20 @@
 package runner
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/TheArqsz/ct-hulhu/internal/certparser"
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+	"github.com/TheArqsz/ct-hulhu/internal/output"
 )
 
 type stringSlice []string
@@ -24,77 +33,235 @@ func (s *stringSlice) Set(val string) error {
 type Options struct {
 	Domain     stringSlice
 	DomainFile string
-
-	LogURL   stringSlice
-	ListLogs bool
-	LogState string
-
-	Workers      int
-	ParseWorkers int
-	BatchSize    int
-	RateLimit    int
-	Timeout      int
-	Retries      int
-	Start        int64
-	Count        int64
-	FromEnd      bool
-
-	Output  string
-	JSON    bool
-	Fields  string
-	Silent  bool
-	Verbose bool
-	NoColor bool
-
-	Monitor      bool
-	PollInterval int
+	StdinMode  string
+
+	LogURL        stringSlice
+	ListLogs      bool
+	ListOperators bool
+	ListLogsDiff  bool
+	GetSTH        bool
+	Probe         bool
+	Diff          string
+	diffNew       string
+	LogState      string
+	AllStates     bool
+	UsableAt      string
+
+	Workers         int
+	GlobalWorkers   int
+	ParseWorkers    int
+	BatchSize       int
+	RateLimit       int
+	TargetRate      int
+	Timeout         int
+	MinTLS          string
+	EntriesStyle    string
+	LinkPages       int
+	NoKeepAlive     bool
+	AuthToken       string
+	Retries         int
+	MaxTotalRetries int64
+	RetryDropped    bool
+	FailFast        bool
+	LogParseErrors  bool
+	MaxMemory       int
+	Start           int64
+	Count           int64
+	FromEnd         bool
+	Recent          time.Duration
+	Reverse         bool
+	Autotune        bool
+	RampStart       int
+	RampInterval    time.Duration
+
+	CAOnly              bool
+	ExactMatch          bool
+	SubdomainsOnly      bool
+	BrandMatch          bool
+	ExcludeExpiredAtLog bool
+	MinNotBefore        string
+	EntryType           string
+	StrictTimestamps    bool
+	EKU                 stringSlice
+	ValidationLevel     stringSlice
+	Serial              stringSlice
+	Issuer              stringSlice
+	IssuerFile          string
+	DenyIssuerFile      string
+
+	Output        string
+	JSON          bool
+	Proto         bool
+	LinkPrecerts  bool
+	IncludeRaw    bool
+	JSONMeta      bool
+	ShowMatch     bool
+	RollupCIDR    string
+	MaxResults    int
+	CountMatches  bool
+	FlushInterval time.Duration
+	OutputBuffer  int
+	ProgressJSON  string
+	Exec          string
+	Ordered       bool
+	OrderWindow   int64
+	ArchiveLeaves string
+	ArchiveMaxMB  int
+	Fields        string
+	Template      string
+	Table         bool
+	GroupBy       string
+	Sep           string
+	CIDedup       bool
+	MaxPerDomain  int
+	ExportSeen    string
+	SeedDedup     string
+	PSLFile       string
+	Silent        bool
+	QuietProgress bool
+	Verbose       bool
+	VerboseHTTP   bool
+	TraceFile     string
+	NoColor       bool
+
+	rollupV4Bits int
+	rollupV6Bits int
+	template     *template.Template
+	usableAt     time.Time
+	minNotBefore time.Time
+	minTLS       uint16
+	entriesStyle *ctlog.EntriesStyle
+
+	Monitor            bool
+	PollInterval       int
+	PollConcurrency    int
+	MonitorIdleTimeout time.Duration
+	MonitorTimestamps  bool
+	PollOnce           bool
+	MaxLogAge          time.Duration
+	AlertOutput        string
+	AlertDomains       stringSlice
 
 	Update             bool
 	DisableUpdateCheck bool
 
-	Resume   bool
-	StateDir string
+	Resume        bool
+	ResumeAuto    time.Duration
+	SinceIndex    string
+	DurableQueue  bool
+	ResumeOverlap int64
+	ResumeDedup   bool
+	StateDir      string
 }
 
 func ParseOptions() *Options {
 	opts := &Options{}
 
-	flag.Var(&opts.Domain, "d", "target domain(s) to filter (comma-separated, can be repeated)")
-	flag.Var(&opts.Domain, "domain", "target domain(s) to filter (comma-separated, can be repeated)")
-	flag.StringVar(&opts.DomainFile, "df", "", "file containing target domains (one per line)")
+	flag.Var(&opts.Domain, "d", "target domain(s), IPs or CIDRs to filter (comma-separated, can be repeated)")
+	flag.Var(&opts.Domain, "domain", "target domain(s), IPs or CIDRs to filter (comma-separated, can be repeated)")
+	flag.StringVar(&opts.DomainFile, "df", "", "file containing target domains (one per line, supports globs and @include)")
+	flag.StringVar(&opts.StdinMode, "stdin", "domains", "what lines piped on stdin are (domains/logs)")
 
 	flag.Var(&opts.LogURL, "lu", "CT log URL(s) to scrape (comma-separated, can be repeated)")
 	flag.Var(&opts.LogURL, "log-url", "CT log URL(s) to scrape (comma-separated, can be repeated)")
 	flag.BoolVar(&opts.ListLogs, "ls", false, "list available CT logs and exit")
 	flag.BoolVar(&opts.ListLogs, "list-logs", false, "list available CT logs and exit")
-	flag.StringVar(&opts.LogState, "log-state", "usable", "filter logs by state (usable/readonly/retired/qualified/all)")
+	flag.BoolVar(&opts.ListLogsDiff, "list-logs-diff", false, "show logs added, removed or changed state since the log list saved by the last run, save the current one and exit")
+	flag.BoolVar(&opts.ListOperators, "list-operators", false, "list CT log operators with their log counts by state and exit (add -get-sth for entry totals)")
+	flag.StringVar(&opts.Diff, "diff", "", "compare two output files and print added/removed lines: -diff old.txt new.txt")
+	flag.BoolVar(&opts.GetSTH, "get-sth", false, "print the signed tree head of each selected log and exit")
+	flag.BoolVar(&opts.Probe, "probe", false, "check each selected log with one quick get-sth, report reachability and latency, and exit")
+	flag.StringVar(&opts.LogState, "log-state", "usable", "filter logs by state, comma-separated for several (usable/readonly/retired/qualified/all)")
+	flag.BoolVar(&opts.AllStates, "all-states", false, "select logs in every state, same as -log-state all")
+	flag.StringVar(&opts.UsableAt, "usable-at", "", "select logs that were scrapeable at this date (YYYY-MM-DD or RFC3339), overrides -log-state")
 
 	flag.IntVar(&opts.Workers, "w", 4, "number of concurrent fetch workers")
 	flag.IntVar(&opts.Workers, "workers", 4, "number of concurrent fetch workers")
+	flag.IntVar(&opts.GlobalWorkers, "global-workers", 0, "max requests in flight across all logs, get-sth included (0 = no shared limit)")
 	flag.IntVar(&opts.ParseWorkers, "pw", 0, "number of concurrent parse workers (0 = auto)")
 	flag.IntVar(&opts.ParseWorkers, "parse-workers", 0, "number of concurrent parse workers (0 = auto)")
 	flag.IntVar(&opts.BatchSize, "bs", 256, "entries per batch request")
 	flag.IntVar(&opts.BatchSize, "batch-size", 256, "entries per batch request")
 	flag.IntVar(&opts.RateLimit, "rl", 0, "max requests per second (0 = unlimited)")
 	flag.IntVar(&opts.RateLimit, "rate-limit", 0, "max requests per second (0 = unlimited)")
+	flag.IntVar(&opts.TargetRate, "target-rate", 0, "adjust workers (up to -w) to hold this many entries/sec (0 = ramp on error rate)")
 	flag.IntVar(&opts.Timeout, "to", 30, "HTTP request timeout in seconds")
 	flag.IntVar(&opts.Timeout, "timeout", 30, "HTTP request timeout in seconds")
+	flag.StringVar(&opts.MinTLS, "min-tls", "1.2", "minimum TLS version for all connections (1.2/1.3)")
+	flag.StringVar(&opts.AuthToken, "auth-token", "", "send 'Authorization: Bearer <token>' to CT logs (or set CT_HULHU_AUTH_TOKEN)")
+	flag.BoolVar(&opts.NoKeepAlive, "disable-keepalive", false, "open a new connection for every request, for proxies that stall on keep-alive connections")
+	flag.StringVar(&opts.EntriesStyle, "entries-style", "auto", "get-entries request style (auto/get/post-form/post-json)")
+	flag.IntVar(&opts.LinkPages, "link-pages", 0, "follow up to N Link rel=next pages per get-entries request, for paginated logs (0 = off)")
 	flag.IntVar(&opts.Retries, "retries", 3, "number of retries per failed request")
+	flag.Int64Var(&opts.MaxTotalRetries, "max-total-retries", 0, "retry budget for the whole run (0 = unlimited)")
+	flag.BoolVar(&opts.RetryDropped, "retry-dropped", false, "after the main pass, fetch dropped batches once more with half the workers")
+	flag.BoolVar(&opts.FailFast, "fail-fast", false, "stop the run with an error as soon as one log fails, instead of moving on")
+	flag.BoolVar(&opts.LogParseErrors, "log-parse-errors", false, "log the index and error of each certificate that fails to parse instead of skipping it silently")
+	flag.IntVar(&opts.MaxMemory, "max-memory", 0, "soft heap limit in MB: flush output and force GC when exceeded (0 = off, best-effort)")
 	flag.Int64Var(&opts.Start, "start", -1, "start entry index (-1 = auto)")
 	flag.Int64Var(&opts.Count, "n", 0, "number of entries to fetch (0 = all)")
 	flag.Int64Var(&opts.Count, "count", 0, "number of entries to fetch (0 = all)")
 	flag.BoolVar(&opts.FromEnd, "from-end", false, "start from newest entries")
-
-	flag.StringVar(&opts.Output, "o", "", "output file path")
-	flag.StringVar(&opts.Output, "output", "", "output file path")
+	flag.DurationVar(&opts.Recent, "recent", 0, "scrape entries logged within this duration, e.g. 6h, found by binary search on leaf timestamps")
+	flag.BoolVar(&opts.Reverse, "reverse", false, "fetch batches newest-first within the selected range")
+	flag.BoolVar(&opts.Autotune, "autotune", false, "calibrate workers and batch size against the log before scraping")
+	flag.IntVar(&opts.RampStart, "ramp-start", 1, "number of fetch workers to start with before ramping up")
+	flag.DurationVar(&opts.RampInterval, "ramp-interval", 500*time.Millisecond, "interval between adding fetch workers")
+
+	flag.BoolVar(&opts.ExactMatch, "exact-match", false, "match domain filters exactly, without subdomains")
+	flag.BoolVar(&opts.SubdomainsOnly, "subdomains-only", false, "match only subdomains of domain filters, not the domain itself")
+	flag.BoolVar(&opts.BrandMatch, "brand-match", false, "also match names that embed a domain filter's first label anywhere, e.g. example-com-login.evil.net (brand_embedded in JSON)")
+	flag.BoolVar(&opts.ExcludeExpiredAtLog, "exclude-expired-at-log", false, "drop certificates that had already expired when they were logged")
+	flag.StringVar(&opts.MinNotBefore, "min-not-before", "", "drop certificates issued before this date (YYYY-MM-DD or RFC3339)")
+	flag.StringVar(&opts.EntryType, "entry-type", "both", "only parse log entries of this type (x509/precert/both), skipping the rest before parsing")
+	flag.BoolVar(&opts.StrictTimestamps, "strict-timestamps", false, "drop entries whose leaf timestamp is before 2013 or more than a day in the future")
+	flag.BoolVar(&opts.CAOnly, "ca-only", false, "only output CA certificates (basic constraints CA=true)")
+	flag.Var(&opts.EKU, "eku", "only output certificates with extended key usage (e.g. serverAuth, clientAuth, codeSigning)")
+	flag.Var(&opts.ValidationLevel, "validation-level", "only output certificates asserting a CA/B Forum validation level (dv/ov/iv/ev, comma-separated)")
+	flag.Var(&opts.Serial, "serial", "only output certificates with this hex serial number, colons and case ignored (comma-separated, can be repeated)")
+	flag.Var(&opts.Issuer, "issuer", "only output certificates whose issuer contains this substring (can be repeated)")
+	flag.StringVar(&opts.IssuerFile, "issuer-file", "", "file of allowed issuer substrings (one per line)")
+	flag.StringVar(&opts.DenyIssuerFile, "deny-issuer-file", "", "file of issuer substrings to exclude (one per line)")
+
+	flag.StringVar(&opts.Output, "o", "", "output file path (.json/.jsonl/.ndjson or .tsv picks the format unless one is set)")
+	flag.StringVar(&opts.Output, "output", "", "output file path (.json/.jsonl/.ndjson or .tsv picks the format unless one is set)")
 	flag.BoolVar(&opts.JSON, "json", false, "JSON line output")
 	flag.BoolVar(&opts.JSON, "j", false, "JSON line output")
-	flag.StringVar(&opts.Fields, "f", "domains", "output fields (domains/ips/emails/certs/all)")
-	flag.StringVar(&opts.Fields, "fields", "domains", "output fields (domains/ips/emails/certs/all)")
+	flag.BoolVar(&opts.Proto, "proto", false, "binary output: varint length-prefixed protobuf CertResult messages (proto/cert_result.proto)")
+	flag.BoolVar(&opts.LinkPrecerts, "link-precerts", false, "pair precerts with final certificates seen in the same run (adds final_for/precert_of to JSON)")
+	flag.BoolVar(&opts.IncludeRaw, "include-raw", false, "add the base64 certificate DER to JSON output (der_b64, or precert_tbs_b64 for precerts)")
+	flag.BoolVar(&opts.JSONMeta, "json-meta", false, "write a {\"_meta\": ...} line with the log URL, fetched index range and tool version after each log's results")
+	flag.BoolVar(&opts.ShowMatch, "show-match", false, "add the matching -d filter and certificate name to JSON output (matched_filter, matched_domain)")
+	flag.BoolVar(&opts.CountMatches, "count-matches", false, "print the number of matching entries instead of the results")
+	flag.IntVar(&opts.MaxResults, "max-results", 0, "stop scraping after this many unique results (0 = unlimited)")
+	flag.DurationVar(&opts.FlushInterval, "flush-interval", 0, "flush output on this interval instead of after every batch (0 = every batch, ignored in monitor mode)")
+	flag.IntVar(&opts.OutputBuffer, "output-buffer-size", 0, "output write buffer in bytes, larger means fewer writes on big scrapes (0 = 4096)")
+	flag.StringVar(&opts.ArchiveLeaves, "archive-leaves", "", "also save the raw leaf_input and extra_data of every matching entry to this directory, one JSON file per entry")
+	flag.IntVar(&opts.ArchiveMaxMB, "archive-max-mb", 1024, "stop archiving leaves after this many MB (0 = unlimited)")
+	flag.BoolVar(&opts.Ordered, "ordered", false, "write results in log index order, holding back batches that arrive early")
+	flag.Int64Var(&opts.OrderWindow, "order-window", 100000, "with -ordered, max entries held back before writing out of order")
+	flag.StringVar(&opts.Exec, "exec", "", "start this shell command once and stream every matching result to its stdin as JSON lines")
+	flag.StringVar(&opts.ProgressJSON, "progress-json", "", "write progress events as JSON lines to this file or file descriptor number")
+	flag.StringVar(&opts.RollupCIDR, "rollup-cidr", "", "collapse IP output into CIDR blocks, e.g. /24 or /24,/64 (IPv4[,IPv6])")
+	flag.BoolVar(&opts.Table, "table", false, "render results as an aligned table (CN, issuer, not-after, #domains) when stdout is a terminal")
+	flag.StringVar(&opts.GroupBy, "group-by", "", "group line output: 'cert' writes one tab-separated line per certificate (overrides -f)")
+	flag.StringVar(&opts.Sep, "sep", ",", "separator between list values in -f certs, -f usage and -group-by output")
+	flag.BoolVar(&opts.CIDedup, "ci-dedup", false, "deduplicate line output case-insensitively, keeping the first spelling seen")
+	flag.StringVar(&opts.ExportSeen, "export-seen", "", "write the dedup set to this file at the end of the run, one value per line")
+	flag.StringVar(&opts.PSLFile, "psl-file", "", "public suffix list for registered_domain, e.g. a fresh public_suffix_list.dat (default: embedded copy)")
+	flag.StringVar(&opts.SeedDedup, "seed-dedup", "", "skip values already in this earlier output file, e.g. a scrape's output when starting a monitor")
+	flag.IntVar(&opts.MaxPerDomain, "max-per-domain", 0, "stop writing results for a matched domain after this many lines (0 = no cap)")
+	flag.StringVar(&opts.Template, "template", "", "Go text/template rendering one line per certificate, e.g. '{{.CommonName}} {{.NotAfter}}' (overrides -f)")
+	flag.StringVar(&opts.Fields, "f", "domains", "output fields (domains/ips/emails/certs/usage/policy/revocation/serials/all)")
+	flag.StringVar(&opts.Fields, "fields", "domains", "output fields (domains/ips/emails/certs/usage/policy/revocation/serials/all)")
 	flag.BoolVar(&opts.Silent, "s", false, "silent mode - only output results")
 	flag.BoolVar(&opts.Silent, "silent", false, "silent mode - only output results")
+	flag.BoolVar(&opts.QuietProgress, "quiet-progress", false, "drop the periodic progress lines but keep other logging")
 	flag.BoolVar(&opts.Verbose, "v", false, "verbose output")
 	flag.BoolVar(&opts.Verbose, "verbose", false, "verbose output")
+	flag.BoolVar(&opts.VerboseHTTP, "vv", false, "verbose output plus every HTTP request with status, duration and size")
+	flag.BoolVar(&opts.VerboseHTTP, "verbose-http", false, "verbose output plus every HTTP request with status, duration and size")
+	flag.StringVar(&opts.TraceFile, "trace-file", "", "record every fetched and dropped index range to this file")
 	flag.BoolVar(&opts.NoColor, "nc", false, "disable color output")
 	flag.BoolVar(&opts.NoColor, "no-color", false, "disable color output")
 
@@ -102,6 +269,13 @@ func ParseOptions() *Options {
 	flag.BoolVar(&opts.Monitor, "m", false, "continuous monitoring mode - watch for new entries")
 	flag.IntVar(&opts.PollInterval, "poll-interval", 10, "seconds between STH polls in monitor mode")
 	flag.IntVar(&opts.PollInterval, "pi", 10, "seconds between STH polls in monitor mode")
+	flag.IntVar(&opts.PollConcurrency, "poll-concurrency", 0, "logs polled at once in monitor mode (0 = same as -w)")
+	flag.BoolVar(&opts.MonitorTimestamps, "monitor-timestamps", false, "prefix monitor output with discovery time (adds discovered_at in JSON)")
+	flag.DurationVar(&opts.MonitorIdleTimeout, "monitor-idle-timeout", 0, "stop monitor after no new entries for this duration (0 = never)")
+	flag.BoolVar(&opts.PollOnce, "poll-once", false, "run a single monitor poll, save each log's tree size and exit (implies -m, use with -resume)")
+	flag.StringVar(&opts.AlertOutput, "alert-output", "", "in monitor mode, also write results matching -alert-domains to this file")
+	flag.Var(&opts.AlertDomains, "alert-domains", "critical subset of domains whose matches also go to -alert-output (comma-separated, can be repeated)")
+	flag.DurationVar(&opts.MaxLogAge, "max-log-age", 0, "in monitor mode, skip logs whose latest tree head is older than this, e.g. 24h (0 = keep all)")
 
 	flag.BoolVar(&opts.Update, "up", false, "update ct-hulhu to latest version")
 	flag.BoolVar(&opts.Update, "update", false, "update ct-hulhu to latest version")
@@ -109,6 +283,11 @@ func ParseOptions() *Options {
 	flag.BoolVar(&opts.DisableUpdateCheck, "disable-update-check", false, "disable automatic update check")
 
 	flag.BoolVar(&opts.Resume, "resume", false, "resume from last saved position")
+	flag.BoolVar(&opts.DurableQueue, "durable-queue", false, "track finished batches on disk and resume exactly the unfinished ones")
+	flag.DurationVar(&opts.ResumeAuto, "resume-auto", 0, "resume only from saved state newer than this age, start fresh otherwise (implies -resume)")
+	flag.Int64Var(&opts.ResumeOverlap, "resume-overlap", 0, "re-fetch this many entries before the saved position when resuming")
+	flag.BoolVar(&opts.ResumeDedup, "resume-dedup", false, "append to the -o file and skip results it already holds instead of overwriting it")
+	flag.StringVar(&opts.SinceIndex, "since-index-file", "", "JSON file of the highest index scraped per log; each run continues after it and updates it on completion")
 	flag.StringVar(&opts.StateDir, "state-dir", defaultStateDir(), "directory for state files")
 
 	flag.Usage = func() {
@@ -130,6 +309,13 @@ func ParseOptions() *Options {
 
 	flag.Parse()
 
+	// -diff takes two paths and the flag package hands over only the first;
+	// the second is a positional argument, possibly followed by more flags.
+	if opts.Diff != "" && flag.NArg() > 0 {
+		opts.diffNew = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
 	if flag.NArg() > 0 {
 		fmt.Fprintf(os.Stderr, "error: unrecognized arguments: %s\n", strings.Join(flag.Args(), " "))
 		fmt.Fprintf(os.Stderr, "hint: all flags must appear before any positional arguments\n")
@@ -137,18 +323,52 @@ func ParseOptions() *Options {
 		os.Exit(1)
 	}
 
+	if opts.VerboseHTTP {
+		opts.Verbose = true
+	}
+	if opts.PollOnce {
+		opts.Monitor = true
+	}
+	if opts.AllStates {
+		opts.LogState = "all"
+	}
+	if opts.ResumeAuto > 0 {
+		opts.Resume = true
+	}
+	if opts.AuthToken == "" {
+		opts.AuthToken = os.Getenv("CT_HULHU_AUTH_TOKEN")
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
 	configureLogger(opts.Silent, opts.Verbose, opts.NoColor)
+	opts.inferFormat(set)
 	opts.validate()
 
 	return opts
 }
 
+// Bounds for -output-buffer-size: below a page the buffer saves nothing,
+// and above this it only delays output.
+const (
+	minOutputBuffer = 4 << 10
+	maxOutputBuffer = 64 << 20
+)
+
 func (o *Options) validate() {
 	var errors []string
 
 	if o.Workers < 1 || o.Workers > 128 {
 		errors = append(errors, "-w/--workers must be between 1 and 128")
 	}
+	if o.GlobalWorkers < 0 {
+		errors = append(errors, "--global-workers must be >= 0")
+	}
+	if o.RampStart < 1 || o.RampStart > 128 {
+		errors = append(errors, "--ramp-start must be between 1 and 128")
+	}
+	if o.RampInterval <= 0 {
+		errors = append(errors, "--ramp-interval must be > 0")
+	}
 	if o.ParseWorkers < 0 || o.ParseWorkers > 128 {
 		errors = append(errors, "-pw/--parse-workers must be between 0 and 128")
 	}
@@ -158,28 +378,252 @@ func (o *Options) validate() {
 	if o.RateLimit < 0 {
 		errors = append(errors, "-rl/--rate-limit must be >= 0")
 	}
+	if o.TargetRate < 0 {
+		errors = append(errors, "--target-rate must be >= 0")
+	}
 	if o.Timeout < 1 {
 		errors = append(errors, "-to/--timeout must be >= 1")
 	}
+	switch o.MinTLS {
+	case "1.2":
+		o.minTLS = tls.VersionTLS12
+	case "1.3":
+		o.minTLS = tls.VersionTLS13
+	default:
+		errors = append(errors, fmt.Sprintf("--min-tls must be one of: 1.2, 1.3 (got %q)", o.MinTLS))
+	}
+	if o.EntriesStyle != "auto" {
+		if style, ok := ctlog.LookupEntriesStyle(o.EntriesStyle); ok {
+			o.entriesStyle = &style
+		} else {
+			errors = append(errors, fmt.Sprintf("--entries-style must be one of: auto, get, post-form, post-json (got %q)", o.EntriesStyle))
+		}
+	}
+	if o.LinkPages < 0 || o.LinkPages > 1000 {
+		errors = append(errors, "--link-pages must be between 0 and 1000")
+	}
 	if o.Retries < 0 || o.Retries > 10 {
 		errors = append(errors, "--retries must be between 0 and 10")
 	}
+	if o.MaxResults < 0 {
+		errors = append(errors, "--max-results must be >= 0")
+	}
+	if o.CountMatches && (o.Monitor || o.Output != "" || o.MaxResults > 0) {
+		errors = append(errors, "--count-matches cannot be combined with -m, -o or --max-results")
+	}
+	if o.Table && (o.JSON || o.Template != "" || o.Output != "" || o.CountMatches) {
+		errors = append(errors, "--table cannot be combined with -json, --template, -o or --count-matches")
+	}
+	if o.GroupBy != "" {
+		switch {
+		case o.GroupBy != "cert":
+			errors = append(errors, fmt.Sprintf("--group-by must be 'cert' (got %q)", o.GroupBy))
+		case o.JSON || o.Template != "" || o.Table:
+			errors = append(errors, "--group-by cannot be combined with -json, --template or --table")
+		}
+	}
+	switch {
+	case o.Sep == "":
+		errors = append(errors, "--sep must not be empty")
+	case strings.ContainsAny(o.Sep, "\r\n"):
+		errors = append(errors, "--sep must not contain a newline, output is one record per line")
+	case o.GroupBy != "" && strings.Contains(o.Sep, "\t"):
+		errors = append(errors, "--sep must not contain a tab with --group-by, which separates columns with tabs")
+	}
+	if o.LinkPrecerts && !o.JSON {
+		errors = append(errors, "--link-precerts requires -json")
+	}
+	if o.IncludeRaw && !o.JSON && !o.Proto {
+		errors = append(errors, "--include-raw requires -json or --proto")
+	}
+	if o.JSONMeta && !o.JSON {
+		errors = append(errors, "--json-meta requires -json")
+	}
+	if o.JSONMeta && o.Monitor {
+		errors = append(errors, "--json-meta records a scrape's index range and doesn't apply to -monitor")
+	}
+	if o.ShowMatch && !o.JSON && !o.Proto {
+		errors = append(errors, "--show-match requires -json or --proto")
+	}
+	if o.Proto && (o.JSON || o.Template != "" || o.Table || o.GroupBy != "" || o.CountMatches || o.ResumeDedup) {
+		errors = append(errors, "--proto cannot be combined with -json, --template, --table, --group-by, --count-matches or --resume-dedup")
+	}
+	if o.Recent < 0 {
+		errors = append(errors, "--recent must be >= 0")
+	}
+	if o.Recent > 0 && (o.Start >= 0 || o.Count > 0 || o.FromEnd || o.Monitor) {
+		errors = append(errors, "--recent picks its own range and cannot be combined with -start, -n, -from-end or -m")
+	}
+	if o.ExactMatch && o.SubdomainsOnly {
+		errors = append(errors, "--exact-match and --subdomains-only cannot be combined")
+	}
+	if o.MaxPerDomain < 0 {
+		errors = append(errors, "--max-per-domain must be >= 0")
+	}
+	if o.FlushInterval < 0 {
+		errors = append(errors, "--flush-interval must be >= 0")
+	}
+	if o.MaxTotalRetries < 0 {
+		errors = append(errors, "--max-total-retries must be >= 0")
+	}
 	if o.PollInterval < 1 {
 		errors = append(errors, "-pi/--poll-interval must be >= 1")
 	}
+	if o.OutputBuffer != 0 && (o.OutputBuffer < minOutputBuffer || o.OutputBuffer > maxOutputBuffer) {
+		errors = append(errors, fmt.Sprintf("--output-buffer-size must be 0 or between %d and %d bytes", minOutputBuffer, maxOutputBuffer))
+	}
+	if o.ArchiveMaxMB < 0 {
+		errors = append(errors, "--archive-max-mb must be >= 0")
+	}
+	if o.Ordered {
+		switch {
+		case o.OrderWindow < 1:
+			errors = append(errors, "--order-window must be >= 1")
+		case o.Monitor || o.Reverse || o.DurableQueue:
+			errors = append(errors, "--ordered cannot be combined with -m, -reverse or --durable-queue")
+		}
+	}
+	if o.ProgressJSON != "" {
+		if o.Monitor {
+			errors = append(errors, "--progress-json only applies to scraping, not -monitor")
+		}
+		if fd, err := strconv.Atoi(o.ProgressJSON); err == nil && fd < 3 {
+			errors = append(errors, "--progress-json needs a file path or a descriptor of 3 or more; 0-2 are stdin, results on stdout and logs on stderr")
+		}
+	}
+	if o.MaxMemory < 0 {
+		errors = append(errors, "--max-memory must be >= 0")
+	}
+	if o.PollConcurrency < 0 {
+		errors = append(errors, "--poll-concurrency must be >= 0")
+	}
+	if o.DurableQueue && (o.Resume || o.Monitor) {
+		errors = append(errors, "--durable-queue cannot be combined with -resume or -m")
+	}
+	if o.Diff != "" && o.diffNew == "" {
+		errors = append(errors, "--diff needs two files: -diff old.txt new.txt")
+	}
+	if o.ResumeAuto < 0 {
+		errors = append(errors, "--resume-auto must be >= 0")
+	}
+	if o.SinceIndex != "" && (o.Resume || o.DurableQueue || o.Monitor || o.Reverse || o.Start >= 0) {
+		errors = append(errors, "--since-index-file picks its own start and cannot be combined with -resume, --durable-queue, -m, -reverse or -start")
+	}
+	if o.ResumeOverlap < 0 {
+		errors = append(errors, "--resume-overlap must be >= 0")
+	}
+	if o.ResumeDedup {
+		switch {
+		case !o.Resume && !o.DurableQueue:
+			errors = append(errors, "--resume-dedup requires -resume or --durable-queue")
+		case o.Output == "":
+			errors = append(errors, "--resume-dedup requires -o")
+		case !o.JSON && o.Template == "" && (o.GroupBy != "" || o.Fields == "certs" || o.Fields == "usage" || o.Fields == "policy"):
+			errors = append(errors, "--resume-dedup cannot rebuild dedup state for -f certs, -f usage, -f policy or --group-by")
+		}
+	}
+	if o.SeedDedup != "" {
+		switch {
+		case o.Proto:
+			errors = append(errors, "--seed-dedup cannot read --proto output")
+		case !o.JSON && o.Template == "" && (o.Table || o.GroupBy != "" || o.Fields == "certs" || o.Fields == "usage" || o.Fields == "policy"):
+			errors = append(errors, "--seed-dedup cannot rebuild dedup state for -f certs, -f usage, -f policy, --table or --group-by")
+		}
+	}
+	if o.MonitorIdleTimeout < 0 {
+		errors = append(errors, "--monitor-idle-timeout must be >= 0")
+	}
+	if o.MaxLogAge < 0 {
+		errors = append(errors, "--max-log-age must be >= 0")
+	}
+	switch {
+	case (o.AlertOutput != "") != (len(o.AlertDomains) > 0):
+		errors = append(errors, "--alert-output and --alert-domains must be used together")
+	case o.AlertOutput != "" && !o.Monitor:
+		errors = append(errors, "--alert-output requires -m or --poll-once")
+	case o.AlertOutput != "" && o.Output != "" && samePath(o.AlertOutput, o.Output):
+		errors = append(errors, "--alert-output must be a different file from -o")
+	}
+	if o.MaxLogAge > 0 && !o.Monitor {
+		errors = append(errors, "--max-log-age requires -m or --poll-once")
+	}
 
 	validFields := map[string]bool{
-		"domains": true, "ips": true, "emails": true, "certs": true, "all": true,
+		"domains": true, "ips": true, "emails": true, "certs": true, "usage": true, "policy": true, "revocation": true, "serials": true, "all": true,
 	}
 	if !validFields[o.Fields] {
-		errors = append(errors, fmt.Sprintf("-f/--fields must be one of: domains, ips, emails, certs, all (got %q)", o.Fields))
+		errors = append(errors, fmt.Sprintf("-f/--fields must be one of: domains, ips, emails, certs, usage, policy, revocation, serials, all (got %q)", o.Fields))
 	}
 
+	if o.Template != "" {
+		tmpl, err := output.ParseTemplate(o.Template)
+		switch {
+		case err != nil:
+			errors = append(errors, fmt.Sprintf("--template: %v", err))
+		case o.JSON:
+			errors = append(errors, "--template cannot be combined with -json")
+		}
+		o.template = tmpl
+	}
+
+	for _, eku := range o.EKU {
+		if !certparser.IsKnownExtKeyUsage(eku) {
+			errors = append(errors, fmt.Sprintf("--eku: unknown extended key usage %q", eku))
+		}
+	}
+	for _, level := range o.ValidationLevel {
+		if !certparser.IsKnownValidationLevel(level) {
+			errors = append(errors, fmt.Sprintf("--validation-level must be dv, ov, iv or ev (got %q)", level))
+		}
+	}
+	for _, serial := range o.Serial {
+		if _, ok := new(big.Int).SetString(certparser.NormalizeSerial(serial), 16); !ok {
+			errors = append(errors, fmt.Sprintf("--serial: %q is not a hex serial number", serial))
+		}
+	}
+
+	if o.RollupCIDR != "" {
+		v4, v6, err := parseRollupCIDR(o.RollupCIDR)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("--rollup-cidr: %v", err))
+		}
+		o.rollupV4Bits, o.rollupV6Bits = v4, v6
+	}
+
+	if o.StdinMode != "domains" && o.StdinMode != "logs" {
+		errors = append(errors, fmt.Sprintf("--stdin must be one of: domains, logs (got %q)", o.StdinMode))
+	}
+
+	switch o.EntryType {
+	case "x509", "precert", "both":
+	default:
+		errors = append(errors, fmt.Sprintf("--entry-type must be one of: x509, precert, both (got %q)", o.EntryType))
+	}
+	if o.EntryType != "both" && o.LinkPrecerts {
+		errors = append(errors, "--link-precerts needs both entry types, drop --entry-type")
+	}
 	validStates := map[string]bool{
 		"usable": true, "readonly": true, "qualified": true, "retired": true, "all": true,
 	}
-	if !validStates[o.LogState] {
-		errors = append(errors, fmt.Sprintf("--log-state must be one of: usable, readonly, qualified, retired, all (got %q)", o.LogState))
+	for _, state := range strings.Split(o.LogState, ",") {
+		if !validStates[strings.TrimSpace(state)] {
+			errors = append(errors, fmt.Sprintf("--log-state takes one or more of: usable, readonly, qualified, retired, all (got %q)", state))
+		}
+	}
+
+	if o.UsableAt != "" {
+		t, err := parseDate(o.UsableAt)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("--usable-at: %v", err))
+		}
+		o.usableAt = t
+	}
+	if o.MinNotBefore != "" {
+		t, err := parseDate(o.MinNotBefore)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("--min-not-before: %v", err))
+		}
+		o.minNotBefore = t
 	}
 
 	if len(errors) > 0 {
@@ -190,6 +634,88 @@ func (o *Options) validate() {
 	}
 }
 
+// samePath reports whether a and b name the same file, however they are
+// spelled.
+func samePath(a, b string) bool {
+	if fa, err := os.Stat(a); err == nil {
+		if fb, err := os.Stat(b); err == nil {
+			return os.SameFile(fa, fb)
+		}
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// parseDate accepts a plain date (UTC midnight) or an RFC3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC3339 (got %q)", s)
+	}
+	return t, nil
+}
+
+// parseRollupCIDR parses "/24" or "/24,/64" into IPv4 and IPv6 prefix
+// lengths. IPv6 defaults to /64 when omitted.
+func parseRollupCIDR(s string) (v4, v6 int, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("expected /N or /N,/M (got %q)", s)
+	}
+	v6 = 64
+	for i, part := range parts {
+		bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(part), "/"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid prefix length %q", part)
+		}
+		limit := 32
+		if i == 1 {
+			limit = 128
+		}
+		if bits < 1 || bits > limit {
+			return 0, 0, fmt.Errorf("prefix length %d out of range 1-%d", bits, limit)
+		}
+		if i == 0 {
+			v4 = bits
+		} else {
+			v6 = bits
+		}
+	}
+	return v4, v6, nil
+}
+
+// formatFlags are the flags that choose an output format; setting any of
+// them turns off inference from the -o extension.
+var formatFlags = []string{"json", "proto", "template", "table", "group-by", "f", "fields", "count-matches"}
+
+// inferFormat picks the output format from the -o extension when no
+// format flag was given: .json, .jsonl and .ndjson write JSON lines, .tsv
+// one tab-separated row per certificate. Anything else, .txt included,
+// keeps the line output; .csv does too, with a warning, since there is no
+// CSV format for it to mean.
+func (o *Options) inferFormat(set map[string]bool) {
+	if o.Output == "" {
+		return
+	}
+	for _, name := range formatFlags {
+		if set[name] {
+			return
+		}
+	}
+	switch strings.ToLower(filepath.Ext(o.Output)) {
+	case ".json", ".jsonl", ".ndjson":
+		o.JSON = true
+	case ".tsv":
+		o.GroupBy = "cert"
+	case ".csv":
+		log.Warning("-o %s: there is no CSV output, writing plain lines (use .tsv or -group-by cert for one row per certificate)", o.Output)
+	}
+}
+
 func defaultStateDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -201,35 +727,111 @@ func defaultStateDir() string {
 func printFlags() {
 	w := os.Stderr
 	fmt.Fprintf(w, "\nTARGET:\n")
-	fmt.Fprintf(w, "  -d, -domain string[]        target domain(s) to filter (comma-separated)\n")
-	fmt.Fprintf(w, "  -df string                  file containing target domains (one per line)\n")
+	fmt.Fprintf(w, "  -d, -domain string[]        target domain(s), IPs or CIDRs to filter (comma-separated)\n")
+	fmt.Fprintf(w, "  -df string                  file containing target domains (one per line, supports globs and @include)\n")
+	fmt.Fprintf(w, "  -stdin string               treat stdin lines as domains or logs (default: domains)\n")
 
 	fmt.Fprintf(w, "\nLOG SELECTION:\n")
 	fmt.Fprintf(w, "  -lu, -log-url string[]      CT log URL(s) to scrape\n")
 	fmt.Fprintf(w, "  -ls, -list-logs             list available CT logs and exit\n")
-	fmt.Fprintf(w, "  -log-state string           filter logs by state: usable/readonly/retired/qualified/all (default: usable)\n")
+	fmt.Fprintf(w, "  -list-logs-diff             show logs added, removed or changed state since the last run and exit\n")
+	fmt.Fprintf(w, "  -list-operators             list operators with log counts by state and exit (-get-sth adds entry totals)\n")
+	fmt.Fprintf(w, "  -diff string string         print lines added/removed between two output files and exit\n")
+	fmt.Fprintf(w, "  -get-sth                    print the signed tree head of each selected log and exit\n")
+	fmt.Fprintf(w, "  -probe                      report reachability, latency and tree size of each selected log and exit\n")
+	fmt.Fprintf(w, "  -log-state string           filter logs by state, comma-separated: usable/readonly/retired/qualified/all (default: usable)\n")
+	fmt.Fprintf(w, "  -all-states                 select logs in every state, same as -log-state all\n")
+	fmt.Fprintf(w, "  -usable-at string           logs scrapeable at a date (YYYY-MM-DD or RFC3339), overrides -log-state\n")
 
 	fmt.Fprintf(w, "\nSCRAPING:\n")
 	fmt.Fprintf(w, "  -w, -workers int            concurrent fetch workers (default: 4)\n")
+	fmt.Fprintf(w, "  -global-workers int         max requests in flight across all logs, 0 = no shared limit (default: 0)\n")
 	fmt.Fprintf(w, "  -pw, -parse-workers int     concurrent parse workers, 0 = auto (default: 0)\n")
 	fmt.Fprintf(w, "  -bs, -batch-size int        entries per request (default: 256)\n")
 	fmt.Fprintf(w, "  -rl, -rate-limit int        max requests/sec, 0 = unlimited (default: 0)\n")
+	fmt.Fprintf(w, "  -target-rate int            adjust workers (up to -w) to hold N entries/sec, 0 = off (default: 0)\n")
 	fmt.Fprintf(w, "  -to, -timeout int           HTTP timeout in seconds (default: 30)\n")
+	fmt.Fprintf(w, "  -min-tls string             minimum TLS version for all connections: 1.2/1.3 (default: 1.2)\n")
+	fmt.Fprintf(w, "  -auth-token string          bearer token sent to CT logs, or set CT_HULHU_AUTH_TOKEN\n")
+	fmt.Fprintf(w, "  -disable-keepalive          new connection for every request, for proxies that stall on reused ones\n")
+	fmt.Fprintf(w, "  -entries-style string       get-entries request style: auto/get/post-form/post-json (default: auto)\n")
+	fmt.Fprintf(w, "  -link-pages int             follow up to N Link rel=next pages per request, 0 = off (default: 0)\n")
 	fmt.Fprintf(w, "  -retries int                retries per failed request (default: 3)\n")
+	fmt.Fprintf(w, "  -max-total-retries int      retry budget for the whole run, 0 = unlimited (default: 0)\n")
+	fmt.Fprintf(w, "  -retry-dropped              retry dropped batches in a second pass with half the workers\n")
+	fmt.Fprintf(w, "  -fail-fast                  abort with a non-zero exit when any log fails (default: skip it)\n")
+	fmt.Fprintf(w, "  -log-parse-errors           log index and error of each certificate that fails to parse\n")
+	fmt.Fprintf(w, "  -max-memory int             soft heap limit in MB, flush and GC when exceeded, 0 = off (default: 0)\n")
 	fmt.Fprintf(w, "  -start int                  start entry index (default: auto)\n")
 	fmt.Fprintf(w, "  -n, -count int              entries to fetch, 0 = all (default: 0)\n")
 	fmt.Fprintf(w, "  -from-end                   start from newest entries\n")
+	fmt.Fprintf(w, "  -recent duration            scrape entries logged within this duration, e.g. 6h\n")
+	fmt.Fprintf(w, "  -reverse                    fetch batches newest-first within the selected range\n")
+	fmt.Fprintf(w, "  -autotune                   calibrate -w/-bs against the log for ~10s before scraping\n")
+	fmt.Fprintf(w, "  -ramp-start int             workers to start with, set to -w to skip ramping (default: 1)\n")
+	fmt.Fprintf(w, "  -ramp-interval duration     interval between adding workers (default: 500ms)\n")
+
+	fmt.Fprintf(w, "\nFILTERS:\n")
+	fmt.Fprintf(w, "  -exact-match                match -d/-df domains exactly, without subdomains\n")
+	fmt.Fprintf(w, "  -subdomains-only            match only subdomains of -d/-df domains, not the domain itself\n")
+	fmt.Fprintf(w, "  -brand-match                also match names embedding a domain's first label (brand_embedded in JSON)\n")
+	fmt.Fprintf(w, "  -exclude-expired-at-log     drop certs already expired when logged (expired_when_logged in JSON)\n")
+	fmt.Fprintf(w, "  -min-not-before string      drop certs issued before a date (YYYY-MM-DD or RFC3339)\n")
+	fmt.Fprintf(w, "  -entry-type string          only parse x509 or precert entries, skipped before parsing: x509/precert/both (default: both)\n")
+	fmt.Fprintf(w, "  -strict-timestamps          drop entries with leaf timestamps before 2013 or in the future\n")
+	fmt.Fprintf(w, "  -ca-only                    only output CA certificates (basic constraints CA=true)\n")
+	fmt.Fprintf(w, "  -eku string[]               only output certs with extended key usage (serverAuth/clientAuth/codeSigning/...)\n")
+	fmt.Fprintf(w, "  -validation-level string[]  only output certs asserting a CA/B Forum level: dv/ov/iv/ev\n")
+	fmt.Fprintf(w, "  -serial string[]            only output certs with this hex serial (colons and case ignored)\n")
+	fmt.Fprintf(w, "  -issuer string[]            only output certs whose issuer contains this substring\n")
+	fmt.Fprintf(w, "  -issuer-file string         file of allowed issuer substrings (one per line)\n")
+	fmt.Fprintf(w, "  -deny-issuer-file string    file of issuer substrings to exclude (one per line)\n")
 
 	fmt.Fprintf(w, "\nMONITOR:\n")
 	fmt.Fprintf(w, "  -m, -monitor                continuous monitoring mode\n")
 	fmt.Fprintf(w, "  -pi, -poll-interval int     seconds between polls (default: 10)\n")
+	fmt.Fprintf(w, "  -poll-concurrency int       logs polled at once, 0 = same as -w (default: 0)\n")
+	fmt.Fprintf(w, "  -monitor-timestamps         prefix output with discovery time (discovered_at in JSON)\n")
+	fmt.Fprintf(w, "  -monitor-idle-timeout dur   stop after no new entries for this long, 0 = never (default: 0)\n")
+	fmt.Fprintf(w, "  -poll-once                  poll once, save tree sizes and exit, for cron (implies -m)\n")
+	fmt.Fprintf(w, "  -alert-output string        also write results matching -alert-domains to this file\n")
+	fmt.Fprintf(w, "  -alert-domains string       critical subset of domains for -alert-output (comma-separated)\n")
+	fmt.Fprintf(w, "  -max-log-age duration       skip logs whose latest tree head is older than this, 0 = keep all (default: 0)\n")
 
 	fmt.Fprintf(w, "\nOUTPUT:\n")
-	fmt.Fprintf(w, "  -o, -output string          output file path\n")
+	fmt.Fprintf(w, "  -o, -output string          output file path; .json/.jsonl/.ndjson -> -json, .tsv -> -group-by cert\n")
 	fmt.Fprintf(w, "  -j, -json                   JSON line output\n")
-	fmt.Fprintf(w, "  -f, -fields string          output fields: domains/ips/emails/certs/all (default: domains)\n")
+	fmt.Fprintf(w, "  -proto                      length-prefixed protobuf output (schema: proto/cert_result.proto)\n")
+	fmt.Fprintf(w, "  -link-precerts              pair precerts with final certs seen in the run (final_for/precert_of in JSON)\n")
+	fmt.Fprintf(w, "  -include-raw                add base64 cert DER to JSON (der_b64, precert_tbs_b64 for precerts)\n")
+	fmt.Fprintf(w, "  -json-meta                  write a {\"_meta\": ...} line with log URL, index range and version per log\n")
+	fmt.Fprintf(w, "  -show-match                 add the matching filter and name to JSON (matched_filter, matched_domain)\n")
+	fmt.Fprintf(w, "  -count-matches              print the number of matching entries instead of the results\n")
+	fmt.Fprintf(w, "  -max-results int            stop scraping after this many unique results, 0 = unlimited (default: 0)\n")
+	fmt.Fprintf(w, "  -flush-interval duration    flush output on a timer instead of every batch, 0 = every batch (default: 0)\n")
+	fmt.Fprintf(w, "  -output-buffer-size int     output write buffer in bytes, 0 = 4096 (default: 0)\n")
+	fmt.Fprintf(w, "  -archive-leaves string      save raw leaf_input/extra_data of matching entries to a directory\n")
+	fmt.Fprintf(w, "  -archive-max-mb int         stop archiving leaves after this many MB, 0 = unlimited (default: 1024)\n")
+	fmt.Fprintf(w, "  -ordered                    write results in log index order (bounded by -order-window)\n")
+	fmt.Fprintf(w, "  -order-window int           with -ordered, max entries held back before writing out of order (default: 100000)\n")
+	fmt.Fprintf(w, "  -exec string                stream matching results as JSON lines to this command's stdin\n")
+	fmt.Fprintf(w, "  -progress-json string       write progress as JSON lines to a file or fd number (e.g. 3)\n")
+	fmt.Fprintf(w, "  -rollup-cidr string         collapse IP output into CIDR blocks: /24 or /24,/64 (IPv4[,IPv6])\n")
+	fmt.Fprintf(w, "  -table                      aligned table of CN, issuer, not-after, #domains (plain lines when piped)\n")
+	fmt.Fprintf(w, "  -group-by string            'cert': one tab-separated line per cert: serial, CN, domains, IPs, emails\n")
+	fmt.Fprintf(w, "  -sep string                 separator between list values in -f certs/usage and -group-by (default: \",\")\n")
+	fmt.Fprintf(w, "  -ci-dedup                   dedup line output ignoring case, keeping the first spelling seen\n")
+	fmt.Fprintf(w, "  -export-seen string         write the dedup set to this file at the end of the run\n")
+	fmt.Fprintf(w, "  -psl-file string            public suffix list for registered_domain (default: embedded copy)\n")
+	fmt.Fprintf(w, "  -seed-dedup string          skip values already in this earlier output file (same -f or -json)\n")
+	fmt.Fprintf(w, "  -max-per-domain int         stop writing results for a matched domain after N lines (default: 0, no cap)\n")
+	fmt.Fprintf(w, "  -template string            Go text/template over the cert result, one line per cert (overrides -f)\n")
+	fmt.Fprintf(w, "  -f, -fields string          output fields: domains/ips/emails/certs/usage/policy/revocation/serials/all (default: domains)\n")
 	fmt.Fprintf(w, "  -s, -silent                 only output results (no banner, no progress)\n")
+	fmt.Fprintf(w, "  -quiet-progress             drop the periodic progress lines, keep the summary and warnings\n")
 	fmt.Fprintf(w, "  -v, -verbose                verbose/debug output\n")
+	fmt.Fprintf(w, "  -vv, -verbose-http          -v plus every HTTP request with status, duration and size\n")
+	fmt.Fprintf(w, "  -trace-file string          record every fetched and dropped index range to this file\n")
 	fmt.Fprintf(w, "  -nc, -no-color              disable color output\n")
 
 	fmt.Fprintf(w, "\nUPDATE:\n")
@@ -238,5 +840,10 @@ func printFlags() {
 
 	fmt.Fprintf(w, "\nSTATE:\n")
 	fmt.Fprintf(w, "  -resume                     resume from last saved position\n")
+	fmt.Fprintf(w, "  -resume-auto duration       resume only from state newer than this, e.g. 24h; implies -resume\n")
+	fmt.Fprintf(w, "  -resume-overlap int         re-fetch N entries before the saved position (default: 0)\n")
+	fmt.Fprintf(w, "  -resume-dedup               append to -o and skip results the file already holds\n")
+	fmt.Fprintf(w, "  -durable-queue              track finished batches on disk, restart on exactly the unfinished ones\n")
+	fmt.Fprintf(w, "  -since-index-file string    continue each log after the index saved by the last completed run\n")
 	fmt.Fprintf(w, "  -state-dir string           state file directory (default: ~/.ct-hulhu)\n")
 }
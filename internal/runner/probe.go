@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// probeTimeout caps each -probe request, so a dead log doesn't hold up
+// the report for the full -to.
+const probeTimeout = 5 * time.Second
+
+type probeResult struct {
+	URL       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	LatencyMs int64  `json:"latency_ms"`
+	TreeSize  int64  `json:"tree_size"`
+	Error     string `json:"error,omitempty"`
+}
+
+// probe sends a single get-sth to every selected log, without retries, and
+// reports which ones answer and how fast. Reachable logs are listed
+// fastest first.
+func (r *Runner) probe(ctx context.Context) error {
+	logURLs, err := r.resolveLogURLs(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeout := min(time.Duration(r.opts.Timeout)*time.Second, probeTimeout)
+	results := make([]probeResult, len(logURLs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.opts.Workers)
+	for i, logURL := range logURLs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			defer wg.Done()
+			started := time.Now()
+			sth, err := r.newClientWith(logURL, timeout, 0).GetSTH(ctx)
+			res := probeResult{URL: logURL, LatencyMs: time.Since(started).Milliseconds()}
+			if err != nil {
+				r.warnLogError(logURL, err)
+				res.Error = err.Error()
+			} else {
+				res.Reachable = true
+				res.TreeSize = sth.TreeSize
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(results, func(a, b probeResult) int {
+		if a.Reachable != b.Reachable {
+			if a.Reachable {
+				return -1
+			}
+			return 1
+		}
+		if !a.Reachable {
+			return 0
+		}
+		return cmp.Compare(a.LatencyMs, b.LatencyMs)
+	})
+
+	reachable := 0
+	if !r.opts.JSON {
+		fmt.Printf("%-11s %9s %14s  %s\n", "STATUS", "LATENCY", "TREE SIZE", "URL")
+	}
+	for _, res := range results {
+		if res.Reachable {
+			reachable++
+		}
+		if r.opts.JSON {
+			data, err := json.Marshal(res)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			continue
+		}
+		if res.Reachable {
+			fmt.Printf("%-11s %7dms %14d  %s\n", "ok", res.LatencyMs, res.TreeSize, res.URL)
+		} else {
+			fmt.Printf("%-11s %9s %14s  %s\n%13s%s\n", "unreachable", "-", "-", res.URL, "", res.Error)
+		}
+	}
+
+	log.Info("%d/%d logs reachable", reachable, len(results))
+	if reachable == 0 {
+		return fmt.Errorf("no CT log answered the probe")
+	}
+	return nil
+}
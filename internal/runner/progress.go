@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const progressInterval = 5 * time.Second
+
+// progressEvent is one -progress-json line. Done marks the last event for
+// a log. The run summary written after the last log has no Log and
+// carries MatchesByLog.
+type progressEvent struct {
+	Log          string           `json:"log"`
+	Processed    int64            `json:"processed"`
+	Total        int64            `json:"total"`
+	Rate         float64          `json:"rate"`
+	Results      int              `json:"results"`
+	Matched      int64            `json:"matched"`
+	Done         bool             `json:"done,omitempty"`
+	MatchesByLog map[string]int64 `json:"matches_by_log,omitempty"`
+}
+
+// progressSink writes progress events as NDJSON for programs wrapping
+// ct-hulhu. A nil sink discards events.
+type progressSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// c is the file the sink created itself, nil for an inherited
+	// descriptor, which is left open.
+	c io.Closer
+}
+
+// openProgressSink opens spec as an already open file descriptor when it
+// is a number, and as a file path otherwise.
+func openProgressSink(spec string) (*progressSink, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var f *os.File
+	if fd, err := strconv.Atoi(spec); err == nil {
+		f = os.NewFile(uintptr(fd), "progress-json")
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("file descriptor %d: %w", fd, err)
+		}
+		return &progressSink{enc: json.NewEncoder(f)}, nil
+	}
+	f, err := os.Create(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &progressSink{enc: json.NewEncoder(f), c: f}, nil
+}
+
+func (p *progressSink) emit(ev progressEvent) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.enc.Encode(ev); err != nil {
+		log.Debug("progress-json: %v", err)
+	}
+}
+
+func (p *progressSink) Close() error {
+	if p == nil || p.c == nil {
+		return nil
+	}
+	return p.c.Close()
+}
+
+// progressReporter is the one place progress is reported from, for every
+// log of a run: the periodic and SIGUSR1 log lines and the -progress-json
+// events. A scrape points it at the log being fetched with track; monitor
+// mode describes the whole run with setIdle instead. A nil reporter
+// reports nothing.
+type progressReporter struct {
+	sink      *progressSink
+	stats     *stats
+	quiet     bool
+	statusReq <-chan struct{}
+
+	mu      sync.Mutex
+	current func() progressEvent
+	idle    func() string
+}
+
+// start reports every progressInterval and on each status request until
+// the returned stop func is called.
+func (p *progressReporter) start(ctx context.Context) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.statusReq:
+				p.report(false)
+			case <-ticker.C:
+				p.report(true)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// track makes snapshot the log progress is reported for, until untrack is
+// called.
+func (p *progressReporter) track(snapshot func() progressEvent) (untrack func()) {
+	if p == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	p.current = snapshot
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		p.current = nil
+		p.mu.Unlock()
+	}
+}
+
+// setIdle sets the status a status request gets while no log is tracked.
+func (p *progressReporter) setIdle(status func() string) {
+	p.mu.Lock()
+	p.idle = status
+	p.mu.Unlock()
+}
+
+// report writes the progress of the tracked log. Periodic reports skip a
+// log that has processed nothing yet and, with -quiet-progress, leave out
+// the log line. With no log tracked only a status request is answered.
+func (p *progressReporter) report(periodic bool) {
+	p.mu.Lock()
+	current, idle := p.current, p.idle
+	p.mu.Unlock()
+	if current == nil {
+		switch {
+		case periodic:
+		case idle != nil:
+			log.Info("%s", idle())
+		default:
+			log.Info("totals so far: %s", p.stats.snapshot())
+		}
+		return
+	}
+
+	ev := current()
+	if periodic && ev.Processed == 0 {
+		return
+	}
+	p.sink.emit(ev)
+	if periodic && p.quiet {
+		return
+	}
+	pct := float64(ev.Processed) / float64(ev.Total) * 100
+	log.Info("progress: %d/%d (%.1f%%) - %.0f entries/sec - %d results",
+		ev.Processed, ev.Total, pct, ev.Rate, ev.Results)
+}
+
+// finish writes the last event for a log.
+func (p *progressReporter) finish(ev progressEvent) {
+	if p == nil {
+		return
+	}
+	ev.Done = true
+	p.sink.emit(ev)
+}
+
+// summary writes the run summary event: totals across every log and the
+// matches each of them produced.
+func (p *progressReporter) summary(results int) {
+	if p == nil {
+		return
+	}
+	byLog := make(map[string]int64)
+	for _, c := range p.stats.matchesByLog() {
+		byLog[c.log] = c.matched
+	}
+	p.sink.emit(progressEvent{
+		Processed:    p.stats.parsed.Load(),
+		Results:      results,
+		Matched:      p.stats.matched.Load(),
+		Done:         true,
+		MatchesByLog: byLog,
+	})
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// resultSink receives matching results from parseBatch.
+type resultSink interface {
+	WriteResult(result *ctlog.CertResult)
+}
+
+// resultCollector keeps one batch's results for the reorder buffer.
+type resultCollector struct {
+	mu      sync.Mutex
+	results []*ctlog.CertResult
+}
+
+func (c *resultCollector) WriteResult(result *ctlog.CertResult) {
+	c.mu.Lock()
+	c.results = append(c.results, result)
+	c.mu.Unlock()
+}
+
+type heldBatch struct {
+	end     int64
+	results []*ctlog.CertResult
+}
+
+// reorderBuffer writes batch results in index order. A batch that arrives
+// ahead of the next expected index is held until the gap before it fills.
+// Held batches may cover at most window entries; past that the lowest one
+// is written anyway and the gap is skipped, so output is only roughly
+// ordered around dropped or very slow batches but memory stays bounded.
+type reorderBuffer struct {
+	next        int64
+	window      int64
+	held        map[int64]heldBatch
+	heldEntries int64
+	write       func(*ctlog.CertResult)
+}
+
+func newReorderBuffer(start, window int64, write func(*ctlog.CertResult)) *reorderBuffer {
+	return &reorderBuffer{next: start, window: window, held: make(map[int64]heldBatch), write: write}
+}
+
+// add takes the results of the batch [start, end). Late batches, e.g.
+// from -retry-dropped, are written straight away.
+func (b *reorderBuffer) add(start, end int64, results []*ctlog.CertResult) {
+	slices.SortFunc(results, func(x, y *ctlog.CertResult) int { return cmp.Compare(x.Index, y.Index) })
+	if start < b.next {
+		b.emit(results)
+		return
+	}
+	b.held[start] = heldBatch{end: end, results: results}
+	b.heldEntries += end - start
+	b.release()
+	for b.heldEntries > b.window && len(b.held) > 0 {
+		b.next = b.lowestHeld()
+		b.release()
+	}
+}
+
+// release writes every held batch that continues the ordered prefix.
+func (b *reorderBuffer) release() {
+	for {
+		batch, ok := b.held[b.next]
+		if !ok {
+			return
+		}
+		delete(b.held, b.next)
+		b.heldEntries -= batch.end - b.next
+		b.emit(batch.results)
+		b.next = batch.end
+	}
+}
+
+func (b *reorderBuffer) lowestHeld() int64 {
+	lowest := int64(-1)
+	for start := range b.held {
+		if lowest < 0 || start < lowest {
+			lowest = start
+		}
+	}
+	return lowest
+}
+
+// flush writes everything still held, lowest index first.
+func (b *reorderBuffer) flush() {
+	if b == nil {
+		return
+	}
+	for len(b.held) > 0 {
+		b.next = b.lowestHeld()
+		b.release()
+	}
+}
+
+func (b *reorderBuffer) emit(results []*ctlog.CertResult) {
+	for _, result := range results {
+		b.write(result)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"maps"
+	"math/rand/v2"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,15 +23,42 @@ import (
 	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
 	"github.com/TheArqsz/ct-hulhu/internal/loglist"
 	"github.com/TheArqsz/ct-hulhu/internal/output"
+	"github.com/TheArqsz/ct-hulhu/internal/psl"
 	"github.com/TheArqsz/ct-hulhu/internal/updater"
 )
 
 type Runner struct {
-	opts *Options
+	opts        *Options
+	statusReq   chan struct{}
+	pause       *ctlog.PauseGate
+	retryBudget *ctlog.RetryBudget
+	limiter     *ctlog.RequestLimiter
+	stats       stats
+	progress    *progressReporter
+	archive     *leafArchive
+	hook        *execHook
+	alert       *alertOutput
+	sinceIndex  *sinceIndex
+	trace       *rangeTrace
 }
 
 func New(opts *Options) *Runner {
-	return &Runner{opts: opts}
+	r := &Runner{
+		opts:      opts,
+		statusReq: make(chan struct{}, 1),
+		pause:     ctlog.NewPauseGate(),
+	}
+	r.progress = &progressReporter{stats: &r.stats, quiet: opts.QuietProgress, statusReq: r.statusReq}
+	if opts.MaxTotalRetries > 0 {
+		r.retryBudget = ctlog.NewRetryBudget(opts.MaxTotalRetries)
+		r.retryBudget.SetOnExhausted(func() {
+			log.Warning("retry budget of %d exhausted - failing requests will no longer be retried", opts.MaxTotalRetries)
+		})
+	}
+	if opts.GlobalWorkers > 0 {
+		r.limiter = ctlog.NewRequestLimiter(opts.GlobalWorkers)
+	}
+	return r
 }
 
 func (r *Runner) Run() error {
@@ -45,10 +76,17 @@ func (r *Runner) Run() error {
 		log.Warning("interrupt received, shutting down gracefully...")
 		cancel()
 	}()
+	r.handleControlSignals(ctx)
+	updater.SetMinTLSVersion(r.opts.minTLS)
+	updater.RemoveStaleBinary()
 
 	if r.opts.Update {
 		return updater.Update(ctx, getVersion())
 	}
+	if r.opts.Diff != "" {
+		return r.diffOutputs()
+	}
+	r.loadPSL()
 
 	if !r.opts.DisableUpdateCheck && !r.opts.Silent {
 		go func() {
@@ -65,6 +103,42 @@ func (r *Runner) Run() error {
 	if r.opts.ListLogs {
 		return r.listLogs(ctx)
 	}
+	if r.opts.ListOperators {
+		return r.listOperators(ctx)
+	}
+	if r.opts.ListLogsDiff {
+		return r.listLogsDiff(ctx)
+	}
+	if r.opts.GetSTH {
+		return r.getSTHs(ctx)
+	}
+	if r.opts.Probe {
+		return r.probe(ctx)
+	}
+	if r.opts.ArchiveLeaves != "" {
+		archive, err := newLeafArchive(r.opts.ArchiveLeaves, r.opts.ArchiveMaxMB)
+		if err != nil {
+			return fmt.Errorf("creating -archive-leaves directory: %w", err)
+		}
+		r.archive = archive
+		defer r.archive.report()
+	}
+	if r.opts.TraceFile != "" {
+		trace, err := openRangeTrace(r.opts.TraceFile)
+		if err != nil {
+			return fmt.Errorf("creating -trace-file: %w", err)
+		}
+		r.trace = trace
+		defer r.trace.close()
+	}
+	if r.opts.Exec != "" {
+		hook, err := startExecHook(r.opts.Exec)
+		if err != nil {
+			return fmt.Errorf("starting -exec command: %w", err)
+		}
+		r.hook = hook
+		defer r.hook.close()
+	}
 	if r.opts.Monitor {
 		return r.monitor(ctx)
 	}
@@ -72,17 +146,14 @@ func (r *Runner) Run() error {
 }
 
 func (r *Runner) listLogs(ctx context.Context) error {
-	timeout := time.Duration(r.opts.Timeout) * time.Second
-	fetcher := loglist.NewFetcher(timeout)
-
 	log.Info("fetching CT log list...")
 
-	logList, err := fetcher.FetchDefault(ctx)
+	logList, err := r.fetchLogList(ctx)
 	if err != nil {
-		return fmt.Errorf("fetching log list: %w", err)
+		return err
 	}
 
-	logs := loglist.FilterLogs(logList, r.opts.LogState)
+	logs := r.filterLogs(logList)
 
 	if r.opts.JSON {
 		for _, l := range logs {
@@ -116,8 +187,172 @@ func (r *Runner) listLogs(ctx context.Context) error {
 	return nil
 }
 
+// operatorStates is the column order of the -list-operators table.
+var operatorStates = []string{"usable", "qualified", "readonly", "retired", "pending", "rejected"}
+
+func (r *Runner) listOperators(ctx context.Context) error {
+	log.Info("fetching CT log list...")
+
+	logList, err := r.fetchLogList(ctx)
+	if err != nil {
+		return err
+	}
+
+	logs := loglist.FilterLogs(logList, "all")
+	summaries := loglist.SummarizeOperators(logs)
+
+	var entries map[string]int64
+	if r.opts.GetSTH {
+		entries = r.operatorEntries(ctx, logs)
+	}
+
+	if r.opts.JSON {
+		for _, s := range summaries {
+			record := map[string]any{
+				"operator": output.Sanitize(s.Name),
+				"logs":     s.Logs,
+				"states":   s.States,
+			}
+			if entries != nil {
+				record["entries"] = entries[s.Name]
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				log.Debug("json marshal error: %v", err)
+				continue
+			}
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	header := fmt.Sprintf("%-30s %5s", "OPERATOR", "LOGS")
+	for _, state := range operatorStates {
+		header += fmt.Sprintf(" %9s", strings.ToUpper(state))
+	}
+	if entries != nil {
+		header += fmt.Sprintf(" %14s", "ENTRIES")
+	}
+	fmt.Println(header)
+	fmt.Println(strings.Repeat("-", len(header)))
+	for _, s := range summaries {
+		line := fmt.Sprintf("%-30s %5d", truncate(output.Sanitize(s.Name), 30), s.Logs)
+		for _, state := range operatorStates {
+			line += fmt.Sprintf(" %9d", s.States[state])
+		}
+		if entries != nil {
+			line += fmt.Sprintf(" %14d", entries[s.Name])
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\nTotal: %d operators, %d logs\n", len(summaries), len(logs))
+	return nil
+}
+
+// operatorEntries sums tree sizes per operator. Read-only logs use the
+// final tree size from the log list; retired and rejected logs are
+// skipped, and the rest are asked for their STH.
+func (r *Runner) operatorEntries(ctx context.Context, logs []loglist.LogWithOperator) map[string]int64 {
+	var mu sync.Mutex
+	entries := make(map[string]int64)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.opts.Workers)
+	for _, l := range logs {
+		switch l.Log.CurrentState() {
+		case "readonly":
+			entries[l.Operator] += l.Log.State.ReadOnly.FinalTreeSize
+			continue
+		case "retired", "rejected":
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			defer wg.Done()
+			sth, err := r.newClient(l.Log.FullURL()).GetSTH(ctx)
+			if err != nil {
+				log.Debug("skipping %s: %v", l.Log.FullURL(), err)
+				return
+			}
+			mu.Lock()
+			entries[l.Operator] += sth.TreeSize
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return entries
+}
+
+func (r *Runner) getSTHs(ctx context.Context) error {
+	logURLs, err := r.resolveLogURLs(ctx)
+	if err != nil {
+		return err
+	}
+
+	sths := make([]*ctlog.STH, len(logURLs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.opts.Workers)
+	for i, logURL := range logURLs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			defer wg.Done()
+			sth, err := r.newClient(logURL).GetSTH(ctx)
+			if err != nil {
+				r.warnLogError(logURL, err)
+				log.Warning("skipping %s: %v", logURL, err)
+				return
+			}
+			sths[i] = sth
+		}()
+	}
+	wg.Wait()
+
+	fetched := 0
+	for i, sth := range sths {
+		if sth == nil {
+			continue
+		}
+		fetched++
+		if err := r.writeSTH(os.Stdout, logURLs[i], sth); err != nil {
+			return err
+		}
+	}
+	if fetched == 0 {
+		return fmt.Errorf("could not fetch an STH from any CT log")
+	}
+	return nil
+}
+
+func (r *Runner) writeSTH(w io.Writer, logURL string, sth *ctlog.STH) error {
+	ts := time.UnixMilli(sth.Timestamp).UTC().Format("2006-01-02T15:04:05.000Z")
+	if r.opts.JSON {
+		data, err := json.Marshal(map[string]any{
+			"url":                 logURL,
+			"tree_size":           sth.TreeSize,
+			"timestamp":           ts,
+			"sha256_root_hash":    sth.SHA256RootHash,
+			"tree_head_signature": sth.TreeHeadSignature,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s\n  tree size: %d\n  timestamp: %s\n  root hash: %s\n  signature: %s\n",
+		logURL, sth.TreeSize, ts,
+		output.Sanitize(sth.SHA256RootHash), output.Sanitize(sth.TreeHeadSignature))
+	return err
+}
+
 func (r *Runner) scrape(ctx context.Context) error {
-	domains := r.collectDomains()
+	domains, err := r.collectDomains()
+	if err != nil {
+		return err
+	}
 
 	logURLs, err := r.resolveLogURLs(ctx)
 	if err != nil {
@@ -127,40 +362,86 @@ func (r *Runner) scrape(ctx context.Context) error {
 		return fmt.Errorf("no CT logs to scrape - use -lu <url> to specify a log or omit to auto-discover")
 	}
 
-	writer, err := output.NewWriter(r.opts.Output, r.opts.JSON, r.opts.Fields)
+	writer, err := r.newWriter()
 	if err != nil {
 		return err
 	}
 	defer writer.Close()
 
-	parser := certparser.New(domains)
+	parser, err := r.newParser(domains)
+	if err != nil {
+		return err
+	}
+
+	r.progress.sink, err = openProgressSink(r.opts.ProgressJSON)
+	if err != nil {
+		return fmt.Errorf("opening -progress-json: %w", err)
+	}
+	defer r.progress.sink.Close()
+	defer r.progress.start(ctx)()
+
+	if r.opts.SinceIndex != "" {
+		if r.sinceIndex, err = loadSinceIndex(r.opts.SinceIndex); err != nil {
+			return fmt.Errorf("reading -since-index-file: %w", err)
+		}
+	}
 
 	if len(domains) > 0 {
 		log.Info("filtering for domains: %s", strings.Join(domains, ", "))
 	}
 
+	memCtx, stopMem := context.WithCancel(ctx)
+	defer stopMem()
+	go r.watchMemory(memCtx, writer)
+
 	for _, logURL := range logURLs {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
 		if err := r.scrapeLog(ctx, logURL, parser, writer); err != nil {
+			if errors.Is(err, errResultCap) {
+				log.Info("stopped: reached -max-results cap of %d results", r.opts.MaxResults)
+				break
+			}
+			r.stats.errors.Add(1)
+			if r.opts.FailFast {
+				return fmt.Errorf("scraping %s: %w", logURL, err)
+			}
 			log.Warning("error scraping %s: %v", logURL, err)
 			continue
 		}
 	}
 
-	log.Success("done - %d unique results written", writer.Stats())
+	if r.opts.CountMatches {
+		log.Success("done - %d matching entries", r.stats.matched.Load())
+		fmt.Println(r.stats.matched.Load())
+	} else {
+		log.Success("done - %d unique results written", writer.Stats())
+		reportDedup(writer)
+	}
+	log.Info("totals across %d log(s): %s", len(logURLs), r.stats.snapshot())
+	r.progress.summary(writer.Stats())
+	if len(logURLs) > 1 {
+		r.reportMatchesByLog(len(logURLs))
+	}
+	r.reportParserSkips(parser)
+	r.reportHTTPDiagnostics()
 	return nil
 }
 
+var errResultCap = errors.New("result cap reached")
+
 func (r *Runner) scrapeLog(ctx context.Context, logURL string, parser *certparser.Parser, writer *output.Writer) error {
-	timeout := time.Duration(r.opts.Timeout) * time.Second
-	client := ctlog.NewClient(logURL, timeout, r.opts.Retries)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client := r.newClient(logURL)
 
 	log.Info("connecting to %s", logURL)
 
 	sth, err := client.GetSTH(ctx)
 	if err != nil {
+		r.warnLogError(logURL, err)
 		return fmt.Errorf("getting STH: %w", err)
 	}
 
@@ -168,6 +449,23 @@ func (r *Runner) scrapeLog(ctx context.Context, logURL string, parser *certparse
 	log.Info("tree size: %d entries", treeSize)
 
 	start, end := r.calculateRange(treeSize)
+	if r.opts.Recent > 0 {
+		since := time.Now().Add(-r.opts.Recent)
+		if start, err = client.FindIndexAt(ctx, treeSize, since); err != nil {
+			r.warnLogError(logURL, err)
+			return fmt.Errorf("finding entries since %s: %w", since.UTC().Format(time.RFC3339), err)
+		}
+		log.Info("entries logged since %s start at index %d", since.UTC().Format(time.RFC3339), start)
+	}
+	if r.sinceIndex != nil {
+		if last, ok := r.sinceIndex.last(logURL); ok {
+			start, end = last+1, treeSize
+			if r.opts.Count > 0 {
+				end = min(start+r.opts.Count, treeSize)
+			}
+			log.Info("continuing after entry %d from %s", last, r.opts.SinceIndex)
+		}
+	}
 	if start >= end {
 		log.Info("no entries to process")
 		return nil
@@ -176,89 +474,178 @@ func (r *Runner) scrapeLog(ctx context.Context, logURL string, parser *certparse
 	totalEntries := end - start
 
 	if r.opts.Resume {
-		progress := r.loadProgress(logURL)
-		if progress != nil && progress.LastIndex > start {
-			start = progress.LastIndex + 1
-			if start >= end {
-				log.Info("resume: all entries already processed for this log")
-				return nil
-			}
-			totalEntries = end - start
-			log.Info("resuming from entry %d (%d entries remaining)", start, totalEntries)
-		} else {
-			log.Info("resume: no saved state found for this log, starting fresh")
+		start, end = r.resumeRange(logURL, start, end)
+		if start >= end {
+			log.Info("resume: all entries already processed for this log")
+			return nil
+		}
+		totalEntries = end - start
+	}
+
+	var queue *ledger
+	var pending []ctlog.Range
+	if r.opts.DurableQueue {
+		queue = r.loadLedger(logURL)
+		pending = queue.pending(start, end)
+		totalEntries = rangeSize(pending)
+		if totalEntries == 0 {
+			log.Info("durable queue: all entries already processed for this log")
+			return nil
+		}
+		log.Info("durable queue: %d entries pending in %d ranges", totalEntries, len(pending))
+	}
+
+	workers, batchSize := r.opts.Workers, r.opts.BatchSize
+	if r.opts.Autotune {
+		workers, batchSize = r.autotune(ctx, logURL, start, end)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 	}
+	batchSize = r.probeBatchLimit(ctx, client, batchSize, start, treeSize)
 
 	log.Info("scraping entries %d to %d (%d entries) with %d workers",
-		start, end-1, totalEntries, r.opts.Workers)
+		start, end-1, totalEntries, workers)
 
-	pool := ctlog.NewWorkerPool(client, r.opts.BatchSize, r.opts.Workers, r.opts.RateLimit)
-	pool.SetDebugLog(log.Debug)
-	results := make(chan ctlog.EntryBatch, r.opts.Workers*2)
+	pool := r.newPool(client, batchSize, workers)
+	results := make(chan ctlog.EntryBatch, workers*2)
 
 	var processed atomic.Int64
 	startTime := time.Now()
 
-	stopProgress := make(chan struct{})
-	progressDone := make(chan struct{})
-	go func() {
-		defer close(progressDone)
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-stopProgress:
-				return
-			case <-ticker.C:
-				done := processed.Load()
-				if done == 0 {
-					continue
-				}
-				elapsed := time.Since(startTime)
-				rate := float64(done) / elapsed.Seconds()
-				pct := float64(done) / float64(totalEntries) * 100
-				log.Info("progress: %d/%d (%.1f%%) - %.0f entries/sec - %d results",
-					done, totalEntries, pct, rate, writer.Stats())
-			}
+	snapshot := func() progressEvent {
+		done := processed.Load()
+		return progressEvent{
+			Log:       logURL,
+			Processed: done,
+			Total:     totalEntries,
+			Rate:      float64(done) / time.Since(startTime).Seconds(),
+			Results:   writer.Stats(),
+			Matched:   r.stats.logMatches(logURL),
 		}
-	}()
+	}
+	untrack := r.progress.track(snapshot)
 
 	fetchErr := make(chan error, 1)
 	go func() {
+		if queue != nil {
+			fetchErr <- pool.FetchRanges(ctx, pending, results)
+			return
+		}
 		fetchErr <- pool.FetchRange(ctx, start, end, results)
 	}()
 
 	parseSem := r.newParseSem()
+	var reorder *reorderBuffer
+	if r.opts.Ordered {
+		reorder = newReorderBuffer(start, r.opts.OrderWindow, writer.WriteResult)
+	}
+	handle := func(batch ctlog.EntryBatch) {
+		if reorder != nil {
+			batchResults := &resultCollector{}
+			r.parseBatch(batch, parser, batchResults, logURL, parseSem, &processed)
+			reorder.add(batch.StartIndex, batch.StartIndex+int64(len(batch.Entries)), batchResults.results)
+		} else {
+			r.parseBatch(batch, parser, writer, logURL, parseSem, &processed)
+		}
+		if queue != nil {
+			queue.markDone(ctlog.Range{Start: batch.StartIndex, End: batch.StartIndex + int64(len(batch.Entries)) - 1})
+		}
+	}
 
-	var lastSaveCount int64
+	// lastIdx is the resume frontier: the highest index reached going
+	// forward, or the lowest one when fetching in reverse. low and high
+	// bound the entries actually fetched, for -json-meta.
+	var lastSaveCount, lastIdx int64
+	low, high := end, start-1
+	cover := func(batch ctlog.EntryBatch) {
+		if len(batch.Entries) > 0 {
+			low = min(low, batch.StartIndex)
+			high = max(high, batch.StartIndex+int64(len(batch.Entries))-1)
+		}
+	}
+	capped := false
 	for batch := range results {
-		r.parseBatch(batch, parser, writer, logURL, parseSem, &processed)
-		writer.Flush()
+		if capped {
+			continue
+		}
+		handle(batch)
+		if r.flushInterval() == 0 {
+			writer.Flush()
+		}
+		cover(batch)
+		if r.opts.Reverse {
+			lastIdx = batch.StartIndex
+		} else {
+			lastIdx = batch.StartIndex + int64(len(batch.Entries)) - 1
+		}
 
-		if r.opts.Resume {
-			current := processed.Load()
-			if current-lastSaveCount >= 10000 {
-				lastIdx := batch.StartIndex + int64(len(batch.Entries)) - 1
+		if current := processed.Load(); current-lastSaveCount >= 10000 {
+			if r.opts.Resume {
 				r.saveProgress(logURL, treeSize, lastIdx, current)
-				lastSaveCount = current
 			}
+			if queue != nil {
+				r.saveLedger(queue)
+			}
+			lastSaveCount = current
+		}
+
+		if r.opts.MaxResults > 0 && writer.Stats() >= r.opts.MaxResults {
+			capped = true
+			cancel()
 		}
 	}
 
-	close(stopProgress)
-	<-progressDone
+	dropped := pool.DroppedEntries()
+	if r.opts.RetryDropped && dropped > 0 && !capped && ctx.Err() == nil {
+		dropped = r.retryDropped(ctx, client, pool.DroppedRanges(), batchSize, workers, func(batch ctlog.EntryBatch) {
+			handle(batch)
+			cover(batch)
+		})
+	}
+	reorder.flush()
+	r.stats.dropped.Add(dropped)
+
+	if r.opts.JSONMeta && !r.opts.CountMatches && high >= low {
+		writer.WriteMeta(output.Meta{
+			LogURL:      logURL,
+			Start:       low,
+			End:         high,
+			TreeSize:    treeSize,
+			ToolVersion: getVersion(),
+			StartedAt:   startTime.UTC().Format(time.RFC3339),
+		})
+	}
+
+	untrack()
 
 	writer.Flush()
+	r.progress.finish(snapshot())
 
 	if r.opts.Resume {
-		r.saveProgress(logURL, treeSize, end-1, processed.Load())
+		saveIdx := end - 1
+		if r.opts.Reverse {
+			saveIdx = start
+		}
+		if capped {
+			saveIdx = lastIdx
+		}
+		r.saveProgress(logURL, treeSize, saveIdx, processed.Load())
 		log.Info("resume state saved to %s", r.stateFilePath(logURL))
 	}
+	if queue != nil {
+		queue.setFailed(pool.DroppedRanges())
+		r.saveLedger(queue)
+		if remaining := rangeSize(queue.pending(start, end)); remaining > 0 {
+			log.Info("durable queue: %d entries still pending, run again to continue (%s)", remaining, r.ledgerPath(logURL))
+		}
+	}
 
-	if err := <-fetchErr; err != nil {
+	err = <-fetchErr
+	if capped {
+		return errResultCap
+	}
+	if err != nil {
 		return err
 	}
 
@@ -267,17 +654,32 @@ func (r *Runner) scrapeLog(ctx context.Context, logURL string, parser *certparse
 	rate := float64(done) / elapsed.Seconds()
 	log.Success("completed %s: %d entries in %v (%.0f entries/sec)",
 		logURL, done, elapsed.Round(time.Second), rate)
-	if dropped := pool.DroppedEntries(); dropped > 0 {
+	if r.opts.TargetRate > 0 {
+		log.Info("target rate %d entries/sec, achieved %.0f (%.0f%%)",
+			r.opts.TargetRate, rate, rate/float64(r.opts.TargetRate)*100)
+	}
+	if dropped > 0 {
 		log.Warning("dropped %d entries due to fetch errors (%.1f%% of requested range)",
 			dropped, float64(dropped)/float64(totalEntries)*100)
 	}
 	log.Debug("fetch stats: %s", pool.ErrorInfo())
 
+	if r.sinceIndex != nil {
+		if dropped > 0 {
+			log.Warning("not updating %s for this log: %d entries were dropped", r.opts.SinceIndex, dropped)
+		} else if err := r.sinceIndex.record(logURL, end-1); err != nil {
+			log.Warning("saving %s: %v", r.opts.SinceIndex, err)
+		}
+	}
+
 	return nil
 }
 
 func (r *Runner) monitor(ctx context.Context) error {
-	domains := r.collectDomains()
+	domains, err := r.collectDomains()
+	if err != nil {
+		return err
+	}
 
 	logURLs, err := r.resolveLogURLs(ctx)
 	if err != nil {
@@ -286,46 +688,93 @@ func (r *Runner) monitor(ctx context.Context) error {
 	if len(logURLs) == 0 {
 		return fmt.Errorf("no CT logs to monitor - use -lu <url> to specify a log or omit to auto-discover")
 	}
+	return r.monitorLogs(ctx, domains, logURLs)
+}
 
-	writer, err := output.NewWriter(r.opts.Output, r.opts.JSON, r.opts.Fields)
+// monitorLogs polls logURLs for new entries until ctx is done, the monitor
+// goes idle, or after one poll with -poll-once.
+func (r *Runner) monitorLogs(ctx context.Context, domains, logURLs []string) error {
+	writer, err := r.newWriter()
 	if err != nil {
 		return err
 	}
 	defer writer.Close()
 
-	parser := certparser.New(domains)
+	parser, err := r.newParser(domains)
+	if err != nil {
+		return err
+	}
 
 	if len(domains) > 0 {
 		log.Info("monitoring for domains: %s", strings.Join(domains, ", "))
 	}
+	if r.opts.AlertOutput != "" {
+		if r.alert, err = r.openAlertOutput(); err != nil {
+			return fmt.Errorf("creating -alert-output: %w", err)
+		}
+		defer r.alert.close()
+		log.Info("writing matches for %s to %s", strings.Join(r.opts.AlertDomains, ", "), r.opts.AlertOutput)
+		if len(domains) > 0 {
+			for _, d := range unreachableAlertDomains(parser, r.opts.AlertDomains) {
+				log.Warning("-alert-domains %s is outside every -d filter, it can never match", d)
+			}
+		}
+	}
+
+	memCtx, stopMem := context.WithCancel(ctx)
+	defer stopMem()
+	go r.watchMemory(memCtx, writer)
 
 	pollInterval := time.Duration(r.opts.PollInterval) * time.Second
 
 	var treeMu sync.Mutex
 	lastTreeSize := make(map[string]int64)
-	timeout := time.Duration(r.opts.Timeout) * time.Second
 	clients := make(map[string]*ctlog.Client)
+	// shrunkTo holds the last smaller tree size reported per log, so a
+	// log stuck on a rolled-back tree is reported once, not every poll.
+	// It is cleared once the log is back at its old size.
+	shrunkTo := make(map[string]int64)
 
 	var initWg sync.WaitGroup
+	var stale atomic.Int64
 	for _, logURL := range logURLs {
 		initWg.Add(1)
 		go func(logURL string) {
 			defer initWg.Done()
-			client := ctlog.NewClient(logURL, timeout, r.opts.Retries)
+			client := r.newClient(logURL)
 			sth, err := client.GetSTH(ctx)
 			if err != nil {
+				r.warnLogError(logURL, err)
 				log.Warning("skipping %s: %v", logURL, err)
 				return
 			}
+			if age := sthAge(sth, time.Now()); r.opts.MaxLogAge > 0 && age > r.opts.MaxLogAge {
+				stale.Add(1)
+				log.Warning("skipping %s: last tree head is %v old, over -max-log-age %v", logURL, age.Round(time.Minute), r.opts.MaxLogAge)
+				return
+			}
+			start := sth.TreeSize
+			if r.opts.Resume {
+				if progress := r.loadProgress(logURL); progress != nil && !progress.Reverse {
+					start = progress.LastIndex + 1
+					log.Info("[%s] resuming from saved tree size %d (now %d)", truncate(logURL, 50), start, sth.TreeSize)
+				}
+			}
 			treeMu.Lock()
 			clients[logURL] = client
-			lastTreeSize[logURL] = sth.TreeSize
+			lastTreeSize[logURL] = start
 			treeMu.Unlock()
-			log.Debug("[%s] starting at tree size %d", truncate(logURL, 50), sth.TreeSize)
+			log.Debug("[%s] starting at tree size %d", truncate(logURL, 50), start)
 		}(logURL)
 	}
 	initWg.Wait()
 
+	if n := stale.Load(); n > 0 {
+		log.Info("skipped %d of %d log(s) with no tree head in the last %v", n, len(logURLs), r.opts.MaxLogAge)
+		if len(lastTreeSize) == 0 {
+			return fmt.Errorf("no CT log has signed a tree head within -max-log-age %v", r.opts.MaxLogAge)
+		}
+	}
 	if len(lastTreeSize) == 0 {
 		return fmt.Errorf("could not connect to any CT logs")
 	}
@@ -334,6 +783,20 @@ func (r *Runner) monitor(ctx context.Context) error {
 	defer ticker.Stop()
 
 	log.Info("connected to %d log(s), polling every %vs (Ctrl+C to stop)", len(lastTreeSize), r.opts.PollInterval)
+	r.progress.setIdle(func() string {
+		treeMu.Lock()
+		defer treeMu.Unlock()
+		return fmt.Sprintf("monitoring %d log(s) - %d unique results - %s", len(lastTreeSize), writer.Stats(), r.stats.snapshot())
+	})
+	defer r.progress.start(ctx)()
+
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+
+	phase := pollPhases(lastTreeSize, pollInterval)
+	if r.opts.PollOnce {
+		phase = nil
+	}
 
 	poll := func() {
 		if ctx.Err() != nil {
@@ -341,59 +804,148 @@ func (r *Runner) monitor(ctx context.Context) error {
 		}
 
 		var wg sync.WaitGroup
-		monitorSem := make(chan struct{}, r.opts.Workers)
+		monitorSem := make(chan struct{}, r.pollConcurrency())
 		treeMu.Lock()
 		snapshot := make(map[string]int64, len(lastTreeSize))
 		maps.Copy(snapshot, lastTreeSize)
 		treeMu.Unlock()
 		for logURL, prevSize := range snapshot {
 			wg.Add(1)
-			monitorSem <- struct{}{}
 			go func(logURL string, prevSize int64) {
-				defer func() { <-monitorSem }()
 				defer wg.Done()
+				select {
+				case <-time.After(phase[logURL]):
+				case <-ctx.Done():
+					return
+				}
+				monitorSem <- struct{}{}
+				defer func() { <-monitorSem }()
 
 				client := clients[logURL]
 				sth, err := client.GetSTH(ctx)
 				if err != nil {
+					r.stats.errors.Add(1)
 					log.Debug("poll error for %s: %v", logURL, err)
 					return
 				}
 
 				newSize := sth.TreeSize
-				if newSize <= prevSize {
+				if newSize < prevSize {
+					treeMu.Lock()
+					reported := shrunkTo[logURL] == newSize
+					shrunkTo[logURL] = newSize
+					treeMu.Unlock()
+					if !reported {
+						log.Error("[%s] tree size went down from %d to %d (STH timestamp %d, root hash %s). CT logs only grow: this points to a log rollback, a split view or a man-in-the-middle. Still tracking from %d",
+							logURL, prevSize, newSize, sth.Timestamp, sth.SHA256RootHash, prevSize)
+					}
+					return
+				}
+				treeMu.Lock()
+				delete(shrunkTo, logURL)
+				treeMu.Unlock()
+				if newSize == prevSize {
 					return
 				}
 
+				lastActivity.Store(time.Now().UnixNano())
 				delta := newSize - prevSize
 				log.Info("[%s] %d new entries (tree %d -> %d)",
 					truncate(logURL, 40), delta, prevSize, newSize)
 
 				r.fetchAndProcess(ctx, client, logURL, prevSize, newSize, parser, writer)
 				writer.Flush()
+				r.alert.flush()
+				if ctx.Err() != nil {
+					// Interrupted mid-fetch: keep the old size so the
+					// entries are fetched again after a -resume.
+					return
+				}
 				treeMu.Lock()
 				lastTreeSize[logURL] = newSize
 				treeMu.Unlock()
+				if r.opts.Resume {
+					r.saveProgress(logURL, newSize, newSize-1, delta)
+				}
 			}(logURL, prevSize)
 		}
 		wg.Wait()
 	}
 
+	// saveState records where each log stands for the next -resume. It
+	// runs between polls, so lastTreeSize is not being written.
+	saveState := func() {
+		if !r.opts.Resume && !r.opts.PollOnce {
+			return
+		}
+		for logURL, size := range lastTreeSize {
+			r.saveProgress(logURL, size, size-1, 0)
+		}
+	}
+	// finish saves state and prints the summary every way out of the
+	// monitor ends with.
+	finish := func(status string) {
+		saveState()
+		log.Success("%s - %d unique results written", status, writer.Stats())
+		reportDedup(writer)
+		log.Info("totals: %s", r.stats.snapshot())
+		r.reportParserSkips(parser)
+		r.reportHTTPDiagnostics()
+	}
+
 	poll()
+	if r.opts.PollOnce {
+		finish("poll finished")
+		return nil
+	}
 	for {
 		select {
 		case <-ctx.Done():
-			log.Success("monitor stopped - %d unique results written", writer.Stats())
+			finish("monitor stopped")
 			return nil
 		case <-ticker.C:
 			if err := ctx.Err(); err != nil {
 				return nil
 			}
 			poll()
+			if idle := r.opts.MonitorIdleTimeout; idle > 0 && time.Since(time.Unix(0, lastActivity.Load())) >= idle {
+				log.Info("no new entries for %v, stopping monitor", idle)
+				finish("monitor stopped")
+				return nil
+			}
 		}
 	}
 }
 
+// sthAge is how long before now the log signed sth. A log that keeps
+// serving an old tree head has stopped integrating new entries.
+func sthAge(sth *ctlog.STH, now time.Time) time.Duration {
+	return now.Sub(time.UnixMilli(sth.Timestamp))
+}
+
+// pollPhases gives each log a fixed random offset within the first half of
+// the poll interval, so polls to logs sharing a frontend are spread out
+// instead of arriving together on every tick. A single log polls at once.
+func pollPhases(logs map[string]int64, interval time.Duration) map[string]time.Duration {
+	phase := make(map[string]time.Duration, len(logs))
+	if len(logs) < 2 {
+		return phase
+	}
+	for logURL := range logs {
+		phase[logURL] = rand.N(interval / 2)
+	}
+	return phase
+}
+
+// pollConcurrency is how many logs a monitor poll checks at once,
+// independent of the -w workers each log then fetches new entries with.
+func (r *Runner) pollConcurrency() int {
+	if r.opts.PollConcurrency > 0 {
+		return r.opts.PollConcurrency
+	}
+	return r.opts.Workers
+}
+
 func (r *Runner) fetchAndProcess(
 	ctx context.Context,
 	client *ctlog.Client,
@@ -402,8 +954,7 @@ func (r *Runner) fetchAndProcess(
 	parser *certparser.Parser,
 	writer *output.Writer,
 ) {
-	pool := ctlog.NewWorkerPool(client, r.opts.BatchSize, r.opts.Workers, r.opts.RateLimit)
-	pool.SetDebugLog(log.Debug)
+	pool := r.newPool(client, r.opts.BatchSize, r.opts.Workers)
 	results := make(chan ctlog.EntryBatch, r.opts.Workers*2)
 
 	fetchErr := make(chan error, 1)
@@ -417,11 +968,260 @@ func (r *Runner) fetchAndProcess(
 		r.parseBatch(batch, parser, writer, logURL, parseSem, nil)
 	}
 
+	r.stats.dropped.Add(pool.DroppedEntries())
 	if err := <-fetchErr; err != nil {
 		log.Debug("fetch error for %s: %v", logURL, err)
 	}
 }
 
+func (r *Runner) newClient(logURL string) *ctlog.Client {
+	return r.newClientWith(logURL, time.Duration(r.opts.Timeout)*time.Second, r.opts.Retries)
+}
+
+func (r *Runner) newClientWith(logURL string, timeout time.Duration, retries int) *ctlog.Client {
+	client := ctlog.NewClient(logURL, timeout, retries)
+	if r.retryBudget != nil {
+		client.SetRetryBudget(r.retryBudget)
+	}
+	if r.opts.VerboseHTTP {
+		client.SetRequestLog(log.Debug)
+	}
+	if r.opts.minTLS != 0 {
+		client.SetMinTLSVersion(r.opts.minTLS)
+	}
+	if r.opts.NoKeepAlive {
+		client.DisableKeepAlives()
+	}
+	if r.opts.AuthToken != "" {
+		client.SetAuthToken(r.opts.AuthToken)
+	}
+	if r.opts.entriesStyle != nil {
+		client.SetEntriesStyle(*r.opts.entriesStyle)
+	}
+	if r.opts.LinkPages > 0 {
+		client.SetLinkPages(r.opts.LinkPages)
+	}
+	if r.limiter != nil {
+		client.SetRequestLimiter(r.limiter)
+	}
+	return client
+}
+
+func (r *Runner) newFetcher() *loglist.Fetcher {
+	fetcher := loglist.NewFetcher(time.Duration(r.opts.Timeout) * time.Second)
+	if r.opts.minTLS != 0 {
+		fetcher.SetMinTLSVersion(r.opts.minTLS)
+	}
+	return fetcher
+}
+
+// fetchLogList downloads the default log list and warns when its newest
+// timestamp suggests the data is out of date.
+func (r *Runner) fetchLogList(ctx context.Context) (*loglist.LogList, error) {
+	logList, err := r.newFetcher().FetchDefault(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching log list: %w", err)
+	}
+	if newest := logList.NewestTimestamp(); !newest.IsZero() && time.Since(newest) > loglist.MaxAge {
+		log.Warning("log list looks stale: newest timestamp is %s (%d days old), new logs may be missing and retired ones still listed",
+			newest.Format(time.DateOnly), int(time.Since(newest).Hours()/24))
+	}
+	return logList, nil
+}
+
+// warnLogError adds a hint for failures that point at the log or its URL
+// rather than at a transient network problem.
+func (r *Runner) warnLogError(logURL string, err error) {
+	switch {
+	case ctlog.IsTLSVersionError(err):
+		log.Warning("%s only supports TLS versions below -min-tls %s", logURL, r.opts.MinTLS)
+	case errors.Is(err, ctlog.ErrNonCompliant):
+		log.Warning("%s did not answer like an RFC 6962 log - check the URL or whether a proxy is in the way", logURL)
+	}
+}
+
+// retryDropped fetches the ranges the main pass gave up on once more, with
+// half the workers, and returns how many entries are still missing.
+func (r *Runner) retryDropped(ctx context.Context, client *ctlog.Client, ranges []ctlog.Range, batchSize, workers int, parse func(ctlog.EntryBatch)) int64 {
+	total := rangeSize(ranges)
+	workers = max(1, workers/2)
+	log.Info("retrying %d dropped entries in %d ranges with %d workers", total, len(ranges), workers)
+
+	pool := r.newPool(client, batchSize, workers)
+	results := make(chan ctlog.EntryBatch, workers*2)
+	go pool.FetchRanges(ctx, ranges, results)
+	for batch := range results {
+		parse(batch)
+	}
+
+	missing := pool.DroppedEntries()
+	log.Info("retry pass recovered %d of %d dropped entries", total-missing, total)
+	return missing
+}
+
+func (r *Runner) reportHTTPDiagnostics() {
+	if r.opts.NoKeepAlive {
+		log.Info("keep-alive was disabled (-disable-keepalive): every request used a new connection")
+	}
+}
+
+// reportDedup shows how much of the matched data was repeated, e.g. the
+// same domain in many certificates.
+func reportDedup(writer *output.Writer) {
+	emitted, offered := writer.DedupStats()
+	if offered == 0 {
+		return
+	}
+	log.Info("emitted %d unique of %d total values (%.1f%% dedup)",
+		emitted, offered, float64(offered-emitted)/float64(offered)*100)
+	if n := writer.CapSkipped(); n > 0 {
+		log.Info("skipped %d results over the -max-per-domain cap", n)
+	}
+}
+
+// reportMatchesByLog prints how many matches each log produced, most
+// first, so users can see which logs carry their domains.
+func (r *Runner) reportMatchesByLog(scraped int) {
+	counts := r.stats.matchesByLog()
+	if len(counts) == 0 {
+		return
+	}
+	log.Info("matches by log (%d of %d logs had matches):", len(counts), scraped)
+	for _, c := range counts {
+		log.Info("  %10d  %s", c.matched, c.log)
+	}
+}
+
+func (r *Runner) reportParserSkips(parser *certparser.Parser) {
+	if n := parser.SkippedByNotBefore(); n > 0 {
+		log.Info("skipped %d certificates issued before %s", n, r.opts.MinNotBefore)
+	}
+	if n := parser.SkippedByTimestamp(); n > 0 {
+		log.Warning("skipped %d entries with implausible leaf timestamps (before 2013 or in the future)", n)
+	}
+}
+
+// loadPSL installs the -psl-file list used for registered_domain. If it
+// can't be read, the embedded list stays in use.
+func (r *Runner) loadPSL() {
+	if r.opts.PSLFile != "" {
+		list, err := psl.Load(r.opts.PSLFile)
+		if err == nil {
+			psl.SetDefault(list)
+			log.Debug("loaded %d public suffix rules from %s", list.Len(), r.opts.PSLFile)
+			return
+		}
+		log.Warning("could not load -psl-file %s: %v; using the embedded list", r.opts.PSLFile, err)
+	}
+	if psl.Default().Len() == 0 && (r.opts.JSON || r.opts.Proto || r.opts.Exec != "") {
+		log.Warning("no public suffix list available: registered_domain falls back to the last two labels of each name, which is wrong for suffixes like co.uk")
+	}
+}
+
+func (r *Runner) newWriter() (*output.Writer, error) {
+	return output.NewWriterWithOptions(r.opts.Output, r.opts.JSON, r.opts.Fields, r.writerOptions())
+}
+
+func (r *Runner) writerOptions() output.Options {
+	return output.Options{
+		RollupV4Bits:  r.opts.rollupV4Bits,
+		RollupV6Bits:  r.opts.rollupV6Bits,
+		Timestamps:    r.opts.Monitor && r.opts.MonitorTimestamps,
+		FlushInterval: r.flushInterval(),
+		Template:      r.opts.template,
+		LinkPrecerts:  r.opts.LinkPrecerts,
+		Table:         r.opts.Table && stdoutIsTerminal(),
+		GroupByCert:   r.opts.GroupBy == "cert",
+		Append:        r.opts.ResumeDedup,
+		Sep:           r.opts.Sep,
+		FoldCase:      r.opts.CIDedup,
+		MaxPerDomain:  r.opts.MaxPerDomain,
+		ExportSeen:    r.opts.ExportSeen,
+		SeedDedup:     r.opts.SeedDedup,
+		Proto:         r.opts.Proto,
+		BufferSize:    r.opts.OutputBuffer,
+	}
+}
+
+// flushInterval is zero in monitor mode: monitor output feeds live pipelines,
+// so it keeps flushing after every poll instead of on a timer.
+func (r *Runner) flushInterval() time.Duration {
+	if r.opts.Monitor {
+		return 0
+	}
+	return r.opts.FlushInterval
+}
+
+func (r *Runner) newParser(domains []string) (*certparser.Parser, error) {
+	issuers, err := collectPatterns(r.opts.Issuer, r.opts.IssuerFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading issuer file: %w", err)
+	}
+	denyIssuers, err := collectPatterns(nil, r.opts.DenyIssuerFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading deny issuer file: %w", err)
+	}
+
+	return certparser.NewWithOptions(domains, certparser.Options{
+		CAOnly:       r.opts.CAOnly,
+		ExactMatch:   r.opts.ExactMatch,
+		EKU:          r.opts.EKU,
+		Issuers:      issuers,
+		DenyIssuers:  denyIssuers,
+		LinkPrecerts: r.opts.LinkPrecerts,
+
+		ExcludeExpiredAtLog: r.opts.ExcludeExpiredAtLog,
+		SubdomainsOnly:      r.opts.SubdomainsOnly,
+		BrandMatch:          r.opts.BrandMatch,
+		MinNotBefore:        r.opts.minNotBefore,
+		IncludeRaw:          r.opts.IncludeRaw,
+		ShowMatch:           r.opts.ShowMatch,
+		StrictTimestamps:    r.opts.StrictTimestamps,
+		ReportCertErrors:    r.opts.LogParseErrors,
+		ValidationLevels:    r.opts.ValidationLevel,
+		Serials:             r.opts.Serial,
+		SkipX509:            r.opts.EntryType == "precert",
+		SkipPrecerts:        r.opts.EntryType == "x509",
+	}), nil
+}
+
+// collectPatterns merges flag values with the lines of an optional file.
+func collectPatterns(values []string, path string) ([]string, error) {
+	patterns := append([]string(nil), values...)
+	if path == "" {
+		return patterns, nil
+	}
+	lines, err := readLinesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(patterns, lines...), nil
+}
+
+func (r *Runner) newPool(client *ctlog.Client, batchSize, workers int) *ctlog.WorkerPool {
+	pool := ctlog.NewWorkerPool(client, batchSize, workers, r.opts.RateLimit)
+	pool.SetDebugLog(log.Debug)
+	pool.SetPauseGate(r.pause)
+	if r.limiter != nil {
+		pool.SetRequestLimiter(r.limiter)
+	}
+	pool.SetInitialWorkers(r.opts.RampStart)
+	pool.SetRampInterval(r.opts.RampInterval)
+	pool.SetReverse(r.opts.Reverse)
+	pool.SetTargetRate(r.opts.TargetRate)
+	pool.SetStallTimeout(r.stallTimeout(), log.Warning)
+	r.trace.attach(pool, client.URL())
+	return pool
+}
+
+// stallFactor is how many times the worst-case request time (every retry
+// hitting the timeout) a batch may take before it is treated as stuck.
+const stallFactor = 3
+
+func (r *Runner) stallTimeout() time.Duration {
+	return stallFactor * time.Duration(r.opts.Retries+1) * time.Duration(r.opts.Timeout) * time.Second
+}
+
 func (r *Runner) newParseSem() chan struct{} {
 	n := r.opts.ParseWorkers
 	if n <= 0 {
@@ -430,7 +1230,8 @@ func (r *Runner) newParseSem() chan struct{} {
 	return make(chan struct{}, n)
 }
 
-func (r *Runner) parseBatch(batch ctlog.EntryBatch, parser *certparser.Parser, writer *output.Writer, logURL string, parseSem chan struct{}, counter *atomic.Int64) {
+func (r *Runner) parseBatch(batch ctlog.EntryBatch, parser *certparser.Parser, writer resultSink, logURL string, parseSem chan struct{}, counter *atomic.Int64) {
+	r.stats.fetched.Add(int64(len(batch.Entries)))
 	var wg sync.WaitGroup
 	for i, entry := range batch.Entries {
 		wg.Add(1)
@@ -443,47 +1244,80 @@ func (r *Runner) parseBatch(batch ctlog.EntryBatch, parser *certparser.Parser, w
 			}
 			result, err := parser.ParseEntry(e, idx, logURL)
 			if err != nil {
+				r.stats.errors.Add(1)
+				var certErr *certparser.CertError
+				if errors.As(err, &certErr) {
+					log.Info("unparseable certificate at %s entry %d: %v", logURL, idx, certErr.Err)
+					return
+				}
 				log.Debug("parse error at entry %d: %v", idx, err)
 				return
 			}
-			if result != nil {
-				writer.WriteResult(result)
+			r.stats.parsed.Add(1)
+			if result == nil {
+				return
+			}
+			r.stats.addMatch(logURL)
+			r.archive.save(logURL, idx, e)
+			r.hook.send(result)
+			if r.opts.CountMatches {
+				return
 			}
+			writer.WriteResult(result)
+			r.alert.send(result)
 		}(entry, batch.StartIndex+int64(i))
 	}
 	wg.Wait()
 }
 
-func (r *Runner) collectDomains() []string {
+// collectDomains merges -d, -df and stdin. An unreadable -df is an error:
+// carrying on without it would scrape with no domain filter at all.
+func (r *Runner) collectDomains() ([]string, error) {
 	var domains []string
 	domains = append(domains, r.opts.Domain...)
 
 	if r.opts.DomainFile != "" {
-		fileDomains, err := readLinesFromFile(r.opts.DomainFile)
+		fileDomains, err := readDomainFiles(r.opts.DomainFile)
 		if err != nil {
-			log.Warning("reading domain file: %v", err)
-		} else {
-			domains = append(domains, fileDomains...)
+			return nil, fmt.Errorf("reading domain file: %w", err)
+		}
+		if len(fileDomains) == 0 {
+			return nil, fmt.Errorf("domain file %s holds no domains", r.opts.DomainFile)
 		}
+		domains = append(domains, fileDomains...)
 	}
 
-	if hasStdin() {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" {
-				domains = append(domains, line)
-			}
-		}
+	if r.opts.StdinMode != "logs" && hasStdin() {
+		domains = append(domains, readStdinLines()...)
+	}
+
+	return domains, nil
+}
+
+func (r *Runner) collectLogURLs() []string {
+	urls := append([]string(nil), r.opts.LogURL...)
+	if r.opts.StdinMode == "logs" && hasStdin() {
+		urls = append(urls, readStdinLines()...)
 	}
+	return urls
+}
 
-	return domains
+func readStdinLines() []string {
+	var lines []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
 }
 
 func (r *Runner) resolveLogURLs(ctx context.Context) ([]string, error) {
-	if len(r.opts.LogURL) > 0 {
-		urls := make([]string, len(r.opts.LogURL))
-		for i, u := range r.opts.LogURL {
+	if logURLs := r.collectLogURLs(); len(logURLs) > 0 {
+		urls := make([]string, len(logURLs))
+		for i, u := range logURLs {
 			switch {
 			case strings.HasPrefix(u, "https://"):
 				//
@@ -495,32 +1329,91 @@ func (r *Runner) resolveLogURLs(ctx context.Context) ([]string, error) {
 			}
 			urls[i] = u
 		}
-		return urls, nil
+		return dedupLogURLs(urls), nil
 	}
 
 	log.Info("auto-discovering CT logs...")
 
-	timeout := time.Duration(r.opts.Timeout) * time.Second
-	fetcher := loglist.NewFetcher(timeout)
-
-	logList, err := fetcher.FetchDefault(ctx)
+	logList, err := r.fetchLogList(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("fetching log list: %w", err)
+		return nil, err
 	}
 
-	logs := loglist.FilterLogs(logList, r.opts.LogState)
+	logs := r.filterLogs(logList)
 	if len(logs) == 0 {
-		return nil, fmt.Errorf("no logs found matching state filter '%s'", r.opts.LogState)
+		return nil, fmt.Errorf("no logs found matching %s", r.logFilterDesc())
 	}
 
-	log.Info("found %d %s CT logs", len(logs), r.opts.LogState)
+	if r.opts.UsableAt != "" {
+		log.Info("found %d CT logs usable at %s", len(logs), r.opts.UsableAt)
+	} else {
+		log.Info("found %d %s CT logs", len(logs), r.opts.LogState)
+	}
+	if r.opts.Monitor {
+		if logs = liveLogs(logs); len(logs) == 0 {
+			return nil, fmt.Errorf("all selected logs are readonly or retired and will never grow - monitor usable logs (-log-state usable) or scrape these without -m")
+		}
+	}
 
 	urls := make([]string, len(logs))
 	for i, l := range logs {
 		urls[i] = l.Log.FullURL()
 	}
 
-	return urls, nil
+	return dedupLogURLs(urls), nil
+}
+
+// liveLogs drops read-only and retired logs, which a monitor would poll
+// forever without seeing a new entry.
+func liveLogs(logs []loglist.LogWithOperator) []loglist.LogWithOperator {
+	var live []loglist.LogWithOperator
+	for _, l := range logs {
+		if !l.Log.Frozen() {
+			live = append(live, l)
+		}
+	}
+	if frozen := len(logs) - len(live); frozen > 0 && len(live) > 0 {
+		log.Warning("monitor: skipping %d readonly/retired log(s) that can no longer grow", frozen)
+	}
+	return live
+}
+
+// dedupLogURLs drops repeated logs, treating URLs that differ only in host
+// case or a trailing slash as the same. The first spelling is kept so
+// resume state keyed on it still applies.
+func dedupLogURLs(urls []string) []string {
+	seen := make(map[string]struct{}, len(urls))
+	out := urls[:0]
+	for _, u := range urls {
+		key := strings.TrimSuffix(u, "/")
+		if scheme, rest, ok := strings.Cut(key, "://"); ok {
+			host, path, _ := strings.Cut(rest, "/")
+			key = strings.ToLower(scheme) + "://" + strings.ToLower(host) + "/" + path
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, u)
+	}
+	if removed := len(urls) - len(out); removed > 0 {
+		log.Info("ignoring %d duplicate log URL(s)", removed)
+	}
+	return out
+}
+
+func (r *Runner) filterLogs(logList *loglist.LogList) []loglist.LogWithOperator {
+	if r.opts.UsableAt != "" {
+		return loglist.FilterLogsUsableAt(logList, r.opts.usableAt)
+	}
+	return loglist.FilterLogs(logList, r.opts.LogState)
+}
+
+func (r *Runner) logFilterDesc() string {
+	if r.opts.UsableAt != "" {
+		return fmt.Sprintf("usable-at %s", r.opts.UsableAt)
+	}
+	return fmt.Sprintf("state filter '%s'", r.opts.LogState)
 }
 
 func (r *Runner) calculateRange(treeSize int64) (start, end int64) {
@@ -546,6 +1439,53 @@ func (r *Runner) calculateRange(treeSize int64) (start, end int64) {
 	return start, end
 }
 
+// resumeRange narrows [start, end) using saved progress. Forward scrapes
+// continue after the saved index; reverse scrapes continue below it. With
+// -resume-overlap the range reaches back over the last N saved entries so
+// any that were dropped get another chance.
+func (r *Runner) resumeRange(logURL string, start, end int64) (int64, int64) {
+	progress := r.loadProgress(logURL)
+	if progress == nil {
+		log.Info("resume: no saved state found for this log, starting fresh")
+		return start, end
+	}
+	if progress.Reverse != r.opts.Reverse {
+		log.Warning("resume: saved state for this log was written in %s order, starting fresh", scrapeOrder(progress.Reverse))
+		return start, end
+	}
+	if r.opts.ResumeAuto > 0 {
+		age := time.Since(progress.LastUpdated).Round(time.Second)
+		if age > r.opts.ResumeAuto {
+			log.Info("resume: saved state is %s old, more than -resume-auto %s, starting fresh", age, r.opts.ResumeAuto)
+			return start, end
+		}
+		log.Info("resume: saved state is %s old, within -resume-auto %s", age, r.opts.ResumeAuto)
+	}
+
+	overlap := r.opts.ResumeOverlap
+	if r.opts.Reverse {
+		if progress.LastIndex < end && progress.LastIndex >= start {
+			end = min(progress.LastIndex+overlap, end)
+			log.Info("resuming in reverse below entry %d (%d entries remaining)", end, max(end-start, 0))
+			return start, end
+		}
+	} else if progress.LastIndex > start {
+		start = max(progress.LastIndex+1-overlap, start)
+		log.Info("resuming from entry %d (%d entries remaining)", start, max(end-start, 0))
+		return start, end
+	}
+
+	log.Info("resume: saved state is outside the requested range, starting fresh")
+	return start, end
+}
+
+func scrapeOrder(reverse bool) string {
+	if reverse {
+		return "reverse"
+	}
+	return "forward"
+}
+
 func (r *Runner) loadProgress(logURL string) *ctlog.ScrapeProgress {
 	path := r.stateFilePath(logURL)
 	data, err := os.ReadFile(path)
@@ -567,6 +1507,7 @@ func (r *Runner) saveProgress(logURL string, treeSize, lastIndex, entriesDone in
 		LastIndex:   lastIndex,
 		EntriesDone: entriesDone,
 		LastUpdated: time.Now(),
+		Reverse:     r.opts.Reverse,
 	}
 
 	data, err := json.Marshal(progress)
@@ -587,13 +1528,21 @@ func (r *Runner) saveProgress(logURL string, treeSize, lastIndex, entriesDone in
 }
 
 func (r *Runner) stateFilePath(logURL string) string {
-	safe := strings.NewReplacer(
+	return r.statePath(logURL, ".state.json")
+}
+
+func (r *Runner) statePath(logURL, suffix string) string {
+	return filepath.Join(r.opts.StateDir, safeLogName(logURL)+suffix)
+}
+
+// safeLogName turns a log URL into a file name component.
+func safeLogName(logURL string) string {
+	return strings.NewReplacer(
 		"https://", "",
 		"http://", "",
 		"/", "_",
 		":", "_",
 	).Replace(logURL)
-	return filepath.Join(r.opts.StateDir, safe+".state.json")
 }
 
 func readLinesFromFile(path string) ([]string, error) {
@@ -614,6 +1563,74 @@ func readLinesFromFile(path string) ([]string, error) {
 	return lines, scanner.Err()
 }
 
+// readDomainFiles reads every file matching pattern. Lines of the form
+// "@include <pattern>" pull in further files, resolved relative to the
+// including file. An include that can't be read, matches nothing or
+// closes a cycle is skipped with a warning; the rest of the list is kept.
+func readDomainFiles(pattern string) ([]string, error) {
+	return readDomainGlob(pattern, nil)
+}
+
+func readDomainGlob(pattern string, stack []string) ([]string, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		// Keep the open error for a plain path that doesn't exist.
+		paths = []string{pattern}
+	}
+
+	var domains []string
+	for _, path := range paths {
+		lines, err := readDomainFile(path, stack)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, lines...)
+	}
+	return domains, nil
+}
+
+func readDomainFile(path string, stack []string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+	}
+	stack = append(stack, abs)
+
+	lines, err := readLinesFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, line := range lines {
+		inc, ok := strings.CutPrefix(line, "@include")
+		if !ok {
+			domains = append(domains, line)
+			continue
+		}
+		inc = strings.TrimSpace(inc)
+		if inc == "" {
+			return nil, fmt.Errorf("%s: @include without a path", path)
+		}
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(abs), inc)
+		}
+		included, err := readDomainGlob(inc, stack)
+		if err != nil {
+			log.Warning("%s: skipping @include %s: %v", path, inc, err)
+			continue
+		}
+		domains = append(domains, included...)
+	}
+	return domains, nil
+}
+
 func hasStdin() bool {
 	stat, err := os.Stdin.Stat()
 	if err != nil {
@@ -622,6 +1639,14 @@ func hasStdin() bool {
 	return (stat.Mode() & os.ModeCharDevice) == 0
 }
 
+func stdoutIsTerminal() bool {
+	stat, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -1,12 +1,36 @@
 package runner
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/TheArqsz/ct-hulhu/internal/certparser"
 	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+	"github.com/TheArqsz/ct-hulhu/internal/loglist"
+	"github.com/TheArqsz/ct-hulhu/internal/output"
 )
 
 func TestCalculateRange(t *testing.T) {
@@ -218,9 +242,9 @@ func TestSaveProgress_CreatesDir(t *testing.T) {
 func TestCollectDomains_FromOptions(t *testing.T) {
 	configureLogger(true, false, true)
 	r := &Runner{opts: &Options{Domain: stringSlice{"example.com", "other.com"}}}
-	domains := r.collectDomains()
-	if len(domains) != 2 {
-		t.Fatalf("expected 2 domains, got %d", len(domains))
+	domains, err := r.collectDomains()
+	if err != nil || len(domains) != 2 {
+		t.Fatalf("expected 2 domains, got %v (%v)", domains, err)
 	}
 }
 
@@ -234,9 +258,23 @@ func TestCollectDomains_FromFile(t *testing.T) {
 		Domain:     stringSlice{"cli-domain.com"},
 		DomainFile: path,
 	}}
-	domains := r.collectDomains()
-	if len(domains) != 2 {
-		t.Fatalf("expected 2 domains, got %d: %v", len(domains), domains)
+	domains, err := r.collectDomains()
+	if err != nil || len(domains) != 2 {
+		t.Fatalf("expected 2 domains, got %v (%v)", domains, err)
+	}
+}
+
+func TestCollectDomains_BadFile(t *testing.T) {
+	configureLogger(true, false, true)
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.txt")
+	os.WriteFile(empty, []byte("# nothing yet\n@include missing/*.txt\n"), 0o644)
+
+	for _, path := range []string{filepath.Join(dir, "missing.txt"), empty} {
+		r := &Runner{opts: &Options{Domain: stringSlice{"cli-domain.com"}, DomainFile: path}}
+		if domains, err := r.collectDomains(); err == nil {
+			t.Errorf("collectDomains() with -df %s = %v, want an error rather than a partial filter", filepath.Base(path), domains)
+		}
 	}
 }
 
@@ -288,3 +326,1360 @@ func TestGetVersion_StripsVPrefix(t *testing.T) {
 		t.Error("getVersion() should never return empty string")
 	}
 }
+
+func TestBetterTrial(t *testing.T) {
+	best := tuneResult{rate: 1000}
+	if !betterTrial(tuneResult{rate: 2000}, best) {
+		t.Error("expected faster trial to win")
+	}
+	if betterTrial(tuneResult{rate: 500}, best) {
+		t.Error("expected slower trial to lose")
+	}
+	if betterTrial(tuneResult{rate: 5000, errorRate: 0.5}, best) {
+		t.Error("expected trial with high error rate to be rejected")
+	}
+}
+
+func TestTuneTrial_AdvancesWindow(t *testing.T) {
+	entries := testEntries(t, 40)
+	var mu sync.Mutex
+	var starts []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		mu.Lock()
+		starts = append(starts, start)
+		mu.Unlock()
+		if start >= 20 {
+			// Stall the second half so the first trial runs out of time.
+			<-r.Context().Done()
+			return
+		}
+		serveTestEntries(w, r, entries)
+	}))
+	defer srv.Close()
+
+	opts := testScrapeOptions(t)
+	opts.Retries = 0
+	r := New(opts)
+	client := r.newClient(srv.URL)
+
+	_, next := r.tuneTrial(context.Background(), client, 0, 0, 40, 1, 10, 200*time.Millisecond)
+	if next != 20 {
+		t.Fatalf("first trial stopped at %d, want 20", next)
+	}
+	mu.Lock()
+	starts = nil
+	mu.Unlock()
+	if _, next = r.tuneTrial(context.Background(), client, next, 0, 40, 1, 10, 200*time.Millisecond); next != 20 {
+		t.Errorf("second trial stopped at %d, want 20", next)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) == 0 || starts[0] != 20 {
+		t.Errorf("second trial requested %v, want it to start at index 20", starts)
+	}
+}
+
+func TestTuneTrial_SlowLog(t *testing.T) {
+	entries := testEntries(t, 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		serveTestEntries(w, r, entries)
+	}))
+	defer srv.Close()
+
+	opts := testScrapeOptions(t)
+	opts.MaxTotalRetries = 5
+	r := New(opts)
+
+	res, _ := r.tuneTrial(context.Background(), r.tuneClient(srv.URL), 0, 0, 200, 4, 10, 500*time.Millisecond)
+	if res.errorRate != 0 || res.rate == 0 {
+		t.Errorf("trial on a slow, healthy log = %.0f entries/sec with %.1f%% errors, want a rate and no errors", res.rate, res.errorRate*100)
+	}
+	if !betterTrial(res, tuneResult{}) {
+		t.Error("trial on a slow, healthy log was rejected")
+	}
+	if n := r.retryBudget.Used(); n != 0 {
+		t.Errorf("calibration took %d retries from the run's budget, want 0", n)
+	}
+}
+
+func TestCollectPatterns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "issuers.txt")
+	os.WriteFile(path, []byte("# approved\nDigiCert\nLet's Encrypt\n"), 0o644)
+
+	got, err := collectPatterns([]string{"Sectigo"}, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 3 || got[0] != "Sectigo" || got[2] != "Let's Encrypt" {
+		t.Errorf("collectPatterns() = %v", got)
+	}
+
+	if _, err := collectPatterns(nil, filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestParseRollupCIDR(t *testing.T) {
+	tests := []struct {
+		in      string
+		v4, v6  int
+		wantErr bool
+	}{
+		{"/24", 24, 64, false},
+		{"16", 16, 64, false},
+		{"/24,/48", 24, 48, false},
+		{"/33", 0, 0, true},
+		{"/24,/129", 0, 0, true},
+		{"abc", 0, 0, true},
+		{"/24,/64,/8", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		v4, v6, err := parseRollupCIDR(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRollupCIDR(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (v4 != tt.v4 || v6 != tt.v6) {
+			t.Errorf("parseRollupCIDR(%q) = (%d, %d), want (%d, %d)", tt.in, v4, v6, tt.v4, tt.v6)
+		}
+	}
+}
+
+// newTestLog serves a fake CT log with n x509 entries, entry i carrying the
+// domain host<i>.example.com.
+func newTestLog(t *testing.T, n int) *httptest.Server {
+	t.Helper()
+	entries := testEntries(t, n)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			fmt.Fprintf(w, `{"tree_size":%d,"timestamp":%d}`, n, time.Now().UnixMilli())
+		case "/ct/v1/get-entries":
+			serveTestEntries(w, r, entries)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testEntries builds n log entries, each a self-signed certificate for
+// host<index>.example.com.
+func testEntries(t *testing.T, n int) []ctlog.RawEntry {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make([]ctlog.RawEntry, n)
+	for i := range entries {
+		name := fmt.Sprintf("host%d.example.com", i)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 1)),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf := make([]byte, 12, 15+len(der))
+		binary.BigEndian.PutUint64(leaf[2:10], uint64(time.Now().UnixMilli()))
+		leaf = append(leaf, byte(len(der)>>16), byte(len(der)>>8), byte(len(der)))
+		leaf = append(leaf, der...)
+		entries[i] = ctlog.RawEntry{LeafInput: base64.StdEncoding.EncodeToString(leaf)}
+	}
+	return entries
+}
+
+func serveTestEntries(w http.ResponseWriter, r *http.Request, entries []ctlog.RawEntry) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	end, _ := strconv.Atoi(r.URL.Query().Get("end"))
+	end = min(end, len(entries)-1)
+	json.NewEncoder(w).Encode(ctlog.GetEntriesResponse{Entries: entries[start : end+1]})
+}
+
+// newSTHLog serves the tree heads in sths, one per get-sth request, and
+// calls done on the request after the last one.
+func newSTHLog(t *testing.T, entries []ctlog.RawEntry, sths []ctlog.STH, done func()) *httptest.Server {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			i := int(calls.Add(1)) - 1
+			if i >= len(sths) {
+				done()
+				i = len(sths) - 1
+			}
+			json.NewEncoder(w).Encode(sths[i])
+		case "/ct/v1/get-entries":
+			serveTestEntries(w, r, entries)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// captureStderr runs fn with os.Stderr redirected and returns what it
+// wrote there.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stderr := os.Stderr
+	os.Stderr = f
+	fn()
+	os.Stderr = stderr
+	data, _ := os.ReadFile(f.Name())
+	return string(data)
+}
+
+func testScrapeOptions(t *testing.T) *Options {
+	t.Helper()
+	configureLogger(true, false, true)
+	return &Options{
+		Start:        -1,
+		Workers:      1,
+		BatchSize:    10,
+		Timeout:      5,
+		RampStart:    1,
+		RampInterval: 500 * time.Millisecond,
+		Output:       filepath.Join(t.TempDir(), "out.txt"),
+		Fields:       "domains",
+		StateDir:     t.TempDir(),
+	}
+}
+
+func TestScrapeLog_MaxResults(t *testing.T) {
+	srv := newTestLog(t, 50)
+	opts := testScrapeOptions(t)
+	opts.MaxResults = 5
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.scrapeLog(context.Background(), srv.URL, parser, writer)
+	if !errors.Is(err, errResultCap) {
+		t.Fatalf("scrapeLog() error = %v, want errResultCap", err)
+	}
+	if got := writer.Stats(); got < 5 || got >= 50 {
+		t.Errorf("Stats() = %d, want at least 5 and fewer than all 50 entries", got)
+	}
+}
+
+func TestResumeRange(t *testing.T) {
+	configureLogger(true, false, true)
+	logURL := "https://ct.example.com/log/"
+
+	tests := []struct {
+		name        string
+		saveReverse bool
+		saveIdx     int64
+		reverse     bool
+		overlap     int64
+		wantStart   int64
+		wantEnd     int64
+	}{
+		{"forward continues after saved index", false, 499, false, 0, 500, 1000},
+		{"reverse continues below saved index", true, 600, true, 0, 0, 600},
+		{"order mismatch starts fresh", false, 499, true, 0, 0, 1000},
+		{"reverse saved index outside range", true, 5000, true, 0, 0, 1000},
+		{"forward overlap", false, 499, false, 100, 400, 1000},
+		{"forward overlap clamped to start", false, 49, false, 100, 0, 1000},
+		{"reverse overlap", true, 600, true, 100, 0, 700},
+		{"reverse overlap clamped to end", true, 950, true, 100, 0, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			saver := &Runner{opts: &Options{StateDir: dir, Reverse: tt.saveReverse}}
+			saver.saveProgress(logURL, 1000, tt.saveIdx, 1)
+
+			r := &Runner{opts: &Options{StateDir: dir, Reverse: tt.reverse, ResumeOverlap: tt.overlap}}
+			start, end := r.resumeRange(logURL, 0, 1000)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("resumeRange() = (%d, %d), want (%d, %d)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestResumeRange_Auto(t *testing.T) {
+	configureLogger(true, false, true)
+	logURL := "https://ct.example.com/log/"
+
+	tests := []struct {
+		name      string
+		age       time.Duration
+		wantStart int64
+	}{
+		{"recent state resumes", time.Hour, 500},
+		{"stale state starts fresh", 48 * time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := &Runner{opts: &Options{StateDir: dir, ResumeAuto: 24 * time.Hour}}
+			data, _ := json.Marshal(ctlog.ScrapeProgress{LogURL: logURL, TreeSize: 1000, LastIndex: 499, LastUpdated: time.Now().Add(-tt.age)})
+			os.WriteFile(r.stateFilePath(logURL), data, 0o600)
+
+			if start, _ := r.resumeRange(logURL, 0, 1000); start != tt.wantStart {
+				t.Errorf("resumeRange() start = %d, want %d", start, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestReadDomainFiles_Include(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "units"), 0o755)
+	os.WriteFile(filepath.Join(dir, "main.txt"), []byte("# all units\nroot.com\n@include units/*.txt\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "units", "a.txt"), []byte("a.com\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "units", "b.txt"), []byte("b.com\n@include ../shared.txt\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "shared.txt"), []byte("shared.com\n"), 0o644)
+
+	got, err := readDomainFiles(filepath.Join(dir, "main.txt"))
+	if err != nil {
+		t.Fatalf("readDomainFiles() error: %v", err)
+	}
+	want := []string{"root.com", "a.com", "b.com", "shared.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("readDomainFiles() = %v, want %v", got, want)
+	}
+
+	got, err = readDomainFiles(filepath.Join(dir, "units", "*.txt"))
+	if err != nil || len(got) != 3 {
+		t.Errorf("readDomainFiles(glob) = %v, %v", got, err)
+	}
+}
+
+func TestReadDomainFiles_Cycle(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a.com\n@include b.txt\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b.com\n@include a.txt\n"), 0o644)
+
+	configureLogger(true, false, true)
+	os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c.com\n@include nothing-*.txt\n"), 0o644)
+
+	got, err := readDomainFiles(filepath.Join(dir, "a.txt"))
+	if err != nil || !slices.Equal(got, []string{"a.com", "b.com"}) {
+		t.Errorf("readDomainFiles() with a cycle = %v, %v, want the cyclic include skipped", got, err)
+	}
+	got, err = readDomainFiles(filepath.Join(dir, "c.txt"))
+	if err != nil || !slices.Equal(got, []string{"c.com"}) {
+		t.Errorf("readDomainFiles() with an empty include = %v, %v, want it skipped", got, err)
+	}
+}
+
+func TestScrapeLog_CountMatches(t *testing.T) {
+	srv := newTestLog(t, 40)
+	opts := testScrapeOptions(t)
+	opts.CountMatches = true
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser([]string{"example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatalf("scrapeLog() error: %v", err)
+	}
+	if got := r.stats.matched.Load(); got != 40 {
+		t.Errorf("matches = %d, want 40", got)
+	}
+	if got := writer.Stats(); got != 0 {
+		t.Errorf("writer got %d results, want 0 in count mode", got)
+	}
+}
+
+func TestRetryDropped(t *testing.T) {
+	configureLogger(true, false, true)
+	srv := newTestLog(t, 40)
+	opts := testScrapeOptions(t)
+	r := New(opts)
+
+	var got atomic.Int64
+	missing := r.retryDropped(context.Background(), r.newClient(srv.URL), []ctlog.Range{{Start: 5, End: 9}, {Start: 20, End: 29}}, 4, 2, func(batch ctlog.EntryBatch) {
+		got.Add(int64(len(batch.Entries)))
+	})
+	if missing != 0 {
+		t.Errorf("retryDropped() left %d entries missing, want 0", missing)
+	}
+	if got.Load() != 15 {
+		t.Errorf("retryDropped() parsed %d entries, want 15", got.Load())
+	}
+}
+
+func TestCollectLogURLs_Stdin(t *testing.T) {
+	configureLogger(true, false, true)
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wr.WriteString("ct.example.com/log1/\n\nhttps://ct.example.com/log2/\n")
+	wr.Close()
+	stdin := os.Stdin
+	os.Stdin = rd
+	defer func() { os.Stdin = stdin }()
+
+	r := &Runner{opts: &Options{LogURL: stringSlice{"ct.example.com/cli/"}, StdinMode: "logs"}}
+	if domains, _ := r.collectDomains(); len(domains) != 0 {
+		t.Errorf("collectDomains() = %v, want stdin left for logs", domains)
+	}
+	urls, err := r.resolveLogURLs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://ct.example.com/cli/", "https://ct.example.com/log1/", "https://ct.example.com/log2/"}
+	if !slices.Equal(urls, want) {
+		t.Errorf("resolveLogURLs() = %v, want %v", urls, want)
+	}
+}
+
+func TestWriteSTH(t *testing.T) {
+	sth := &ctlog.STH{
+		TreeSize:          1234,
+		Timestamp:         1700000000123,
+		SHA256RootHash:    "cm9vdA==",
+		TreeHeadSignature: "c2ln",
+	}
+
+	var buf strings.Builder
+	r := &Runner{opts: &Options{}}
+	if err := r.writeSTH(&buf, "https://ct.example.com/log/", sth); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tree size: 1234", "timestamp: 2023-11-14T22:13:20.123Z", "root hash: cm9vdA==", "signature: c2ln"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	r.opts.JSON = true
+	if err := r.writeSTH(&buf, "https://ct.example.com/log/", sth); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if got["tree_size"] != float64(1234) || got["sha256_root_hash"] != "cm9vdA==" || got["url"] != "https://ct.example.com/log/" {
+		t.Errorf("unexpected JSON: %v", got)
+	}
+}
+
+func TestDedupLogURLs(t *testing.T) {
+	configureLogger(true, false, true)
+	got := dedupLogURLs([]string{
+		"https://ct.example.com/log",
+		"https://ct.example.com/log/",
+		"https://CT.Example.com/log",
+		"https://ct.example.com/other/",
+		"https://ct.example.com/LOG/",
+	})
+	want := []string{"https://ct.example.com/log", "https://ct.example.com/other/", "https://ct.example.com/LOG/"}
+	if !slices.Equal(got, want) {
+		t.Errorf("dedupLogURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestLiveLogs(t *testing.T) {
+	configureLogger(true, false, true)
+	logs := []loglist.LogWithOperator{
+		{Log: loglist.Log{URL: "https://usable.example.com/", State: loglist.LogState{Usable: &loglist.StateInfo{}}}},
+		{Log: loglist.Log{URL: "https://readonly.example.com/", State: loglist.LogState{ReadOnly: &loglist.ReadOnlyInfo{}}}},
+		{Log: loglist.Log{URL: "https://retired.example.com/", State: loglist.LogState{Retired: &loglist.StateInfo{}}}},
+	}
+
+	live := liveLogs(logs)
+	if len(live) != 1 || live[0].Log.URL != "https://usable.example.com/" {
+		t.Errorf("liveLogs() = %v, want only the usable log", live)
+	}
+	if live := liveLogs(logs[1:]); len(live) != 0 {
+		t.Errorf("liveLogs() of frozen logs = %v, want none", live)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	l := &ledger{}
+	l.markDone(ctlog.Range{Start: 20, End: 29})
+	l.markDone(ctlog.Range{Start: 0, End: 9})
+	l.markDone(ctlog.Range{Start: 50, End: 59})
+	l.markDone(ctlog.Range{Start: 10, End: 14})
+
+	want := []ctlog.Range{{Start: 0, End: 14}, {Start: 20, End: 29}, {Start: 50, End: 59}}
+	if !slices.Equal(l.Done, want) {
+		t.Errorf("Done = %v, want %v", l.Done, want)
+	}
+
+	got := l.pending(5, 70)
+	want = []ctlog.Range{{Start: 15, End: 19}, {Start: 30, End: 49}, {Start: 60, End: 69}}
+	if !slices.Equal(got, want) {
+		t.Errorf("pending() = %v, want %v", got, want)
+	}
+	if got := l.pending(0, 10); len(got) != 0 {
+		t.Errorf("pending() inside a done range = %v, want none", got)
+	}
+
+	l.setFailed([]ctlog.Range{{Start: 10, End: 19}, {Start: 20, End: 29}})
+	if want := []ctlog.Range{{Start: 15, End: 19}}; !slices.Equal(l.Failed, want) {
+		t.Errorf("Failed = %v, want %v", l.Failed, want)
+	}
+}
+
+func TestScrapeLog_DurableQueue(t *testing.T) {
+	srv := newTestLog(t, 50)
+	opts := testScrapeOptions(t)
+	opts.DurableQueue = true
+	opts.MaxResults = 5
+	r := New(opts)
+
+	scrape := func() int {
+		t.Helper()
+		writer, err := output.NewWriter(opts.Output, false, "domains")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer writer.Close()
+		parser, err := r.newParser(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.scrapeLog(context.Background(), srv.URL, parser, writer)
+		return writer.Stats()
+	}
+
+	first := scrape()
+	done := rangeSize(r.loadLedger(srv.URL).Done)
+	if done == 0 || done >= 50 {
+		t.Fatalf("ledger after capped run has %d done entries, want some but not all", done)
+	}
+
+	opts.MaxResults = 0
+	second := scrape()
+	if first+second != 50 {
+		t.Errorf("runs wrote %d + %d results, want 50 in total with no overlap", first, second)
+	}
+	if pending := r.loadLedger(srv.URL).pending(0, 50); len(pending) != 0 {
+		t.Errorf("pending after second run = %v, want none", pending)
+	}
+}
+
+func TestScrapeLog_Stats(t *testing.T) {
+	srv := newTestLog(t, 20)
+	opts := testScrapeOptions(t)
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser([]string{"host1.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+			t.Fatalf("scrapeLog() error: %v", err)
+		}
+	}
+
+	want := statsSnapshot{Fetched: 40, Parsed: 40, Matched: 2}
+	if got := r.stats.snapshot(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestStats_MatchesByLog(t *testing.T) {
+	var s stats
+	for range 3 {
+		s.addMatch("https://b.example.com/")
+	}
+	s.addMatch("https://c.example.com/")
+	s.addMatch("https://a.example.com/")
+
+	want := []logMatchCount{
+		{"https://b.example.com/", 3},
+		{"https://a.example.com/", 1},
+		{"https://c.example.com/", 1},
+	}
+	if got := s.matchesByLog(); !slices.Equal(got, want) {
+		t.Errorf("matchesByLog() = %v, want %v", got, want)
+	}
+	if got := s.matched.Load(); got != 5 {
+		t.Errorf("matched = %d, want 5", got)
+	}
+	if got := s.logMatches("https://d.example.com/"); got != 0 {
+		t.Errorf("logMatches() for unseen log = %d, want 0", got)
+	}
+}
+
+func TestAlertOutput(t *testing.T) {
+	srv := newTestLog(t, 20)
+	opts := testScrapeOptions(t)
+	opts.AlertOutput = filepath.Join(t.TempDir(), "alerts.txt")
+	opts.AlertDomains = stringSlice{"host3.example.com", "HOST7.example.com"}
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser([]string{"example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.alert, err = r.openAlertOutput(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatal(err)
+	}
+	r.alert.close()
+	writer.Flush()
+
+	data, err := os.ReadFile(opts.AlertOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	slices.Sort(lines)
+	if want := []string{"host3.example.com", "host7.example.com"}; !slices.Equal(lines, want) {
+		t.Errorf("alert output = %v, want %v", lines, want)
+	}
+	if main, _ := os.ReadFile(opts.Output); len(strings.Fields(string(main))) != 20 {
+		t.Errorf("main output has %d lines, want all 20 matches", len(strings.Fields(string(main))))
+	}
+
+	opts.CountMatches = true
+	opts.AlertOutput = filepath.Join(t.TempDir(), "count.txt")
+	r = New(opts)
+	if r.alert, err = r.openAlertOutput(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatal(err)
+	}
+	r.alert.close()
+	if data, _ := os.ReadFile(opts.AlertOutput); len(data) != 0 {
+		t.Errorf("-count-matches wrote alerts: %q", data)
+	}
+}
+
+func TestUnreachableAlertDomains(t *testing.T) {
+	parser := certparser.New([]string{"example.com", "shop.example.org"})
+	got := unreachableAlertDomains(parser, []string{"login.example.com", "example.org", "*.shop.example.org", "example.net"})
+	if want := []string{"example.org", "example.net"}; !slices.Equal(got, want) {
+		t.Errorf("unreachableAlertDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("out.txt", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("out.txt", "link.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"./out.txt", "out.txt", true},
+		{filepath.Join(dir, "out.txt"), "out.txt", true},
+		{"link.txt", "out.txt", true},
+		{"./new.txt", "new.txt", true},
+		{"other.txt", "out.txt", false},
+	} {
+		if got := samePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSTHAge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	sth := &ctlog.STH{Timestamp: now.Add(-36 * time.Hour).UnixMilli()}
+	if got := sthAge(sth, now); got != 36*time.Hour {
+		t.Errorf("sthAge() = %v, want 36h", got)
+	}
+}
+
+func TestMonitor_MaxLogAge(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.PollInterval = 1
+	opts.PollOnce = true
+	opts.MaxLogAge = time.Hour
+	r := New(opts)
+
+	entries := testEntries(t, 1)
+	stale := newSTHLog(t, entries, []ctlog.STH{{TreeSize: 1, Timestamp: time.Now().Add(-2 * time.Hour).UnixMilli()}}, func() {})
+	fresh := newSTHLog(t, entries, []ctlog.STH{{TreeSize: 1, Timestamp: time.Now().UnixMilli()}}, func() {})
+
+	var err error
+	stderr := captureStderr(t, func() {
+		configureLogger(false, false, true)
+		defer configureLogger(true, false, true)
+		err = r.monitorLogs(context.Background(), nil, []string{stale.URL, fresh.URL})
+	})
+	if err != nil {
+		t.Fatalf("monitorLogs() with one fresh log: %v", err)
+	}
+	if !strings.Contains(stderr, "skipping "+stale.URL) || strings.Contains(stderr, "skipping "+fresh.URL) {
+		t.Errorf("expected only the stale log to be skipped:\n%s", stderr)
+	}
+	if !strings.Contains(stderr, "skipped 1 of 2 log(s)") {
+		t.Errorf("expected the stale log to be counted:\n%s", stderr)
+	}
+
+	err = New(opts).monitorLogs(context.Background(), nil, []string{stale.URL})
+	if err == nil || !strings.Contains(err.Error(), "no CT log has signed a tree head within -max-log-age") {
+		t.Errorf("monitorLogs() with only stale logs = %v, want the -max-log-age error", err)
+	}
+}
+
+func TestMonitor_TreeShrink(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.PollInterval = 1
+	r := New(opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now().UnixMilli()
+	var sths []ctlog.STH
+	for _, size := range []int64{10, 5, 5, 12, 5} {
+		sths = append(sths, ctlog.STH{TreeSize: size, Timestamp: now})
+	}
+	srv := newSTHLog(t, testEntries(t, 12), sths, cancel)
+
+	var err error
+	stderr := captureStderr(t, func() { err = r.monitorLogs(ctx, nil, []string{srv.URL}) })
+	if err != nil {
+		t.Fatalf("monitorLogs() error: %v", err)
+	}
+	if n := strings.Count(stderr, "tree size went down"); n != 2 {
+		t.Errorf("reported %d tree size regressions, want 2 (once while stuck at 5, again after recovering to 12):\n%s", n, stderr)
+	}
+	data, _ := os.ReadFile(opts.Output)
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"host10.example.com", "host11.example.com"}) {
+		t.Errorf("output = %v, want the two entries added by the recovered tree", got)
+	}
+}
+
+func TestMonitor_IdleTimeout(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.PollInterval = 1
+	opts.MonitorIdleTimeout = time.Second
+	opts.Resume = true
+	opts.NoKeepAlive = true
+	r := New(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	sths := []ctlog.STH{{TreeSize: 5, Timestamp: time.Now().UnixMilli()}}
+	srv := newSTHLog(t, testEntries(t, 5), sths, func() {})
+
+	var err error
+	stderr := captureStderr(t, func() {
+		configureLogger(false, false, true)
+		defer configureLogger(true, false, true)
+		err = r.monitorLogs(ctx, nil, []string{srv.URL})
+	})
+	if err != nil {
+		t.Fatalf("monitorLogs() error: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("monitor ran until the test deadline instead of stopping when idle")
+	}
+	for _, want := range []string{"no new entries for 1s, stopping monitor", "monitor stopped", "keep-alive was disabled"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q in the idle stop summary:\n%s", want, stderr)
+		}
+	}
+	progress := r.loadProgress(srv.URL)
+	if progress == nil || progress.LastIndex != 4 {
+		t.Errorf("saved state = %+v, want the tree size of 5 saved for -resume", progress)
+	}
+}
+
+func TestMonitor_PollOnceResume(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.PollInterval = 1
+	opts.PollOnce = true
+	now := time.Now().UnixMilli()
+	// Each run asks for the tree head twice: once to start, once to poll.
+	sths := []ctlog.STH{{TreeSize: 10, Timestamp: now}, {TreeSize: 10, Timestamp: now}, {TreeSize: 12, Timestamp: now}}
+	srv := newSTHLog(t, testEntries(t, 12), sths, func() {})
+
+	if err := New(opts).monitorLogs(context.Background(), nil, []string{srv.URL}); err != nil {
+		t.Fatalf("first -poll-once run: %v", err)
+	}
+	if progress := New(opts).loadProgress(srv.URL); progress == nil || progress.LastIndex != 9 {
+		t.Fatalf("saved state after first run = %+v, want the tree size of 10", progress)
+	}
+	if data, _ := os.ReadFile(opts.Output); len(strings.Fields(string(data))) != 0 {
+		t.Errorf("first run wrote %q, want nothing: the tree didn't grow", data)
+	}
+
+	opts.Resume = true
+	r := New(opts)
+	if err := r.monitorLogs(context.Background(), nil, []string{srv.URL}); err != nil {
+		t.Fatalf("second -poll-once run: %v", err)
+	}
+	data, _ := os.ReadFile(opts.Output)
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"host10.example.com", "host11.example.com"}) {
+		t.Errorf("second run output = %v, want only the two entries added since the first", got)
+	}
+	if progress := r.loadProgress(srv.URL); progress == nil || progress.LastIndex != 11 {
+		t.Errorf("saved state after second run = %+v, want the tree size of 12", progress)
+	}
+}
+
+func TestScrape_FailFast(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.LogURL = []string{"https://127.0.0.1:1/a", "https://127.0.0.1:1/b"}
+
+	if err := New(opts).scrape(context.Background()); err != nil {
+		t.Errorf("scrape() without -fail-fast = %v, want nil", err)
+	}
+
+	opts.FailFast = true
+	r := New(opts)
+	err := r.scrape(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "https://127.0.0.1:1/a") {
+		t.Errorf("scrape() with -fail-fast = %v, want error for the first log", err)
+	}
+	if got := r.stats.errors.Load(); got != 1 {
+		t.Errorf("errors = %d, want 1 (stopped after the first log)", got)
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+	configureLogger(true, false, true)
+	path := filepath.Join(t.TempDir(), "progress.ndjson")
+	r := New(&Options{})
+	var err error
+	if r.progress.sink, err = openProgressSink(path); err != nil {
+		t.Fatal(err)
+	}
+
+	r.progress.report(true)
+	var processed int64
+	untrack := r.progress.track(func() progressEvent {
+		return progressEvent{Log: "https://ct.example.com/", Processed: processed, Total: 10}
+	})
+	r.progress.report(true)
+	processed = 4
+	r.progress.report(true)
+	untrack()
+	r.progress.report(false)
+	r.progress.sink.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"processed":4`) {
+		t.Errorf("events = %q, want one for the tracked log once it made progress", lines)
+	}
+}
+
+func TestScrapeLog_ProgressJSON(t *testing.T) {
+	srv := newTestLog(t, 30)
+	opts := testScrapeOptions(t)
+	opts.ProgressJSON = filepath.Join(t.TempDir(), "progress.ndjson")
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.progress.sink, err = openProgressSink(opts.ProgressJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatal(err)
+	}
+	r.stats.addMatch("https://other.example.com/")
+	r.progress.summary(writer.Stats())
+	r.progress.sink.Close()
+
+	data, err := os.ReadFile(opts.ProgressJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("got %d events, want a final one per log and a summary", len(lines))
+	}
+	var last progressEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-2]), &last); err != nil {
+		t.Fatalf("last log event %q: %v", lines[len(lines)-2], err)
+	}
+	want := progressEvent{Log: srv.URL, Processed: 30, Total: 30, Results: 30, Matched: 30, Done: true}
+	last.Rate = 0
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("final event = %+v, want %+v", last, want)
+	}
+
+	var summary progressEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+		t.Fatalf("summary event %q: %v", lines[len(lines)-1], err)
+	}
+	want = progressEvent{
+		Processed:    30,
+		Results:      30,
+		Matched:      31,
+		Done:         true,
+		MatchesByLog: map[string]int64{srv.URL: 30, "https://other.example.com/": 1},
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("summary event = %+v, want %+v", summary, want)
+	}
+}
+
+func TestScrapeLog_JSONMetaCoveredRange(t *testing.T) {
+	srv := newTestLog(t, 50)
+	opts := testScrapeOptions(t)
+	opts.JSON = true
+	opts.JSONMeta = true
+	opts.MaxResults = 5
+	r := New(opts)
+
+	writer, err := output.NewWriter(opts.Output, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := r.newParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); !errors.Is(err, errResultCap) {
+		t.Fatalf("scrapeLog() error = %v, want errResultCap", err)
+	}
+	writer.Close()
+
+	data, _ := os.ReadFile(opts.Output)
+	var meta output.Meta
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var v struct {
+			Meta *output.Meta `json:"_meta"`
+		}
+		if json.Unmarshal([]byte(line), &v) == nil && v.Meta != nil {
+			meta = *v.Meta
+		}
+	}
+	if meta.LogURL != srv.URL || meta.Start != 0 || meta.End != 9 {
+		t.Errorf("meta = %+v, want entries 0-9 of %s, the batch fetched before the cap", meta, srv.URL)
+	}
+}
+
+func TestScrapeLog_ArchiveLeaves(t *testing.T) {
+	srv := newTestLog(t, 20)
+	opts := testScrapeOptions(t)
+	r := New(opts)
+	dir := t.TempDir()
+	archive, err := newLeafArchive(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.archive = archive
+
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser([]string{"host7.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("archived %d files, want 1", len(files))
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaf archivedLeaf
+	if err := json.Unmarshal(data, &leaf); err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Index != 7 || leaf.LogURL != srv.URL || leaf.LeafInput == "" {
+		t.Errorf("archived leaf = %+v, want index 7 of %s with leaf_input", leaf, srv.URL)
+	}
+	if want := safeLogName(srv.URL) + "_7.json"; files[0].Name() != want {
+		t.Errorf("file name = %q, want %q", files[0].Name(), want)
+	}
+}
+
+func TestProbe_Unreachable(t *testing.T) {
+	opts := testScrapeOptions(t)
+	opts.LogURL = []string{"https://127.0.0.1:1/a"}
+	opts.JSON = true
+
+	err := New(opts).probe(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no CT log answered") {
+		t.Errorf("probe() = %v, want error when no log answers", err)
+	}
+}
+
+func TestPollConcurrency(t *testing.T) {
+	r := &Runner{opts: &Options{Workers: 4}}
+	if got := r.pollConcurrency(); got != 4 {
+		t.Errorf("pollConcurrency() = %d, want -w (4) by default", got)
+	}
+	r.opts.PollConcurrency = 50
+	if got := r.pollConcurrency(); got != 50 {
+		t.Errorf("pollConcurrency() = %d, want 50", got)
+	}
+}
+
+func TestPollPhases(t *testing.T) {
+	interval := 10 * time.Second
+	if got := pollPhases(map[string]int64{"a": 0}, interval); got["a"] != 0 {
+		t.Errorf("single log phase = %v, want 0", got["a"])
+	}
+
+	logs := make(map[string]int64)
+	for i := range 20 {
+		logs[fmt.Sprintf("https://log%d.example.com/", i)] = 0
+	}
+	phase := pollPhases(logs, interval)
+	distinct := make(map[time.Duration]bool)
+	for logURL := range logs {
+		p := phase[logURL]
+		if p < 0 || p >= interval/2 {
+			t.Errorf("phase for %s = %v, want within [0, %v)", logURL, p, interval/2)
+		}
+		distinct[p] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("all logs got the same phase")
+	}
+}
+
+func TestInferFormat(t *testing.T) {
+	configureLogger(true, false, true)
+	tests := []struct {
+		output    string
+		set       map[string]bool
+		wantJSON  bool
+		wantGroup string
+	}{
+		{"out.json", nil, true, ""},
+		{"OUT.NDJSON", nil, true, ""},
+		{"certs.tsv", nil, false, "cert"},
+		{"domains.txt", nil, false, ""},
+		{"domains.csv", nil, false, ""},
+		{"out.json", map[string]bool{"f": true}, false, ""},
+		{"", nil, false, ""},
+	}
+	for _, tt := range tests {
+		o := &Options{Output: tt.output}
+		o.inferFormat(tt.set)
+		if o.JSON != tt.wantJSON || o.GroupBy != tt.wantGroup {
+			t.Errorf("inferFormat(%q, %v): JSON=%v GroupBy=%q, want %v %q", tt.output, tt.set, o.JSON, o.GroupBy, tt.wantJSON, tt.wantGroup)
+		}
+	}
+
+	for output, wantWarning := range map[string]bool{"domains.csv": true, "domains.txt": false} {
+		stderr := captureStderr(t, func() {
+			configureLogger(false, false, true)
+			defer configureLogger(true, false, true)
+			(&Options{Output: output}).inferFormat(nil)
+		})
+		if got := strings.Contains(stderr, "there is no CSV output"); got != wantWarning {
+			t.Errorf("inferFormat(%q) warned = %v, want %v:\n%s", output, got, wantWarning, stderr)
+		}
+	}
+}
+
+func TestReadOutputSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	content := "a.example.com\n2025-01-01T10:00:00Z b.example.com\nb.example.com\n\na.example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := readOutputSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(set)); !slices.Equal(got, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("readOutputSet() = %v, want [a.example.com b.example.com]", got)
+	}
+}
+
+func TestSetDifference(t *testing.T) {
+	old := map[string]struct{}{"a": {}, "b": {}, "c": {}}
+	cur := map[string]struct{}{"b": {}, "d": {}, "c": {}, "e": {}}
+
+	if got := setDifference(cur, old); !slices.Equal(got, []string{"d", "e"}) {
+		t.Errorf("added = %v, want [d e]", got)
+	}
+	if got := setDifference(old, cur); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("removed = %v, want [a]", got)
+	}
+	if got := setDifference(old, old); got == nil || len(got) != 0 {
+		t.Errorf("setDifference of equal sets = %#v, want empty non-nil slice", got)
+	}
+}
+
+func TestExecHook(t *testing.T) {
+	configureLogger(true, false, true)
+	out := filepath.Join(t.TempDir(), "hook.ndjson")
+	h, err := startExecHook("cat > " + out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.send(&ctlog.CertResult{Index: 1, Domains: []string{"a.example.com"}})
+	h.send(&ctlog.CertResult{Index: 2, Domains: []string{"b.example.com"}})
+	h.close()
+
+	data, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), data)
+	}
+	var jr output.JSONResult
+	if err := json.Unmarshal([]byte(lines[1]), &jr); err != nil || jr.Index != 2 || jr.Domains[0] != "b.example.com" {
+		t.Errorf("second line = %q (%v), want the JSON of entry 2", lines[1], err)
+	}
+}
+
+func TestExecHook_CommandExits(t *testing.T) {
+	configureLogger(true, false, true)
+	h, err := startExecHook("exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-h.exited
+	for i := 0; i < execQueue*2; i++ {
+		h.send(&ctlog.CertResult{Index: int64(i)})
+	}
+	h.close()
+	if h.dropped.Load() != execQueue*2 {
+		t.Errorf("dropped = %d, want %d", h.dropped.Load(), execQueue*2)
+	}
+}
+
+func TestReorderBuffer(t *testing.T) {
+	var got []int64
+	b := newReorderBuffer(0, 20, func(r *ctlog.CertResult) { got = append(got, r.Index) })
+	batch := func(start int64, idx ...int64) {
+		var results []*ctlog.CertResult
+		for _, i := range idx {
+			results = append(results, &ctlog.CertResult{Index: i})
+		}
+		b.add(start, start+10, results)
+	}
+
+	batch(10, 15, 12)
+	batch(20, 21)
+	if len(got) != 0 {
+		t.Fatalf("wrote %v before the first batch arrived", got)
+	}
+	batch(0, 3)
+	if want := []int64{3, 12, 15, 21}; !slices.Equal(got, want) {
+		t.Fatalf("after filling the gap got %v, want %v", got, want)
+	}
+
+	// 30-39 is late: once more than 20 entries are held, the gap is
+	// skipped and the held batches are written.
+	batch(40, 41)
+	batch(50, 55)
+	if want := []int64{3, 12, 15, 21}; !slices.Equal(got, want) {
+		t.Fatalf("within the window got %v, want %v", got, want)
+	}
+	batch(60, 62)
+	if want := []int64{3, 12, 15, 21, 41, 55, 62}; !slices.Equal(got, want) {
+		t.Fatalf("after exceeding the window got %v, want %v", got, want)
+	}
+	batch(30, 33)
+	batch(80, 88)
+	b.flush()
+	if want := []int64{3, 12, 15, 21, 41, 55, 62, 33, 88}; !slices.Equal(got, want) {
+		t.Errorf("after flush got %v, want %v", got, want)
+	}
+}
+
+func TestScrapeLog_SinceIndex(t *testing.T) {
+	srv := newTestLog(t, 30)
+	opts := testScrapeOptions(t)
+	opts.SinceIndex = filepath.Join(t.TempDir(), "since.json")
+	os.WriteFile(opts.SinceIndex, []byte(fmt.Sprintf(`{%q: 19}`, srv.URL)), 0o600)
+	r := New(opts)
+
+	var err error
+	if r.sinceIndex, err = loadSinceIndex(opts.SinceIndex); err != nil {
+		t.Fatal(err)
+	}
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+		t.Fatalf("scrapeLog() error: %v", err)
+	}
+	if got := writer.Stats(); got != 10 {
+		t.Errorf("Stats() = %d, want the 10 entries after index 19", got)
+	}
+	saved, err := loadSinceIndex(opts.SinceIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := saved.last(srv.URL); !ok || last != 29 {
+		t.Errorf("saved index = %d, %v, want 29", last, ok)
+	}
+}
+
+func TestProbeBatchLimit(t *testing.T) {
+	configureLogger(true, false, true)
+	const limit = 4
+	var requested [2]int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		requested = [2]int64{start, end}
+		entries := make([]ctlog.RawEntry, min(end-start+1, limit))
+		for i := range entries {
+			entries[i].LeafInput = "AA=="
+		}
+		json.NewEncoder(w).Encode(ctlog.GetEntriesResponse{Entries: entries})
+	}))
+	defer srv.Close()
+
+	r := New(testScrapeOptions(t))
+	client := r.newClient(srv.URL)
+	if got := r.probeBatchLimit(context.Background(), client, 10, 25, 1000); got != limit {
+		t.Errorf("probeBatchLimit() = %d, want %d", got, limit)
+	}
+	if requested != [2]int64{20, 29} {
+		t.Errorf("probe requested %v, want the aligned batch [20 29]", requested)
+	}
+	if got := r.probeBatchLimit(context.Background(), client, 4, 0, 1000); got != 4 {
+		t.Errorf("probeBatchLimit() = %d, want -bs kept at 4", got)
+	}
+}
+
+func TestScrapeLog_RecentGlobalWorkers(t *testing.T) {
+	entries := testEntries(t, 40)
+	var inFlight, peak atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			fmt.Fprintf(w, `{"tree_size":%d,"timestamp":%d}`, len(entries), time.Now().UnixMilli())
+		case "/ct/v1/get-entries":
+			serveTestEntries(w, r, entries)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	srvA := httptest.NewServer(handler)
+	defer srvA.Close()
+	srvB := httptest.NewServer(handler)
+	defer srvB.Close()
+
+	opts := testScrapeOptions(t)
+	opts.Workers = 4
+	opts.Recent = time.Hour
+	opts.GlobalWorkers = 1
+	r := New(opts)
+	writer, err := output.NewWriter(opts.Output, false, "domains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	parser, err := r.newParser(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two logs sharing -global-workers: one's -recent search and batch
+	// probe must wait for the other's get-entries slots.
+	var wg sync.WaitGroup
+	for _, srv := range []*httptest.Server{srvA, srvB} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.scrapeLog(context.Background(), srv.URL, parser, writer); err != nil {
+				t.Errorf("scrapeLog(%s) error: %v", srv.URL, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 1 {
+		t.Errorf("peak concurrent requests = %d, want 1", got)
+	}
+	if got := writer.Stats(); got != 40 {
+		t.Errorf("Stats() = %d, want 40", got)
+	}
+}
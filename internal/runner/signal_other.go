@@ -0,0 +1,7 @@
+//go:build !unix
+
+package runner
+
+import "context"
+
+func (r *Runner) handleControlSignals(ctx context.Context) {}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package runner
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// SIGUSR1 prints progress on demand, SIGUSR2 toggles pausing fetch workers.
+func (r *Runner) handleControlSignals(ctx context.Context) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigChan:
+				switch sig {
+				case syscall.SIGUSR1:
+					select {
+					case r.statusReq <- struct{}{}:
+					default:
+					}
+				case syscall.SIGUSR2:
+					if r.pause.Toggle() {
+						log.Warning("paused - send SIGUSR2 again to resume")
+					} else {
+						log.Info("resumed")
+					}
+				}
+			}
+		}
+	}()
+}
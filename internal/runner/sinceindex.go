@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sinceIndex is the -since-index-file map of log URL to the highest entry
+// index scraped by a completed run. Each run continues after it, so
+// repeated runs collect only what was logged in between.
+type sinceIndex struct {
+	path    string
+	indexes map[string]int64
+}
+
+// loadSinceIndex reads path. A missing file is an empty map, so the
+// first run scrapes whatever range the other flags select.
+func loadSinceIndex(path string) (*sinceIndex, error) {
+	s := &sinceIndex{path: path, indexes: make(map[string]int64)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.indexes); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// last returns the highest index recorded for logURL.
+func (s *sinceIndex) last(logURL string) (int64, bool) {
+	idx, ok := s.indexes[logURL]
+	return idx, ok
+}
+
+// record stores idx for logURL and rewrites the file.
+func (s *sinceIndex) record(logURL string, idx int64) error {
+	s.indexes[logURL] = idx
+	data, err := json.MarshalIndent(s.indexes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
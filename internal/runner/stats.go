@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// stats aggregates counters across every log in a run. It is updated from
+// the fetch and parse goroutines of all logs and can be read at any time.
+type stats struct {
+	fetched atomic.Int64
+	parsed  atomic.Int64
+	matched atomic.Int64
+	dropped atomic.Int64
+	// errors counts entries that failed to parse and logs that could not
+	// be scraped or polled.
+	errors atomic.Int64
+	// byLog counts matches per log URL.
+	byLog sync.Map
+}
+
+func (s *stats) addMatch(logURL string) {
+	s.matched.Add(1)
+	n, ok := s.byLog.Load(logURL)
+	if !ok {
+		n, _ = s.byLog.LoadOrStore(logURL, new(atomic.Int64))
+	}
+	n.(*atomic.Int64).Add(1)
+}
+
+// logMatches returns the number of matches from logURL so far.
+func (s *stats) logMatches(logURL string) int64 {
+	if n, ok := s.byLog.Load(logURL); ok {
+		return n.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+type logMatchCount struct {
+	log     string
+	matched int64
+}
+
+// matchesByLog lists the logs that produced matches, most first.
+func (s *stats) matchesByLog() []logMatchCount {
+	var out []logMatchCount
+	s.byLog.Range(func(k, v any) bool {
+		out = append(out, logMatchCount{log: k.(string), matched: v.(*atomic.Int64).Load()})
+		return true
+	})
+	slices.SortFunc(out, func(a, b logMatchCount) int {
+		if c := cmp.Compare(b.matched, a.matched); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.log, b.log)
+	})
+	return out
+}
+
+type statsSnapshot struct {
+	Fetched int64
+	Parsed  int64
+	Matched int64
+	Dropped int64
+	Errors  int64
+}
+
+func (s *stats) snapshot() statsSnapshot {
+	return statsSnapshot{
+		Fetched: s.fetched.Load(),
+		Parsed:  s.parsed.Load(),
+		Matched: s.matched.Load(),
+		Dropped: s.dropped.Load(),
+		Errors:  s.errors.Load(),
+	}
+}
+
+func (s statsSnapshot) String() string {
+	return fmt.Sprintf("%d fetched, %d parsed, %d matched, %d dropped, %d errors",
+		s.Fetched, s.Parsed, s.Matched, s.Dropped, s.Errors)
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/TheArqsz/ct-hulhu/internal/ctlog"
+)
+
+// rangeTrace is the -trace-file record of coverage: one line per fetched
+// or dropped range, "<status> <start> <end> <log URL>" with inclusive
+// indices, in the order workers finished them.
+type rangeTrace struct {
+	mu sync.Mutex
+	f  *os.File
+	bw *bufio.Writer
+}
+
+func openRangeTrace(path string) (*rangeTrace, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rangeTrace{f: f, bw: bufio.NewWriter(f)}, nil
+}
+
+func (t *rangeTrace) record(logURL, status string, rg ctlog.Range) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.bw, "%s %d %d %s\n", status, rg.Start, rg.End, logURL)
+}
+
+// attach makes pool report its ranges to the trace, if there is one.
+func (t *rangeTrace) attach(pool *ctlog.WorkerPool, logURL string) {
+	if t == nil {
+		return
+	}
+	pool.SetRangeLog(func(status string, rg ctlog.Range) { t.record(logURL, status, rg) })
+}
+
+func (t *rangeTrace) close() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.bw.Flush(); err != nil {
+		log.Warning("writing -trace-file: %v", err)
+	}
+	t.f.Close()
+}
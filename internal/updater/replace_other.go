@@ -0,0 +1,11 @@
+//go:build !windows
+
+package updater
+
+import "os"
+
+// replaceExecutable moves the new binary over the running one; the old
+// inode stays valid for the running process.
+func replaceExecutable(tmpPath, execPath string) error {
+	return os.Rename(tmpPath, execPath)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package updater
+
+import "os"
+
+// replaceExecutable moves the new binary over the running one. Windows
+// refuses to overwrite an executable that is in use but does allow
+// renaming it, so a failed rename falls back to swapExecutable.
+func replaceExecutable(tmpPath, execPath string) error {
+	if err := os.Rename(tmpPath, execPath); err == nil {
+		return nil
+	}
+	return swapExecutable(tmpPath, execPath)
+}
@@ -8,6 +8,7 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -40,6 +41,13 @@ var httpClient = &http.Client{
 	},
 }
 
+// SetMinTLSVersion sets the lowest TLS version used to talk to GitHub.
+func SetMinTLSVersion(v uint16) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{MinVersion: v}
+	httpClient.Transport = t
+}
+
 type githubRelease struct {
 	TagName string  `json:"tag_name"`
 	Assets  []asset `json:"assets"`
@@ -242,7 +250,37 @@ func downloadBinary(ctx context.Context, version string) error {
 	}
 	defer os.Remove(tmpPath)
 
-	return os.Rename(tmpPath, execPath)
+	return replaceExecutable(tmpPath, execPath)
+}
+
+// swapExecutable moves the running binary aside to execPath.old and puts
+// the new one in its place, restoring the old binary if the second step
+// fails. The .old file can't be deleted while it is still running, so
+// RemoveStaleBinary cleans it up on the next start.
+func swapExecutable(tmpPath, execPath string) error {
+	oldPath := execPath + ".old"
+	// A leftover from an earlier update that couldn't be removed yet.
+	if err := os.Remove(oldPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", oldPath, err)
+	}
+	if err := os.Rename(execPath, oldPath); err != nil {
+		return fmt.Errorf("moving running binary aside: %w", err)
+	}
+	if err := os.Rename(tmpPath, execPath); err != nil {
+		if restoreErr := os.Rename(oldPath, execPath); restoreErr != nil {
+			return fmt.Errorf("installing new binary: %w (restoring old binary also failed: %v, it is at %s)", err, restoreErr, oldPath)
+		}
+		return fmt.Errorf("installing new binary: %w", err)
+	}
+	return nil
+}
+
+// RemoveStaleBinary deletes the .old binary a previous self-update left
+// next to the executable. It is a no-op when there is none.
+func RemoveStaleBinary() {
+	if execPath, err := os.Executable(); err == nil {
+		os.Remove(execPath + ".old")
+	}
 }
 
 func extractFromTarGz(data []byte, binaryName string) ([]byte, error) {
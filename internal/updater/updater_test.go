@@ -8,6 +8,8 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -253,3 +255,55 @@ func TestVersionDisplayFormat_NoDoubleV(t *testing.T) {
 		t.Errorf("TrimPrefix(%q, \"v\") = %q, want \"0.3.1\"", tag, got)
 	}
 }
+
+func TestSwapExecutable(t *testing.T) {
+	dir := t.TempDir()
+	execPath := filepath.Join(dir, "ct-hulhu.exe")
+	tmpPath := execPath + ".tmp"
+	writeFile(t, execPath, "old")
+	writeFile(t, tmpPath, "new")
+	writeFile(t, execPath+".old", "older")
+
+	if err := swapExecutable(tmpPath, execPath); err != nil {
+		t.Fatalf("swapExecutable() error: %v", err)
+	}
+	if got := readFile(t, execPath); got != "new" {
+		t.Errorf("executable = %q, want new", got)
+	}
+	if got := readFile(t, execPath+".old"); got != "old" {
+		t.Errorf(".old = %q, want the replaced binary", got)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("tmp file still present: %v", err)
+	}
+}
+
+func TestSwapExecutable_RestoresOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	execPath := filepath.Join(dir, "ct-hulhu.exe")
+	writeFile(t, execPath, "old")
+
+	err := swapExecutable(filepath.Join(dir, "missing.tmp"), execPath)
+	if err == nil {
+		t.Fatal("expected error for missing new binary")
+	}
+	if got := readFile(t, execPath); got != "old" {
+		t.Errorf("executable = %q, want the old binary restored", got)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}